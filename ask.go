@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultAskPassages = 5
+	maxAskPassages     = 12
+	askExcerptLength   = 300
+	// askTemperature keeps answers close to the quoted passages
+	askTemperature = 0.2
+)
+
+var citationRegex = regexp.MustCompile(`\[(p\d+)\]`)
+
+// AskCitation points from the answer to the passage that supports it
+type AskCitation struct {
+	ID      string `json:"id"`
+	Heading string `json:"heading"`
+	URL     string `json:"url"` // page URL with the section anchor
+	Excerpt string `json:"excerpt"`
+}
+
+// AskPassage is a passage that was ranked and sent to the model
+type AskPassage struct {
+	ID      string  `json:"id"`
+	Heading string  `json:"heading"`
+	Anchor  string  `json:"anchor,omitempty"`
+	Score   float64 `json:"score"`
+}
+
+// AskPageOutput is the structuredContent returned by ask_page
+type AskPageOutput struct {
+	URL       string        `json:"url"`
+	Question  string        `json:"question"`
+	Answer    string        `json:"answer"`
+	Answered  bool          `json:"answered"` // false when no passage matched the question
+	Citations []AskCitation `json:"citations"`
+	Passages  []AskPassage  `json:"passages"`
+	Usage     *TokenUsage   `json:"usage,omitempty"`
+}
+
+// askPageTool describes the ask_page tool
+func askPageTool() Tool {
+	return Tool{
+		Name:        "ask_page",
+		Description: "Answer a question about a web page. The page is converted to Markdown and split into passages, the passages most relevant to the question are ranked locally, and only those are given to the model. The answer cites its sources with quoted excerpts and links to the page sections.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"url": map[string]interface{}{
+					"type":        "string",
+					"description": "The page to answer from",
+				},
+				"question": map[string]interface{}{
+					"type":        "string",
+					"description": "The question to answer",
+				},
+				"max_passages": map[string]interface{}{
+					"type":        "integer",
+					"description": "Number of top-ranked passages given to the model (default: 5, max: 12)",
+				},
+				"include_selector": map[string]interface{}{
+					"type":        "string",
+					"description": "CSS selector for the parts of the page to search",
+				},
+				"exclude_selector": map[string]interface{}{
+					"type":        "string",
+					"description": "CSS selector for elements to ignore",
+				},
+				"model": map[string]interface{}{
+					"type":        "string",
+					"description": "AI model to use for conversion and answering (default: " + defaultModel + ")",
+				},
+				"maxTokens": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum tokens in response (default: 4000)",
+				},
+			},
+			"required": []string{"url", "question"},
+		},
+		OutputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"url":      map[string]interface{}{"type": "string"},
+				"question": map[string]interface{}{"type": "string"},
+				"answer":   map[string]interface{}{"type": "string"},
+				"answered": map[string]interface{}{"type": "boolean"},
+				"citations": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"id":      map[string]interface{}{"type": "string"},
+							"heading": map[string]interface{}{"type": "string"},
+							"url":     map[string]interface{}{"type": "string"},
+							"excerpt": map[string]interface{}{"type": "string"},
+						},
+						"required": []string{"id", "heading", "url", "excerpt"},
+					},
+				},
+				"passages": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"id":      map[string]interface{}{"type": "string"},
+							"heading": map[string]interface{}{"type": "string"},
+							"anchor":  map[string]interface{}{"type": "string"},
+							"score":   map[string]interface{}{"type": "number"},
+						},
+					},
+				},
+				"usage": tokenUsageSchema,
+			},
+			"required": []string{"url", "question", "answer", "answered", "citations", "passages"},
+		},
+	}
+}
+
+// handleAskPage processes the ask_page tool call
+func handleAskPage(id interface{}, args map[string]interface{}) *JSONRPCMessage {
+	question, _ := args["question"].(string)
+	if strings.TrimSpace(question) == "" {
+		return newErrorResponse(id, -32602, "missing required parameter: question")
+	}
+	input, err := parseWebReaderInput(args)
+	if err != nil {
+		return newErrorResponse(id, -32602, err.Error())
+	}
+	// Tables are rendered locally so the passages quote them exactly
+	input.TablesAsMarkdown = true
+
+	maxPassages := defaultAskPassages
+	if v, ok := args["max_passages"].(float64); ok && v > 0 {
+		maxPassages = min(int(v), maxAskPassages)
+	}
+
+	page, rpcErr := readWebPage(context.Background(), input)
+	if rpcErr != nil {
+		return &JSONRPCMessage{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error:   rpcErr,
+		}
+	}
+
+	passages := rankPassages(chunkMarkdown(page.Markdown, headingAnchors(page.HTML)), question, maxPassages)
+	log.Printf("Answering %q from %d passages of %s", question, len(passages), input.URL)
+
+	output := AskPageOutput{
+		URL:       input.URL,
+		Question:  question,
+		Citations: []AskCitation{},
+		Passages:  []AskPassage{},
+	}
+	usage := page.Usage
+	for _, p := range passages {
+		output.Passages = append(output.Passages, AskPassage{ID: p.ID, Heading: p.Heading, Anchor: p.Anchor, Score: p.Score})
+	}
+
+	if len(passages) == 0 {
+		output.Answer = "The page does not appear to contain information about this question."
+	} else {
+		var prompt strings.Builder
+		fmt.Fprintf(&prompt, "Question: %s\n\nPassages from %s:\n", question, input.URL)
+		for _, p := range passages {
+			fmt.Fprintf(&prompt, "\n[%s] (section: %s)\n%s\n", p.ID, p.Heading, p.Text)
+		}
+
+		messages := []AIMessage{
+			{
+				Role: "system",
+				Content: "You answer questions using only the passages provided from a web page. " +
+					"Cite the passages that support each statement with their IDs in square brackets, e.g. [p3]. " +
+					"If the passages do not contain the answer, say so plainly instead of guessing. " +
+					"Answer concisely in Markdown.",
+			},
+			{
+				Role:    "user",
+				Content: prompt.String(),
+			},
+		}
+
+		answer, answerUsage, err := callAI(context.Background(), messages, input.Model, input.MaxTokens, askTemperature)
+		usage.add(answerUsage)
+		if err != nil {
+			return newErrorResponse(id, -2, fmt.Sprintf("Failed to answer question: %v", err))
+		}
+		output.Answer = answer
+		output.Answered = true
+		output.Citations = askCitations(answer, passages, question, input.URL)
+	}
+	output.Usage = usage.report()
+
+	return newToolResult(id, []interface{}{
+		TextContent{
+			Type: "text",
+			Text: formatAskReport(output),
+		},
+	}, output)
+}
+
+// askCitations resolves the passage IDs cited in the answer, in order of first citation
+func askCitations(answer string, passages []pagePassage, question, pageURL string) []AskCitation {
+	byID := make(map[string]pagePassage, len(passages))
+	for _, p := range passages {
+		byID[p.ID] = p
+	}
+
+	citations := []AskCitation{}
+	seen := make(map[string]bool)
+	for _, match := range citationRegex.FindAllStringSubmatch(answer, -1) {
+		p, ok := byID[match[1]]
+		if !ok || seen[p.ID] {
+			continue
+		}
+		seen[p.ID] = true
+
+		citations = append(citations, AskCitation{
+			ID:      p.ID,
+			Heading: p.Heading,
+			URL:     sectionURL(pageURL, p.Anchor),
+			Excerpt: bestExcerpt(p.Text, question, askExcerptLength),
+		})
+	}
+	return citations
+}
+
+// sectionURL points a page URL at a section anchor, keeping the query string
+// that may be needed to load the page and replacing any existing fragment
+func sectionURL(pageURL, anchor string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return pageURL
+	}
+	u.Fragment = anchor
+	u.RawFragment = ""
+	return u.String()
+}
+
+// formatAskReport renders the answer followed by its sources
+func formatAskReport(output AskPageOutput) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n%s\n", output.Question, output.Answer)
+	if len(output.Citations) > 0 {
+		b.WriteString("\n**Sources:**\n")
+		for _, c := range output.Citations {
+			fmt.Fprintf(&b, "- [%s] %s (%s)\n  > %s\n", c.ID, c.Heading, c.URL, c.Excerpt)
+		}
+	}
+	if output.Usage != nil {
+		b.WriteString("\n---\n" + formatTokenUsage(output.Usage))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestSectionURL(t *testing.T) {
+	tests := []struct {
+		page, anchor, want string
+	}{
+		{"https://example.com/docs", "install", "https://example.com/docs#install"},
+		{"https://example.com/docs?v=2", "install", "https://example.com/docs?v=2#install"},
+		{"https://example.com/docs?v=2#old", "new", "https://example.com/docs?v=2#new"},
+		{"https://example.com/docs#old", "", "https://example.com/docs"},
+	}
+	for _, tt := range tests {
+		if got := sectionURL(tt.page, tt.anchor); got != tt.want {
+			t.Errorf("sectionURL(%q, %q) = %q, want %q", tt.page, tt.anchor, got, tt.want)
+		}
+	}
+}
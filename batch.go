@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBatchConcurrency = 4
+	maxBatchConcurrency     = 10
+	maxBatchURLs            = 25
+	defaultBatchTimeout     = 120 * time.Second
+)
+
+// Per-URL outcomes reported in BatchReadResult.Status
+const (
+	batchStatusOK      = "ok"
+	batchStatusError   = "error"
+	batchStatusTimeout = "timeout"
+)
+
+// BatchReadResult is the outcome of reading one URL of a batch
+type BatchReadResult struct {
+	URL       string           `json:"url"`
+	Status    string           `json:"status"` // ok, error or timeout
+	Error     string           `json:"error,omitempty"`
+	ErrorCode int              `json:"error_code,omitempty"` // same codes as a failed web_reader call
+	Usage     *TokenUsage      `json:"usage,omitempty"`      // AI tokens spent before a read failed
+	Result    *WebReaderOutput `json:"result,omitempty"`
+}
+
+// WebReaderBatchOutput is the structuredContent returned by web_reader_batch
+type WebReaderBatchOutput struct {
+	Results          []BatchReadResult `json:"results"`
+	Succeeded        int               `json:"succeeded"`
+	Failed           int               `json:"failed"`
+	TimedOut         int               `json:"timed_out"`
+	ProcessingTimeMs float64           `json:"processing_time_ms"`
+	Usage            *TokenUsage       `json:"usage,omitempty"` // AI tokens spent on the batch, including failed reads
+}
+
+// webReaderBatchTool describes the web_reader_batch tool. It accepts every
+// web_reader option, applied to all URLs of the batch.
+func webReaderBatchTool() Tool {
+	properties := map[string]interface{}{
+		"urls": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": fmt.Sprintf("The URLs to read (max: %d)", maxBatchURLs),
+		},
+		"concurrency": map[string]interface{}{
+			"type":        "integer",
+			"description": "Number of URLs read in parallel (default: 4, max: 10)",
+		},
+		"timeout_seconds": map[string]interface{}{
+			"type":        "number",
+			"description": "Overall time limit for the batch in seconds; URLs not finished by then are reported as timed out (default: 120)",
+		},
+	}
+	for name, schema := range webReaderTool().InputSchema["properties"].(map[string]interface{}) {
+		if name != "url" {
+			properties[name] = schema
+		}
+	}
+
+	return Tool{
+		Name:        "web_reader_batch",
+		Description: "Read several web pages in one call. Fetches and converts the URLs concurrently with the same options as web_reader, returning a success or error entry for each URL. Results for finished pages are returned even when others fail or time out.",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   []string{"urls"},
+		},
+		OutputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"results": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"url": map[string]interface{}{"type": "string"},
+							"status": map[string]interface{}{
+								"type": "string",
+								"enum": []string{batchStatusOK, batchStatusError, batchStatusTimeout},
+							},
+							"error":      map[string]interface{}{"type": "string"},
+							"error_code": map[string]interface{}{"type": "integer"},
+							"usage":      tokenUsageSchema,
+							"result":     webReaderOutputSchema,
+						},
+						"required": []string{"url", "status"},
+					},
+				},
+				"succeeded":          map[string]interface{}{"type": "integer"},
+				"failed":             map[string]interface{}{"type": "integer"},
+				"timed_out":          map[string]interface{}{"type": "integer"},
+				"processing_time_ms": map[string]interface{}{"type": "number"},
+				"usage":              tokenUsageSchema,
+			},
+			"required": []string{"results", "succeeded", "failed", "timed_out", "processing_time_ms"},
+		},
+	}
+}
+
+// handleWebReaderBatch processes the web_reader_batch tool call
+func handleWebReaderBatch(id interface{}, args map[string]interface{}, progressToken interface{}) *JSONRPCMessage {
+	startTime := time.Now()
+
+	urls := stringSliceArg(args, "urls")
+	if len(urls) == 0 {
+		return newErrorResponse(id, -32602, "missing required parameter: urls")
+	}
+	if len(urls) > maxBatchURLs {
+		return newErrorResponse(id, -32602, fmt.Sprintf("too many urls: %d (max %d)", len(urls), maxBatchURLs))
+	}
+
+	concurrency := defaultBatchConcurrency
+	if v, ok := args["concurrency"].(float64); ok && v > 0 {
+		concurrency = min(int(v), maxBatchConcurrency)
+	}
+	timeout := defaultBatchTimeout
+	if v, ok := args["timeout_seconds"].(float64); ok && v > 0 {
+		timeout = time.Duration(v * float64(time.Second))
+	}
+
+	// Validate the options for every URL up front so a bad option fails the call before anything is read
+	inputs := make([]*WebReaderInput, len(urls))
+	for i, u := range urls {
+		urlArgs := make(map[string]interface{}, len(args))
+		for k, v := range args {
+			urlArgs[k] = v
+		}
+		urlArgs["url"] = u
+
+		input, err := parseWebReaderInput(urlArgs)
+		if err != nil {
+			return newErrorResponse(id, -32602, fmt.Sprintf("%s: %v", u, err))
+		}
+		inputs[i] = input
+	}
+
+	log.Printf("Reading batch of %d URLs (concurrency %d, timeout %v)", len(urls), concurrency, timeout)
+
+	results, pages := readBatch(inputs, concurrency, timeout, progressToken)
+
+	output := WebReaderBatchOutput{
+		Results:          results,
+		ProcessingTimeMs: float64(time.Since(startTime).Microseconds()) / 1000.0,
+	}
+	content := []interface{}{}
+	var usage TokenUsage
+	for i, r := range results {
+		switch r.Status {
+		case batchStatusOK:
+			output.Succeeded++
+			page := pages[i]
+			usage.add(page.Usage)
+			content = append(content, buildToolResponse(page, inputs[i].ImagesAsContent)...)
+		case batchStatusError:
+			output.Failed++
+			if r.Usage != nil {
+				usage.add(*r.Usage)
+			}
+		case batchStatusTimeout:
+			output.TimedOut++
+		}
+	}
+	output.Usage = usage.report()
+
+	content = append([]interface{}{
+		TextContent{
+			Type: "text",
+			Text: formatBatchReport(output),
+		},
+	}, content...)
+
+	return newToolResult(id, content, output)
+}
+
+// readBatch reads the inputs with a bounded pool of workers, keeping input order.
+// When the timeout expires the results collected so far are returned and the
+// remaining URLs are reported as timed out. Reads still in flight are cancelled
+// through the context and stop reporting progress.
+func readBatch(inputs []*WebReaderInput, concurrency int, timeout time.Duration, progressToken interface{}) ([]BatchReadResult, []*webPage) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	results := make([]BatchReadResult, len(inputs))
+	pages := make([]*webPage, len(inputs))
+	for i, input := range inputs {
+		results[i] = BatchReadResult{
+			URL:    input.URL,
+			Status: batchStatusTimeout,
+			Error:  fmt.Sprintf("not finished within %v", timeout),
+		}
+	}
+
+	completed := 0
+	done := make(chan struct{})
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				result := BatchReadResult{URL: inputs[i].URL}
+				page, rpcErr := readWebPage(ctx, inputs[i])
+				if rpcErr != nil {
+					result.Status = batchStatusError
+					result.Error = rpcErr.Message
+					result.ErrorCode = rpcErr.Code
+					if page != nil {
+						result.Usage = page.Usage.report()
+					}
+				} else {
+					output := page.output()
+					result.Status = batchStatusOK
+					result.Result = &output
+				}
+
+				// Progress is sent under the lock so none goes out after the batch has returned
+				mu.Lock()
+				if ctx.Err() == nil {
+					results[i] = result
+					pages[i] = page
+					completed++
+					sendProgress(progressToken, float64(completed), float64(len(inputs)), fmt.Sprintf("Read %s", inputs[i].URL))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	go func() {
+	feed:
+		for i := range inputs {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Batch timed out after %v", timeout)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	cancel()
+	return append([]BatchReadResult(nil), results...), append([]*webPage(nil), pages...)
+}
+
+// formatBatchReport renders a summary of a batch read, listing the URLs that failed
+func formatBatchReport(output WebReaderBatchOutput) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Batch Read of %d URLs\n\n", len(output.Results))
+	fmt.Fprintf(&b, "- Succeeded: %d\n", output.Succeeded)
+	fmt.Fprintf(&b, "- Failed: %d\n", output.Failed)
+	if output.TimedOut > 0 {
+		fmt.Fprintf(&b, "- Timed out: %d\n", output.TimedOut)
+	}
+	fmt.Fprintf(&b, "- Processing time: %.2fms\n", output.ProcessingTimeMs)
+	b.WriteString(formatTokenUsage(output.Usage))
+
+	header := false
+	for _, r := range output.Results {
+		if r.Status == batchStatusOK {
+			continue
+		}
+		if !header {
+			b.WriteString("\n**Not read:**\n")
+			header = true
+		}
+		fmt.Fprintf(&b, "- %s (%s): %s\n", r.URL, r.Status, r.Error)
+	}
+
+	return b.String()
+}
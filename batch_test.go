@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadBatch(t *testing.T) {
+	useTestLimiter(t)
+
+	var cancelled int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/slow"):
+			select {
+			case <-r.Context().Done():
+				atomic.AddInt32(&cancelled, 1)
+			case <-time.After(5 * time.Second):
+			}
+		case strings.HasPrefix(r.URL.Path, "/page"):
+			w.Write([]byte(`<html><head><title>` + r.URL.Path + `</title></head><body><p>Hello</p></body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	paths := []string{"/page1", "/missing", "/slow1", "/page2", "/slow2"}
+	inputs := make([]*WebReaderInput, len(paths))
+	for i, p := range paths {
+		// metadata_only keeps the reads away from the AI service
+		inputs[i] = &WebReaderInput{URL: server.URL + p, MetadataOnly: true}
+	}
+
+	start := time.Now()
+	results, pages := readBatch(inputs, 2, 500*time.Millisecond, nil)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("readBatch() took %v, want it to return at the timeout", elapsed)
+	}
+
+	want := []string{batchStatusOK, batchStatusError, batchStatusTimeout, batchStatusOK, batchStatusTimeout}
+	for i, r := range results {
+		if r.URL != inputs[i].URL {
+			t.Errorf("result %d is for %q, want input order kept (%q)", i, r.URL, inputs[i].URL)
+		}
+		if r.Status != want[i] {
+			t.Errorf("%s: status %q (%s), want %q", paths[i], r.Status, r.Error, want[i])
+		}
+		if (pages[i] != nil) != (r.Status == batchStatusOK) {
+			t.Errorf("%s: page returned = %v with status %q", paths[i], pages[i] != nil, r.Status)
+		}
+	}
+
+	if r := results[0]; r.Result == nil || r.Result.Page == nil || r.Result.Page.Title != "/page1" {
+		t.Errorf("ok result = %+v, want the page's web_reader result", r.Result)
+	}
+	if r := results[1]; r.ErrorCode != -1 || !strings.Contains(r.Error, "404") {
+		t.Errorf("error result = code %d, %q, want -1 with the HTTP status", r.ErrorCode, r.Error)
+	}
+	if r := results[2]; !strings.Contains(r.Error, "not finished within 500ms") {
+		t.Errorf("timeout result error = %q", r.Error)
+	}
+
+	// Reads still in flight at the timeout are cancelled rather than left running
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&cancelled) < 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if atomic.LoadInt32(&cancelled) < 1 {
+		t.Error("the in-flight slow read was not cancelled")
+	}
+}
+
+func TestFormatBatchReport(t *testing.T) {
+	output := WebReaderBatchOutput{
+		Results: []BatchReadResult{
+			{URL: "https://a.example/", Status: batchStatusOK},
+			{URL: "https://b.example/", Status: batchStatusError, Error: "HTTP 404"},
+			{URL: "https://c.example/", Status: batchStatusTimeout, Error: "not finished within 1m0s"},
+		},
+		Succeeded:        1,
+		Failed:           1,
+		TimedOut:         1,
+		ProcessingTimeMs: 12.5,
+	}
+
+	report := formatBatchReport(output)
+	for _, want := range []string{
+		"# Batch Read of 3 URLs\n",
+		"- Timed out: 1\n",
+		"**Not read:**\n- https://b.example/ (error): HTTP 404\n- https://c.example/ (timeout): not finished within 1m0s\n",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report does not contain %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "https://a.example/") {
+		t.Errorf("report lists a URL that was read:\n%s", report)
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCheckConcurrency = 8
+	maxCheckConcurrency     = 32
+	defaultCheckMaxLinks    = 100
+	defaultCheckTimeout     = 10 * time.Second
+	maxCheckRedirects       = 10
+)
+
+// Link health classifications reported in LinkCheckResult.Status
+const (
+	linkStatusOK         = "ok"
+	linkStatusRedirected = "redirected"
+	linkStatusBroken     = "broken"
+	// linkStatusUnchecked is a link that was never requested because its host's
+	// rate limiter had no slot in time, e.g. a long same-host audit or a Crawl-delay
+	linkStatusUnchecked = "unchecked"
+)
+
+// LinkCheckResult is the health of a single link found on the audited page
+type LinkCheckResult struct {
+	URL           string   `json:"url"`
+	Text          string   `json:"text,omitempty"`
+	Kind          string   `json:"kind,omitempty"`
+	Region        string   `json:"region,omitempty"`
+	Status        string   `json:"status"` // ok, redirected, broken or unchecked
+	StatusCode    int      `json:"status_code,omitempty"`
+	Method        string   `json:"method,omitempty"` // HEAD, or GET when HEAD was rejected
+	FinalURL      string   `json:"final_url,omitempty"`
+	RedirectChain []string `json:"redirect_chain,omitempty"`
+	LatencyMs     float64  `json:"latency_ms"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// CheckLinksOutput is the structuredContent returned by check_links
+type CheckLinksOutput struct {
+	SourceURL  string            `json:"source_url"`
+	Checked    int               `json:"checked"`
+	OK         int               `json:"ok"`
+	Redirected int               `json:"redirected"`
+	Broken     int               `json:"broken"`
+	Unchecked  int               `json:"unchecked"` // requested links the rate limiter never let through
+	Skipped    int               `json:"skipped"`
+	Results    []LinkCheckResult `json:"results"`
+}
+
+// checkLinksTool describes the check_links tool
+func checkLinksTool() Tool {
+	return Tool{
+		Name:        "check_links",
+		Description: "Audit the links on a web page: extract them, request each one (HEAD, falling back to GET) and report status code, redirect chain, final URL, latency and whether it is ok, redirected or broken.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"url": map[string]interface{}{
+					"type":        "string",
+					"description": "The page whose links should be checked",
+				},
+				"concurrency": map[string]interface{}{
+					"type":        "integer",
+					"description": "Number of links checked in parallel (default: 8, max: 32)",
+				},
+				"max_links": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of links to check (default: 100)",
+				},
+				"timeout_seconds": map[string]interface{}{
+					"type":        "number",
+					"description": "Timeout for each link check in seconds (default: 10)",
+				},
+				"link_kinds": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string", "enum": []string{linkKindInternal, linkKindExternal}},
+					"description": "Only check links of these kinds (default: internal and external)",
+				},
+				"link_regions": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string", "enum": []string{linkRegionMain, linkRegionNav, linkRegionHeader, linkRegionFooter, linkRegionAside}},
+					"description": "Only check links found in these page regions (default: all)",
+				},
+				"only_problems": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Only list broken and redirected links in the results",
+				},
+			},
+			"required": []string{"url"},
+		},
+		OutputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"source_url": map[string]interface{}{"type": "string"},
+				"checked":    map[string]interface{}{"type": "integer"},
+				"ok":         map[string]interface{}{"type": "integer"},
+				"redirected": map[string]interface{}{"type": "integer"},
+				"broken":     map[string]interface{}{"type": "integer"},
+				"unchecked":  map[string]interface{}{"type": "integer"},
+				"skipped":    map[string]interface{}{"type": "integer"},
+				"results": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"url":    map[string]interface{}{"type": "string"},
+							"text":   map[string]interface{}{"type": "string"},
+							"kind":   map[string]interface{}{"type": "string"},
+							"region": map[string]interface{}{"type": "string"},
+							"status": map[string]interface{}{
+								"type": "string",
+								"enum": []string{linkStatusOK, linkStatusRedirected, linkStatusBroken, linkStatusUnchecked},
+							},
+							"status_code":    map[string]interface{}{"type": "integer"},
+							"method":         map[string]interface{}{"type": "string"},
+							"final_url":      map[string]interface{}{"type": "string"},
+							"redirect_chain": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+							"latency_ms":     map[string]interface{}{"type": "number"},
+							"error":          map[string]interface{}{"type": "string"},
+						},
+						"required": []string{"url", "status", "latency_ms"},
+					},
+				},
+			},
+			"required": []string{"source_url", "checked", "ok", "redirected", "broken", "unchecked", "skipped", "results"},
+		},
+	}
+}
+
+// handleCheckLinks processes the check_links tool call
+func handleCheckLinks(id interface{}, args map[string]interface{}) *JSONRPCMessage {
+	pageURL, ok := args["url"].(string)
+	if !ok || pageURL == "" {
+		return newErrorResponse(id, -32602, "missing required parameter: url")
+	}
+	parsedURL, err := url.Parse(pageURL)
+	if err != nil {
+		return newErrorResponse(id, -32602, fmt.Sprintf("invalid URL format: %v", err))
+	}
+
+	concurrency := defaultCheckConcurrency
+	if v, ok := args["concurrency"].(float64); ok && v > 0 {
+		concurrency = min(int(v), maxCheckConcurrency)
+	}
+	maxLinks := defaultCheckMaxLinks
+	if v, ok := args["max_links"].(float64); ok && v > 0 {
+		maxLinks = int(v)
+	}
+	timeout := defaultCheckTimeout
+	if v, ok := args["timeout_seconds"].(float64); ok && v > 0 {
+		timeout = time.Duration(v * float64(time.Second))
+	}
+	onlyProblems, _ := args["only_problems"].(bool)
+
+	kinds := stringSliceArg(args, "link_kinds")
+	if len(kinds) == 0 {
+		kinds = []string{linkKindInternal, linkKindExternal}
+	}
+
+	log.Printf("Checking links on: %s", pageURL)
+
+	htmlContent, err := fetchWebContent(context.Background(), pageURL)
+	if err != nil {
+		return newErrorResponse(id, -1, fmt.Sprintf("Failed to fetch web content: %v", err))
+	}
+
+	links := filterLinks(extractLinks(htmlContent, parsedURL), linkFilter{
+		Kinds:   kinds,
+		Regions: stringSliceArg(args, "link_regions"),
+	})
+
+	skipped := 0
+	if len(links) > maxLinks {
+		skipped = len(links) - maxLinks
+		links = links[:maxLinks]
+	}
+
+	results := checkLinks(links, concurrency, timeout)
+
+	output := CheckLinksOutput{
+		SourceURL: pageURL,
+		Checked:   len(results),
+		Skipped:   skipped,
+		Results:   []LinkCheckResult{},
+	}
+	for _, r := range results {
+		switch r.Status {
+		case linkStatusOK:
+			output.OK++
+		case linkStatusRedirected:
+			output.Redirected++
+		case linkStatusBroken:
+			output.Broken++
+		case linkStatusUnchecked:
+			output.Unchecked++
+		}
+		if !onlyProblems || r.Status != linkStatusOK {
+			output.Results = append(output.Results, r)
+		}
+	}
+
+	return newToolResult(id, []interface{}{
+		TextContent{
+			Type: "text",
+			Text: formatLinkCheckReport(output),
+		},
+	}, output)
+}
+
+// checkLinks checks every link with a bounded pool of workers, keeping input order
+func checkLinks(links []LinkInfo, concurrency int, timeout time.Duration) []LinkCheckResult {
+	results := make([]LinkCheckResult, len(links))
+
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = checkLink(links[i], timeout)
+			}
+		}()
+	}
+
+	for i := range links {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// checkLink requests a single link with HEAD, retrying with GET when HEAD fails
+// or is rejected, since many servers do not implement HEAD correctly
+func checkLink(link LinkInfo, timeout time.Duration) LinkCheckResult {
+	result := LinkCheckResult{
+		URL:    link.URL,
+		Text:   truncateString(link.Text, 80),
+		Kind:   link.Kind,
+		Region: link.Region,
+	}
+
+	statusCode, finalURL, chain, latency, err := probeLink("HEAD", link.URL, timeout)
+	result.Method = "HEAD"
+	if errors.Is(err, errNoSlot) {
+		// Nothing was sent, so the link's health is unknown rather than broken
+		result.Status = linkStatusUnchecked
+		result.Error = err.Error()
+		return result
+	}
+	if err != nil || statusCode >= 400 {
+		statusCode, finalURL, chain, latency, err = probeLink("GET", link.URL, timeout)
+		result.Method = "GET"
+	}
+	result.LatencyMs = float64(latency.Microseconds()) / 1000.0
+
+	result.StatusCode = statusCode
+	result.FinalURL = finalURL
+	result.RedirectChain = chain
+
+	switch {
+	case errors.Is(err, errNoSlot):
+		result.Status = linkStatusUnchecked
+		result.Error = err.Error()
+	case err != nil:
+		result.Status = linkStatusBroken
+		result.Error = err.Error()
+	case statusCode >= 400:
+		result.Status = linkStatusBroken
+	case len(chain) > 0:
+		result.Status = linkStatusRedirected
+	default:
+		result.Status = linkStatusOK
+	}
+
+	return result
+}
+
+// probeLink issues one request, following redirects and recording each hop.
+// The latency excludes time spent waiting on the per-host rate limiter.
+func probeLink(method, linkURL string, timeout time.Duration) (int, string, []string, time.Duration, error) {
+	var chain []string
+
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: insecureTransport(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			chain = append(chain, via[len(via)-1].URL.String())
+			if len(via) >= maxCheckRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxCheckRedirects)
+			}
+			return nil
+		},
+	}
+
+	req, err := http.NewRequest(method, linkURL, nil)
+	if err != nil {
+		return 0, "", nil, 0, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	release, err := outboundLimiter.acquire(req, true)
+	if err != nil {
+		return 0, "", nil, 0, err
+	}
+	defer release()
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		return 0, "", chain, latency, err
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode, resp.Request.URL.String(), chain, latency, nil
+}
+
+// formatLinkCheckReport renders a human-readable summary of a link audit
+func formatLinkCheckReport(output CheckLinksOutput) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Link Check for %s\n\n", output.SourceURL)
+	fmt.Fprintf(&b, "- Checked: %d\n", output.Checked)
+	fmt.Fprintf(&b, "- OK: %d\n", output.OK)
+	fmt.Fprintf(&b, "- Redirected: %d\n", output.Redirected)
+	fmt.Fprintf(&b, "- Broken: %d\n", output.Broken)
+	if output.Unchecked > 0 {
+		fmt.Fprintf(&b, "- Not checked (rate limited): %d\n", output.Unchecked)
+	}
+	if output.Skipped > 0 {
+		fmt.Fprintf(&b, "- Not checked (over max_links): %d\n", output.Skipped)
+	}
+
+	for _, section := range []struct{ title, status string }{
+		{"Broken", linkStatusBroken},
+		{"Redirected", linkStatusRedirected},
+		{"Not checked (rate limited)", linkStatusUnchecked},
+	} {
+		header := false
+		for _, r := range output.Results {
+			if r.Status != section.status {
+				continue
+			}
+			if !header {
+				fmt.Fprintf(&b, "\n**%s:**\n", section.title)
+				header = true
+			}
+			fmt.Fprintf(&b, "- %s", r.URL)
+			if r.StatusCode > 0 {
+				fmt.Fprintf(&b, " (HTTP %d)", r.StatusCode)
+			}
+			if r.Error != "" {
+				fmt.Fprintf(&b, " - %s", r.Error)
+			}
+			if r.Status == linkStatusRedirected {
+				fmt.Fprintf(&b, " -> %s", r.FinalURL)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckLinks(t *testing.T) {
+	useTestLimiter(t)
+
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		switch r.URL.Path {
+		case "/ok":
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path     string
+		status   string
+		code     int
+		method   string
+		chain    int
+		hasError bool
+	}{
+		{path: "/ok", status: linkStatusOK, code: 200, method: "HEAD"},
+		{path: "/moved", status: linkStatusRedirected, code: 200, method: "HEAD", chain: 1},
+		{path: "/no-head", status: linkStatusOK, code: 200, method: "GET"},
+		{path: "/gone", status: linkStatusBroken, code: 404, method: "GET"},
+		{path: "/loop", status: linkStatusBroken, method: "GET", chain: maxCheckRedirects, hasError: true},
+		{path: "/ok?again", status: linkStatusOK, code: 200, method: "HEAD"},
+	}
+
+	links := make([]LinkInfo, len(tests))
+	for i, tt := range tests {
+		links[i] = LinkInfo{URL: server.URL + tt.path, Text: fmt.Sprintf("link %d", i), Kind: linkKindInternal}
+	}
+
+	results := checkLinks(links, 3, 5*time.Second)
+	if len(results) != len(tests) {
+		t.Fatalf("checkLinks() returned %d results, want %d", len(results), len(tests))
+	}
+	for i, tt := range tests {
+		r := results[i]
+		if r.URL != links[i].URL || r.Text != links[i].Text {
+			t.Errorf("result %d is for %q, want input order kept (%q)", i, r.URL, links[i].URL)
+		}
+		if r.Status != tt.status || r.StatusCode != tt.code || r.Method != tt.method || len(r.RedirectChain) != tt.chain || (r.Error != "") != tt.hasError {
+			t.Errorf("%s: got status %q, code %d, method %s, %d redirects, error %q; want %q, %d, %s, %d, error %v",
+				tt.path, r.Status, r.StatusCode, r.Method, len(r.RedirectChain), r.Error, tt.status, tt.code, tt.method, tt.chain, tt.hasError)
+		}
+	}
+	if results[1].FinalURL != server.URL+"/ok" {
+		t.Errorf("redirect final URL = %q, want %q", results[1].FinalURL, server.URL+"/ok")
+	}
+	if maxInFlight > 3 {
+		t.Errorf("%d requests in flight, want at most the 3 workers", maxInFlight)
+	}
+}
+
+func TestFormatLinkCheckReport(t *testing.T) {
+	output := CheckLinksOutput{
+		SourceURL:  "https://example.com/",
+		Checked:    4,
+		OK:         1,
+		Redirected: 1,
+		Broken:     1,
+		Unchecked:  1,
+		Skipped:    2,
+		Results: []LinkCheckResult{
+			{URL: "https://example.com/a", Status: linkStatusOK, StatusCode: 200},
+			{URL: "https://example.com/b", Status: linkStatusRedirected, StatusCode: 200, FinalURL: "https://example.com/c"},
+			{URL: "https://example.com/d", Status: linkStatusBroken, StatusCode: 404},
+			{URL: "https://slow.example/", Status: linkStatusUnchecked, Error: "rate limiter: no slot"},
+		},
+	}
+
+	report := formatLinkCheckReport(output)
+	for _, want := range []string{
+		"- Broken: 1\n",
+		"- Not checked (rate limited): 1\n",
+		"- Not checked (over max_links): 2\n",
+		"**Broken:**\n- https://example.com/d (HTTP 404)\n",
+		"**Redirected:**\n- https://example.com/b (HTTP 200) -> https://example.com/c\n",
+		"**Not checked (rate limited):**\n- https://slow.example/ - rate limiter: no slot\n",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report does not contain %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "https://example.com/a") {
+		t.Errorf("report lists an OK link:\n%s", report)
+	}
+}
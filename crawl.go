@@ -0,0 +1,588 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCrawlMaxDepth    = 2
+	defaultCrawlMaxPages    = 20
+	defaultCrawlConcurrency = 3
+	maxCrawlConcurrency     = 8
+	defaultCrawlDuration    = 5 * time.Minute
+	maxStoredCrawls         = 20
+)
+
+// Per-page crawl status reported in CrawlPage.Status
+const (
+	crawlPageOK    = "ok"
+	crawlPageError = "error"
+)
+
+// CrawlPage is one page visited by crawl_site
+type CrawlPage struct {
+	URL       string      `json:"url"`
+	Depth     int         `json:"depth"`
+	Status    string      `json:"status"` // ok or error
+	Title     string      `json:"title,omitempty"`
+	Markdown  string      `json:"markdown,omitempty"`
+	WordCount int         `json:"word_count"`
+	Links     []string    `json:"links,omitempty"` // in-scope links found on the page
+	Error     string      `json:"error,omitempty"`
+	Usage     *TokenUsage `json:"usage,omitempty"`
+}
+
+// CrawlSiteOutput is the structuredContent returned by crawl_site
+type CrawlSiteOutput struct {
+	CrawlID      string              `json:"crawl_id"`
+	SeedURL      string              `json:"seed_url"`
+	Pages        []CrawlPage         `json:"pages"`
+	PagesCrawled int                 `json:"pages_crawled"` // across all calls for this crawl ID
+	Queued       int                 `json:"queued"`
+	Complete     bool                `json:"complete"`
+	Graph        map[string][]string `json:"graph"`
+	Usage        *TokenUsage         `json:"usage,omitempty"` // AI tokens spent on the pages of this call
+}
+
+// crawlOptions are fixed when a crawl starts and reused when it is resumed
+type crawlOptions struct {
+	SameHost      bool
+	PathPrefix    string
+	Include       *regexp.Regexp
+	Exclude       *regexp.Regexp
+	MaxDepth      int
+	RespectRobots bool
+	MetadataOnly  bool
+	Model         string
+	MaxTokens     int
+}
+
+type crawlQueueItem struct {
+	URL   string
+	Depth int
+}
+
+// crawlState is everything needed to resume a crawl by its ID
+type crawlState struct {
+	mu sync.Mutex
+
+	ID       string
+	Seed     *url.URL
+	Options  crawlOptions
+	Visited  map[string]bool // queued or crawled, by normalized URL
+	Frontier []crawlQueueItem
+	Graph    map[string][]string
+	Crawled  int
+
+	lastUsed time.Time // guarded by crawlStore.mu, which picks crawls to evict by it
+
+	robotsMu sync.Mutex // crawl workers share the robots.txt cache
+	robots   map[string]*robotsRules
+}
+
+var crawlStore = struct {
+	mu     sync.Mutex
+	crawls map[string]*crawlState
+}{crawls: make(map[string]*crawlState)}
+
+// crawlSiteTool describes the crawl_site tool
+func crawlSiteTool() Tool {
+	return Tool{
+		Name:        "crawl_site",
+		Description: "Crawl a site from a seed URL, following links within a scope (same host, path prefix, include/exclude patterns) up to a depth and page limit. Returns Markdown for each page plus the link graph. Pass the returned crawl_id to resume and crawl the next batch of queued pages.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"url": map[string]interface{}{
+					"type":        "string",
+					"description": "Seed URL to start crawling from (required unless crawl_id is given)",
+				},
+				"crawl_id": map[string]interface{}{
+					"type":        "string",
+					"description": "Resume a previous crawl; its seed and scope options are reused",
+				},
+				"max_depth": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum link depth from the seed (default: 2)",
+				},
+				"max_pages": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum pages to crawl in this call (default: 20)",
+				},
+				"same_host": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Only follow links on the seed's host (default: true)",
+				},
+				"path_prefix": map[string]interface{}{
+					"type":        "string",
+					"description": "Only follow links whose path starts with this prefix, e.g. /docs/",
+				},
+				"include_pattern": map[string]interface{}{
+					"type":        "string",
+					"description": "Only follow URLs matching this regular expression",
+				},
+				"exclude_pattern": map[string]interface{}{
+					"type":        "string",
+					"description": "Never follow URLs matching this regular expression",
+				},
+				"respect_robots": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Skip URLs disallowed by robots.txt (default: true)",
+				},
+				"concurrency": map[string]interface{}{
+					"type":        "integer",
+					"description": "Pages fetched and converted in parallel (default: 3, max: 8)",
+				},
+				"max_duration_seconds": map[string]interface{}{
+					"type":        "number",
+					"description": "Stop this call after this long and leave the rest queued for resuming (default: 300)",
+				},
+				"metadata_only": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Skip the AI conversion and only collect titles and the link graph",
+				},
+				"model": map[string]interface{}{
+					"type":        "string",
+					"description": "AI model to use for conversion (default: " + defaultModel + ")",
+				},
+				"maxTokens": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum tokens per converted page (default: 4000)",
+				},
+			},
+		},
+		OutputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"crawl_id": map[string]interface{}{"type": "string"},
+				"seed_url": map[string]interface{}{"type": "string"},
+				"pages": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"url":        map[string]interface{}{"type": "string"},
+							"depth":      map[string]interface{}{"type": "integer"},
+							"status":     map[string]interface{}{"type": "string", "enum": []string{crawlPageOK, crawlPageError}},
+							"title":      map[string]interface{}{"type": "string"},
+							"markdown":   map[string]interface{}{"type": "string"},
+							"word_count": map[string]interface{}{"type": "integer"},
+							"links":      map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+							"error":      map[string]interface{}{"type": "string"},
+							"usage":      tokenUsageSchema,
+						},
+						"required": []string{"url", "depth", "status", "word_count"},
+					},
+				},
+				"pages_crawled": map[string]interface{}{"type": "integer"},
+				"queued":        map[string]interface{}{"type": "integer"},
+				"complete":      map[string]interface{}{"type": "boolean"},
+				"graph": map[string]interface{}{
+					"type":                 "object",
+					"additionalProperties": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				},
+				"usage": tokenUsageSchema,
+			},
+			"required": []string{"crawl_id", "seed_url", "pages", "pages_crawled", "queued", "complete", "graph"},
+		},
+	}
+}
+
+// handleCrawlSite processes the crawl_site tool call
+func handleCrawlSite(id interface{}, args map[string]interface{}, progressToken interface{}) *JSONRPCMessage {
+	maxPages := defaultCrawlMaxPages
+	if v, ok := args["max_pages"].(float64); ok && v > 0 {
+		maxPages = int(v)
+	}
+	concurrency := defaultCrawlConcurrency
+	if v, ok := args["concurrency"].(float64); ok && v > 0 {
+		concurrency = min(int(v), maxCrawlConcurrency)
+	}
+	maxDuration := defaultCrawlDuration
+	if v, ok := args["max_duration_seconds"].(float64); ok && v > 0 {
+		maxDuration = time.Duration(v * float64(time.Second))
+	}
+
+	var state *crawlState
+	if crawlID, ok := args["crawl_id"].(string); ok && crawlID != "" {
+		crawlStore.mu.Lock()
+		state = crawlStore.crawls[crawlID]
+		if state != nil {
+			state.lastUsed = time.Now()
+		}
+		crawlStore.mu.Unlock()
+		if state == nil {
+			return newErrorResponse(id, -32602, fmt.Sprintf("unknown crawl_id: %s", crawlID))
+		}
+		log.Printf("Resuming crawl %s (%d queued)", crawlID, len(state.Frontier))
+	} else {
+		var err error
+		state, err = newCrawlState(args)
+		if err != nil {
+			return newErrorResponse(id, -32602, err.Error())
+		}
+		log.Printf("Starting crawl %s from %s", state.ID, state.Seed)
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	defer touchCrawl(state)
+
+	pages := state.run(maxPages, concurrency, time.Now().Add(maxDuration), progressToken)
+
+	// The result is encoded after state.mu is released, when a call resuming
+	// the same crawl may already be adding to it, so it must not share its maps or slices
+	output := CrawlSiteOutput{
+		CrawlID:      state.ID,
+		SeedURL:      state.Seed.String(),
+		Pages:        pages,
+		PagesCrawled: state.Crawled,
+		Queued:       len(state.Frontier),
+		Complete:     len(state.Frontier) == 0,
+		Graph:        make(map[string][]string, len(state.Graph)),
+	}
+	for page, links := range state.Graph {
+		output.Graph[page] = append([]string{}, links...)
+	}
+	for i := range output.Pages {
+		if output.Pages[i].Links != nil {
+			output.Pages[i].Links = append([]string{}, output.Pages[i].Links...)
+		}
+	}
+	if output.Pages == nil {
+		output.Pages = []CrawlPage{}
+	}
+	var usage TokenUsage
+	for _, page := range output.Pages {
+		if page.Usage != nil {
+			usage.add(*page.Usage)
+		}
+	}
+	output.Usage = usage.report()
+
+	return newToolResult(id, []interface{}{
+		TextContent{
+			Type: "text",
+			Text: formatCrawlReport(output),
+		},
+	}, output)
+}
+
+// newCrawlState validates the arguments of a new crawl and registers it
+func newCrawlState(args map[string]interface{}) (*crawlState, error) {
+	seedStr, ok := args["url"].(string)
+	if !ok || seedStr == "" {
+		return nil, fmt.Errorf("missing required parameter: url")
+	}
+	seed, err := url.Parse(seedStr)
+	if err != nil || (seed.Scheme != "http" && seed.Scheme != "https") {
+		return nil, fmt.Errorf("invalid URL format: %s", seedStr)
+	}
+
+	opts := crawlOptions{
+		SameHost:      true,
+		MaxDepth:      defaultCrawlMaxDepth,
+		RespectRobots: true,
+		MaxTokens:     defaultMaxTokens,
+	}
+	if v, ok := args["same_host"].(bool); ok {
+		opts.SameHost = v
+	}
+	if v, ok := args["path_prefix"].(string); ok {
+		opts.PathPrefix = v
+	}
+	if v, ok := args["max_depth"].(float64); ok && v >= 0 {
+		opts.MaxDepth = int(v)
+	}
+	if v, ok := args["respect_robots"].(bool); ok {
+		opts.RespectRobots = v
+	}
+	if v, ok := args["metadata_only"].(bool); ok {
+		opts.MetadataOnly = v
+	}
+	if v, ok := args["model"].(string); ok {
+		opts.Model = v
+	}
+	if v, ok := args["maxTokens"].(float64); ok && v > 0 {
+		opts.MaxTokens = int(v)
+	}
+	if v, ok := args["include_pattern"].(string); ok && v != "" {
+		if opts.Include, err = regexp.Compile(v); err != nil {
+			return nil, fmt.Errorf("invalid include_pattern: %w", err)
+		}
+	}
+	if v, ok := args["exclude_pattern"].(string); ok && v != "" {
+		if opts.Exclude, err = regexp.Compile(v); err != nil {
+			return nil, fmt.Errorf("invalid exclude_pattern: %w", err)
+		}
+	}
+
+	state := &crawlState{
+		ID:       newCrawlID(),
+		Seed:     seed,
+		Options:  opts,
+		Visited:  make(map[string]bool),
+		Graph:    make(map[string][]string),
+		robots:   make(map[string]*robotsRules),
+		lastUsed: time.Now(),
+	}
+
+	if opts.RespectRobots && !state.robotsFor(seed).allowed(seed) {
+		return nil, fmt.Errorf("robots.txt disallows crawling %s", seedStr)
+	}
+
+	normalized := normalizeCrawlURL(seed)
+	state.Visited[normalized] = true
+	state.Frontier = []crawlQueueItem{{URL: normalized, Depth: 0}}
+
+	crawlStore.mu.Lock()
+	defer crawlStore.mu.Unlock()
+
+	// Forget the least recently used crawl once too many are stored
+	if len(crawlStore.crawls) >= maxStoredCrawls {
+		var oldest *crawlState
+		for _, c := range crawlStore.crawls {
+			if oldest == nil || c.lastUsed.Before(oldest.lastUsed) {
+				oldest = c
+			}
+		}
+		delete(crawlStore.crawls, oldest.ID)
+	}
+	crawlStore.crawls[state.ID] = state
+
+	return state, nil
+}
+
+// touchCrawl marks a crawl as just used so it is the last to be evicted
+func touchCrawl(s *crawlState) {
+	crawlStore.mu.Lock()
+	defer crawlStore.mu.Unlock()
+	s.lastUsed = time.Now()
+}
+
+// newCrawlID returns a random identifier for a crawl
+func newCrawlID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("crawl-%d", time.Now().UnixNano())
+	}
+	return "crawl-" + hex.EncodeToString(b)
+}
+
+// run crawls up to maxPages queued pages breadth-first, in parallel batches,
+// until the frontier is empty or the deadline passes. The caller holds s.mu.
+func (s *crawlState) run(maxPages, concurrency int, deadline time.Time, progressToken interface{}) []CrawlPage {
+	var pages []CrawlPage
+
+	for len(pages) < maxPages && len(s.Frontier) > 0 && time.Now().Before(deadline) {
+		n := min(concurrency, maxPages-len(pages), len(s.Frontier))
+		batch := s.Frontier[:n]
+		s.Frontier = s.Frontier[n:]
+
+		results := make([]CrawlPage, len(batch))
+		found := make([][]string, len(batch))
+
+		var wg sync.WaitGroup
+		for i, item := range batch {
+			wg.Add(1)
+			go func(i int, item crawlQueueItem) {
+				defer wg.Done()
+				results[i], found[i] = s.crawlPage(item)
+			}(i, item)
+		}
+		wg.Wait()
+
+		for i, page := range results {
+			pages = append(pages, page)
+			s.Crawled++
+			if page.Links != nil {
+				s.Graph[page.URL] = page.Links
+			} else {
+				s.Graph[page.URL] = []string{}
+			}
+
+			if batch[i].Depth < s.Options.MaxDepth {
+				for _, link := range found[i] {
+					if s.Visited[link] {
+						continue
+					}
+					s.Visited[link] = true
+					s.Frontier = append(s.Frontier, crawlQueueItem{URL: link, Depth: batch[i].Depth + 1})
+				}
+			}
+
+			sendProgress(progressToken, float64(len(pages)), float64(maxPages), fmt.Sprintf("Crawled %s", page.URL))
+		}
+	}
+
+	return pages
+}
+
+// crawlPage fetches and converts one page and returns it with the in-scope links to follow
+func (s *crawlState) crawlPage(item crawlQueueItem) (CrawlPage, []string) {
+	page := CrawlPage{
+		URL:   item.URL,
+		Depth: item.Depth,
+	}
+
+	log.Printf("Crawling [depth %d]: %s", item.Depth, item.URL)
+
+	htmlContent, err := fetchWebContent(context.Background(), item.URL)
+	if err != nil {
+		page.Status = crawlPageError
+		page.Error = err.Error()
+		return page, nil
+	}
+
+	pageURL, _ := url.Parse(item.URL)
+	page.Title = extractPageMetadata(htmlContent, pageURL).Title
+
+	var follow []string
+	seen := make(map[string]bool)
+	for _, link := range extractLinks(htmlContent, pageURL) {
+		linkURL, err := url.Parse(link.URL)
+		if err != nil || link.Kind == linkKindSamePage || !s.inScope(linkURL) {
+			continue
+		}
+		normalized := normalizeCrawlURL(linkURL)
+		if seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		follow = append(follow, normalized)
+	}
+	page.Links = follow
+
+	if !s.Options.MetadataOnly {
+		markdown, usage, err := convertToMarkdown(context.Background(), htmlContent, "", s.Options.Model, s.Options.MaxTokens, 0)
+		page.Usage = usage.report()
+		if err != nil {
+			page.Status = crawlPageError
+			page.Error = fmt.Sprintf("Failed to convert content: %v", err)
+			return page, follow
+		}
+		page.Markdown = markdown
+		page.WordCount = len(strings.Fields(markdown))
+		pageResources.add(item.URL, page.Title, markdown, time.Now())
+	}
+
+	page.Status = crawlPageOK
+	return page, follow
+}
+
+// inScope reports whether a link should be followed under the crawl's scope rules
+func (s *crawlState) inScope(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	if s.Options.SameHost && !strings.EqualFold(u.Hostname(), s.Seed.Hostname()) {
+		return false
+	}
+	if s.Options.PathPrefix != "" && !strings.HasPrefix(u.Path, s.Options.PathPrefix) {
+		return false
+	}
+	// Skip downloads and media, only HTML pages are crawled
+	if linkFileType(u) != "" {
+		return false
+	}
+	full := u.String()
+	if s.Options.Include != nil && !s.Options.Include.MatchString(full) {
+		return false
+	}
+	if s.Options.Exclude != nil && s.Options.Exclude.MatchString(full) {
+		return false
+	}
+	if s.Options.RespectRobots && !s.robotsFor(u).allowed(u) {
+		return false
+	}
+	return true
+}
+
+// robotsFor returns the cached robots.txt rules for a link's host
+func (s *crawlState) robotsFor(u *url.URL) *robotsRules {
+	key := strings.ToLower(u.Scheme + "://" + u.Host)
+
+	s.robotsMu.Lock()
+	defer s.robotsMu.Unlock()
+
+	if rules, ok := s.robots[key]; ok {
+		return rules
+	}
+
+	var rules *robotsRules
+	if robots, err := fetchRobotsTxt(u); err == nil {
+		rules = parseRobotsRules(robots)
+	}
+	s.robots[key] = rules
+	return rules
+}
+
+// normalizeCrawlURL drops the fragment, default ports and host case so each page is visited once
+func normalizeCrawlURL(u *url.URL) string {
+	n := *u
+	n.Fragment = ""
+	n.RawFragment = ""
+	n.Scheme = strings.ToLower(n.Scheme)
+	n.Host = strings.ToLower(n.Host)
+	if (n.Scheme == "http" && strings.HasSuffix(n.Host, ":80")) || (n.Scheme == "https" && strings.HasSuffix(n.Host, ":443")) {
+		n.Host = n.Host[:strings.LastIndex(n.Host, ":")]
+	}
+	if n.Path == "" {
+		n.Path = "/"
+	}
+	return n.String()
+}
+
+// formatCrawlReport renders the crawled pages as one Markdown document
+func formatCrawlReport(output CrawlSiteOutput) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Crawl of %s\n\n", output.SeedURL)
+	fmt.Fprintf(&b, "- Crawl ID: %s\n", output.CrawlID)
+	fmt.Fprintf(&b, "- Pages in this call: %d\n", len(output.Pages))
+	fmt.Fprintf(&b, "- Pages crawled in total: %d\n", output.PagesCrawled)
+	if output.Complete {
+		b.WriteString("- Status: complete\n")
+	} else {
+		fmt.Fprintf(&b, "- Status: %d pages queued, call crawl_site again with this crawl_id to continue\n", output.Queued)
+	}
+	b.WriteString(formatTokenUsage(output.Usage))
+
+	for _, page := range output.Pages {
+		title := page.Title
+		if title == "" {
+			title = page.URL
+		}
+		fmt.Fprintf(&b, "\n---\n\n## %s\n\n- URL: %s\n- Depth: %d\n", title, page.URL, page.Depth)
+		if page.Error != "" {
+			fmt.Fprintf(&b, "- Error: %s\n", page.Error)
+		}
+		if page.Markdown != "" {
+			fmt.Fprintf(&b, "\n%s\n", page.Markdown)
+		}
+	}
+
+	if len(output.Graph) > 0 {
+		b.WriteString("\n---\n\n**Link graph:**\n")
+		sources := make([]string, 0, len(output.Graph))
+		for src := range output.Graph {
+			sources = append(sources, src)
+		}
+		sort.Strings(sources)
+		for _, src := range sources {
+			fmt.Fprintf(&b, "- %s -> %d links\n", src, len(output.Graph[src]))
+		}
+	}
+
+	return b.String()
+}
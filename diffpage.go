@@ -0,0 +1,565 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDiffContext = 3
+	// diffTemperature keeps repeated conversions of an unchanged page close, so
+	// diffs show page changes rather than rewording by the model
+	diffTemperature = 0.1
+	// maxDiffCells bounds the line-matching table; larger changes are reported as a block replacement
+	maxDiffCells = 4_000_000
+)
+
+// Diff formats accepted by diff_page
+const (
+	diffFormatUnified  = "unified"
+	diffFormatSections = "sections"
+)
+
+// Section changes reported in SectionDiff.Change
+const (
+	sectionAdded   = "added"
+	sectionRemoved = "removed"
+	sectionChanged = "changed"
+)
+
+var markdownHeadingRegex = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+
+// SnapshotInfo lists a stored snapshot without its content
+type SnapshotInfo struct {
+	ID        string `json:"id"`
+	FetchedAt string `json:"fetched_at"`
+}
+
+// DiffSummary counts what changed between two snapshots
+type DiffSummary struct {
+	AddedHeadings   []string `json:"added_headings"`
+	RemovedHeadings []string `json:"removed_headings"`
+	ChangedHeadings []string `json:"changed_headings"`
+	LinesAdded      int      `json:"lines_added"`
+	LinesRemoved    int      `json:"lines_removed"`
+}
+
+// SectionDiff is the change to one heading's section
+type SectionDiff struct {
+	Heading string `json:"heading"` // heading path, e.g. "API > Authentication"
+	Change  string `json:"change"`  // added, removed or changed
+	Diff    string `json:"diff"`
+}
+
+// DiffPageOutput is the structuredContent returned by diff_page
+type DiffPageOutput struct {
+	URL                string         `json:"url"`
+	SnapshotID         string         `json:"snapshot_id"`
+	FetchedAt          string         `json:"fetched_at"`
+	PreviousSnapshotID string         `json:"previous_snapshot_id,omitempty"`
+	PreviousFetchedAt  string         `json:"previous_fetched_at,omitempty"`
+	FirstSnapshot      bool           `json:"first_snapshot"`
+	Changed            bool           `json:"changed"`
+	Format             string         `json:"format"`
+	Summary            DiffSummary    `json:"summary"`
+	Diff               string         `json:"diff,omitempty"`
+	Sections           []SectionDiff  `json:"sections,omitempty"`
+	Snapshots          []SnapshotInfo `json:"snapshots"`
+	Usage              *TokenUsage    `json:"usage,omitempty"`
+}
+
+// diffOp is one line of a line diff: ' ' kept, '-' removed, '+' added
+type diffOp struct {
+	Kind byte
+	Line string
+}
+
+// markdownSection is the text under one heading, keyed by its heading path
+type markdownSection struct {
+	Heading string // heading path, with a " (n)" counter when repeated
+	Title   string // the section's own heading as written
+	Body    []string
+}
+
+// diffPageTool describes the diff_page tool
+func diffPageTool() Tool {
+	return Tool{
+		Name:        "diff_page",
+		Description: "Detect changes to a web page. Reads the page to Markdown like web_reader, stores it as a snapshot and diffs it against the previous snapshot (or a given snapshot ID) as a unified or section-by-section diff, summarising added, removed and changed headings.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"url": map[string]interface{}{
+					"type":        "string",
+					"description": "The page to check for changes",
+				},
+				"compare_to": map[string]interface{}{
+					"type":        "string",
+					"description": "Snapshot ID to diff against (default: the latest stored snapshot)",
+				},
+				"format": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{diffFormatUnified, diffFormatSections},
+					"description": "unified for one diff of the whole page, sections for a diff per heading (default: unified)",
+				},
+				"context_lines": map[string]interface{}{
+					"type":        "integer",
+					"description": "Unchanged lines shown around each change (default: 3)",
+				},
+				"model": map[string]interface{}{
+					"type":        "string",
+					"description": "AI model to use for conversion (default: " + defaultModel + ")",
+				},
+				"maxTokens": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum tokens in response (default: 4000)",
+				},
+				"temperature": map[string]interface{}{
+					"type":        "number",
+					"description": "AI temperature 0-1 (default: 0.1, kept low so unchanged pages convert alike)",
+				},
+			},
+			"required": []string{"url"},
+		},
+		OutputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"url":                  map[string]interface{}{"type": "string"},
+				"snapshot_id":          map[string]interface{}{"type": "string"},
+				"fetched_at":           map[string]interface{}{"type": "string", "format": "date-time"},
+				"previous_snapshot_id": map[string]interface{}{"type": "string"},
+				"previous_fetched_at":  map[string]interface{}{"type": "string", "format": "date-time"},
+				"first_snapshot":       map[string]interface{}{"type": "boolean"},
+				"changed":              map[string]interface{}{"type": "boolean"},
+				"format":               map[string]interface{}{"type": "string", "enum": []string{diffFormatUnified, diffFormatSections}},
+				"summary": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"added_headings":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+						"removed_headings": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+						"changed_headings": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+						"lines_added":      map[string]interface{}{"type": "integer"},
+						"lines_removed":    map[string]interface{}{"type": "integer"},
+					},
+				},
+				"diff": map[string]interface{}{"type": "string"},
+				"sections": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"heading": map[string]interface{}{"type": "string"},
+							"change":  map[string]interface{}{"type": "string", "enum": []string{sectionAdded, sectionRemoved, sectionChanged}},
+							"diff":    map[string]interface{}{"type": "string"},
+						},
+					},
+				},
+				"snapshots": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"id":         map[string]interface{}{"type": "string"},
+							"fetched_at": map[string]interface{}{"type": "string", "format": "date-time"},
+						},
+					},
+				},
+				"usage": tokenUsageSchema,
+			},
+			"required": []string{"url", "snapshot_id", "fetched_at", "first_snapshot", "changed", "format", "summary", "snapshots"},
+		},
+	}
+}
+
+// handleDiffPage processes the diff_page tool call
+func handleDiffPage(id interface{}, args map[string]interface{}) *JSONRPCMessage {
+	input, err := parseWebReaderInput(args)
+	if err != nil {
+		return newErrorResponse(id, -32602, err.Error())
+	}
+	if _, ok := args["temperature"]; !ok {
+		input.Temperature = diffTemperature
+	}
+	parsedURL, err := url.Parse(input.URL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		return newErrorResponse(id, -32602, fmt.Sprintf("invalid URL format: %s", input.URL))
+	}
+	pageURL := normalizeCrawlURL(parsedURL)
+
+	format := diffFormatUnified
+	if v, ok := args["format"].(string); ok && v != "" {
+		if v != diffFormatUnified && v != diffFormatSections {
+			return newErrorResponse(id, -32602, fmt.Sprintf("invalid format: %s", v))
+		}
+		format = v
+	}
+	contextLines := defaultDiffContext
+	if v, ok := args["context_lines"].(float64); ok && v >= 0 {
+		contextLines = int(v)
+	}
+
+	// Resolve the baseline before storing the new snapshot
+	var previous *PageSnapshot
+	if compareTo, ok := args["compare_to"].(string); ok && compareTo != "" {
+		if previous = snapshotStore.find(pageURL, compareTo); previous == nil {
+			return newErrorResponse(id, -32602, fmt.Sprintf("unknown snapshot %s for %s", compareTo, pageURL))
+		}
+	} else if history := snapshotStore.list(pageURL); len(history) > 0 {
+		previous = history[len(history)-1]
+	}
+
+	page, rpcErr := readWebPage(context.Background(), input)
+	if rpcErr != nil {
+		return &JSONRPCMessage{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error:   rpcErr,
+		}
+	}
+
+	current, _ := snapshotStore.add(pageURL, page.Markdown, page.FetchedAt)
+
+	output := DiffPageOutput{
+		URL:           pageURL,
+		SnapshotID:    current.ID,
+		FetchedAt:     current.FetchedAt.Format(time.RFC3339),
+		FirstSnapshot: previous == nil,
+		Format:        format,
+		Summary: DiffSummary{
+			AddedHeadings:   []string{},
+			RemovedHeadings: []string{},
+			ChangedHeadings: []string{},
+		},
+		Snapshots: []SnapshotInfo{},
+		Usage:     page.Usage.report(),
+	}
+	for _, snap := range snapshotStore.list(pageURL) {
+		output.Snapshots = append(output.Snapshots, SnapshotInfo{ID: snap.ID, FetchedAt: snap.FetchedAt.Format(time.RFC3339)})
+	}
+
+	if previous != nil {
+		output.PreviousSnapshotID = previous.ID
+		output.PreviousFetchedAt = previous.FetchedAt.Format(time.RFC3339)
+		output.Changed = previous.Hash != current.Hash
+
+		log.Printf("Diffing %s against snapshot %s", pageURL, previous.ID)
+
+		oldLines, newLines := splitLines(previous.Markdown), splitLines(page.Markdown)
+		ops := diffLines(oldLines, newLines)
+		for _, op := range ops {
+			switch op.Kind {
+			case '+':
+				output.Summary.LinesAdded++
+			case '-':
+				output.Summary.LinesRemoved++
+			}
+		}
+
+		sections := diffSections(parseMarkdownSections(oldLines), parseMarkdownSections(newLines), contextLines)
+		for _, s := range sections {
+			switch s.Change {
+			case sectionAdded:
+				output.Summary.AddedHeadings = append(output.Summary.AddedHeadings, s.Heading)
+			case sectionRemoved:
+				output.Summary.RemovedHeadings = append(output.Summary.RemovedHeadings, s.Heading)
+			case sectionChanged:
+				output.Summary.ChangedHeadings = append(output.Summary.ChangedHeadings, s.Heading)
+			}
+		}
+
+		if format == diffFormatSections {
+			output.Sections = sections
+		} else if output.Changed {
+			output.Diff = fmt.Sprintf("--- %s\t%s\n+++ %s\t%s\n", previous.ID, output.PreviousFetchedAt, current.ID, output.FetchedAt) +
+				unifiedDiff(ops, contextLines)
+		}
+	}
+
+	return newToolResult(id, []interface{}{
+		TextContent{
+			Type: "text",
+			Text: formatDiffReport(output),
+		},
+	}, output)
+}
+
+// splitLines splits Markdown into lines, ignoring a trailing newline
+func splitLines(s string) []string {
+	s = strings.TrimRight(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// diffLines computes a line diff from the longest common subsequence. Common
+// leading and trailing lines are matched first to keep the table small.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(midA), len(midB)
+	if n*m > maxDiffCells {
+		for _, line := range midA {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range midB {
+			ops = append(ops, diffOp{'+', line})
+		}
+	} else {
+		// lcs[i][j] is the LCS length of midA[i:] and midB[j:]
+		lcs := make([][]int32, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int32, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < n && j < m {
+			switch {
+			case midA[i] == midB[j]:
+				ops = append(ops, diffOp{' ', midA[i]})
+				i++
+				j++
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				ops = append(ops, diffOp{'-', midA[i]})
+				i++
+			default:
+				ops = append(ops, diffOp{'+', midB[j]})
+				j++
+			}
+		}
+		for ; i < n; i++ {
+			ops = append(ops, diffOp{'-', midA[i]})
+		}
+		for ; j < m; j++ {
+			ops = append(ops, diffOp{'+', midB[j]})
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// unifiedDiff renders diff operations as unified diff hunks with context lines
+func unifiedDiff(ops []diffOp, context int) string {
+	var b strings.Builder
+
+	// Line numbers in the old and new text before each op
+	oldLine := make([]int, len(ops)+1)
+	newLine := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if op.Kind != '+' {
+			oldLine[i+1]++
+		}
+		if op.Kind != '-' {
+			newLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == ' ' {
+			i++
+			continue
+		}
+
+		// Extend the hunk while the next change is within two contexts
+		start := max(0, i-context)
+		end := i
+		for k := i; k < len(ops); k++ {
+			if ops[k].Kind != ' ' {
+				end = k
+			} else if k-end > 2*context {
+				break
+			}
+		}
+		end = min(len(ops), end+context+1)
+
+		oldCount := oldLine[end] - oldLine[start]
+		newCount := newLine[end] - newLine[start]
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(oldLine[start], oldCount), hunkRange(newLine[start], newCount))
+		for _, op := range ops[start:end] {
+			b.WriteByte(op.Kind)
+			b.WriteString(op.Line)
+			b.WriteByte('\n')
+		}
+		i = end
+	}
+
+	return b.String()
+}
+
+// hunkRange formats a unified diff range; empty ranges name the line before them
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// parseMarkdownSections splits Markdown into sections keyed by heading path.
+// Text before the first heading belongs to an unnamed section; headings inside
+// fenced code blocks are ignored.
+func parseMarkdownSections(lines []string) []markdownSection {
+	sections := []markdownSection{{}}
+	var path [6]string
+	seen := make(map[string]int)
+	inFence := false
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+		if match := markdownHeadingRegex.FindStringSubmatch(line); match != nil && !inFence {
+			level := len(match[1])
+			path[level-1] = match[2]
+			for k := level; k < len(path); k++ {
+				path[k] = ""
+			}
+
+			var parts []string
+			for _, p := range path[:level] {
+				if p != "" {
+					parts = append(parts, p)
+				}
+			}
+			heading := strings.Join(parts, " > ")
+
+			// Repeated headings get a counter so each section keeps its own key
+			seen[heading]++
+			if seen[heading] > 1 {
+				heading = fmt.Sprintf("%s (%d)", heading, seen[heading])
+			}
+			sections = append(sections, markdownSection{Heading: heading, Title: match[2]})
+		}
+		sections[len(sections)-1].Body = append(sections[len(sections)-1].Body, line)
+	}
+
+	if len(sections[0].Body) == 0 {
+		sections = sections[1:]
+	}
+	return sections
+}
+
+// diffSections matches sections by heading path and diffs the ones that differ,
+// listing them in the order of the new page followed by removed sections
+func diffSections(oldSections, newSections []markdownSection, context int) []SectionDiff {
+	oldByHeading := make(map[string]markdownSection, len(oldSections))
+	for _, s := range oldSections {
+		oldByHeading[s.Heading] = s
+	}
+	newHeadings := make(map[string]bool, len(newSections))
+
+	var diffs []SectionDiff
+	for _, s := range newSections {
+		newHeadings[s.Heading] = true
+		old, ok := oldByHeading[s.Heading]
+		switch {
+		case !ok:
+			diffs = append(diffs, SectionDiff{
+				Heading: sectionTitle(s.Heading),
+				Change:  sectionAdded,
+				Diff:    unifiedDiff(diffLines(nil, s.Body), context),
+			})
+		case strings.Join(old.Body, "\n") != strings.Join(s.Body, "\n"):
+			diffs = append(diffs, SectionDiff{
+				Heading: sectionTitle(s.Heading),
+				Change:  sectionChanged,
+				Diff:    unifiedDiff(diffLines(old.Body, s.Body), context),
+			})
+		}
+	}
+	for _, s := range oldSections {
+		if !newHeadings[s.Heading] {
+			diffs = append(diffs, SectionDiff{
+				Heading: sectionTitle(s.Heading),
+				Change:  sectionRemoved,
+				Diff:    unifiedDiff(diffLines(s.Body, nil), context),
+			})
+		}
+	}
+	return diffs
+}
+
+// sectionTitle names the unnamed section before the first heading
+func sectionTitle(heading string) string {
+	if heading == "" {
+		return "(top of page)"
+	}
+	return heading
+}
+
+// formatDiffReport renders the change summary and diff as Markdown
+func formatDiffReport(output DiffPageOutput) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Changes to %s\n\n", output.URL)
+	fmt.Fprintf(&b, "- Snapshot: %s (%s)\n", output.SnapshotID, output.FetchedAt)
+	b.WriteString(formatTokenUsage(output.Usage))
+
+	if output.FirstSnapshot {
+		b.WriteString("- First snapshot stored; call diff_page again later to see changes.\n")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "- Compared to: %s (%s)\n", output.PreviousSnapshotID, output.PreviousFetchedAt)
+	if !output.Changed {
+		b.WriteString("- No changes.\n")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "- Lines added: %d, removed: %d\n", output.Summary.LinesAdded, output.Summary.LinesRemoved)
+	for _, group := range []struct {
+		label    string
+		headings []string
+	}{
+		{"Added sections", output.Summary.AddedHeadings},
+		{"Removed sections", output.Summary.RemovedHeadings},
+		{"Changed sections", output.Summary.ChangedHeadings},
+	} {
+		if len(group.headings) > 0 {
+			fmt.Fprintf(&b, "- %s: %s\n", group.label, strings.Join(group.headings, "; "))
+		}
+	}
+
+	if output.Diff != "" {
+		fmt.Fprintf(&b, "\n```diff\n%s```\n", output.Diff)
+	}
+	for _, s := range output.Sections {
+		fmt.Fprintf(&b, "\n## %s (%s)\n\n```diff\n%s```\n", s.Heading, s.Change, s.Diff)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// renderOps writes diff operations as "<kind><line>" strings for comparison
+func renderOps(ops []diffOp) []string {
+	var lines []string
+	for _, op := range ops {
+		lines = append(lines, string(op.Kind)+op.Line)
+	}
+	return lines
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{
+			name: "both empty",
+			want: nil,
+		},
+		{
+			name: "identical",
+			a:    "a\nb",
+			b:    "a\nb",
+			want: []string{" a", " b"},
+		},
+		{
+			name: "all added",
+			b:    "a\nb",
+			want: []string{"+a", "+b"},
+		},
+		{
+			name: "all removed",
+			a:    "a\nb",
+			want: []string{"-a", "-b"},
+		},
+		{
+			name: "changed middle line",
+			a:    "head\nold\ntail",
+			b:    "head\nnew\ntail",
+			want: []string{" head", "-old", "+new", " tail"},
+		},
+		{
+			name: "insertion",
+			a:    "a\nc",
+			b:    "a\nb\nc",
+			want: []string{" a", "+b", " c"},
+		},
+		{
+			name: "deletion",
+			a:    "a\nb\nc",
+			b:    "a\nc",
+			want: []string{" a", "-b", " c"},
+		},
+		{
+			name: "moved line keeps the longest common subsequence",
+			a:    "x\na\nb\nc",
+			b:    "a\nb\nc\nx",
+			want: []string{"-x", " a", " b", " c", "+x"},
+		},
+		{
+			name: "interleaved changes",
+			a:    "1\n2\n3\n4\n5",
+			b:    "1\ntwo\n3\nfour\n5",
+			want: []string{" 1", "-2", "+two", " 3", "-4", "+four", " 5"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := renderOps(diffLines(splitLines(tt.a), splitLines(tt.b)))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesReconstructsBothSides(t *testing.T) {
+	a := splitLines("# Title\n\nIntro\n\n## One\n\nalpha\nbeta\n\n## Two\n\ngamma\n")
+	b := splitLines("# Title\n\nNew intro\n\n## Two\n\ngamma\ndelta\n\n## Three\n\nalpha\n")
+
+	var oldSide, newSide []string
+	for _, op := range diffLines(a, b) {
+		if op.Kind != '+' {
+			oldSide = append(oldSide, op.Line)
+		}
+		if op.Kind != '-' {
+			newSide = append(newSide, op.Line)
+		}
+	}
+	if !reflect.DeepEqual(oldSide, a) {
+		t.Errorf("old side = %q, want %q", oldSide, a)
+	}
+	if !reflect.DeepEqual(newSide, b) {
+		t.Errorf("new side = %q, want %q", newSide, b)
+	}
+}
+
+func TestDiffLinesFallsBackOnLargeInputs(t *testing.T) {
+	// Past maxDiffCells the middle is reported as removed then added
+	var a, b []string
+	for i := 0; i*i <= maxDiffCells; i++ {
+		a = append(a, "old "+strings.Repeat("x", i%7))
+		b = append(b, "new "+strings.Repeat("x", i%7))
+	}
+	ops := diffLines(a, b)
+	if len(ops) != len(a)+len(b) {
+		t.Fatalf("got %d ops, want %d", len(ops), len(a)+len(b))
+	}
+	for i, op := range ops {
+		want := byte('-')
+		if i >= len(a) {
+			want = '+'
+		}
+		if op.Kind != want {
+			t.Fatalf("op %d kind = %q, want %q", i, op.Kind, want)
+		}
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"\n\n", nil},
+		{"one", []string{"one"}},
+		{"one\r\ntwo\n", []string{"one", "two"}},
+		{"one\n\nthree", []string{"one", "", "three"}},
+	}
+	for _, tt := range tests {
+		if got := splitLines(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitLines(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
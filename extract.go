@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultExtractRetries = 2
+	maxExtractRetries     = 5
+	// extractTemperature keeps the model close to the page instead of inventing values
+	extractTemperature = 0.1
+	// maxExtractInputBytes bounds the page HTML sent to the model
+	maxExtractInputBytes = 200 * 1024
+	// extractNoiseSelector removes elements that cost tokens but never hold data
+	extractNoiseSelector = "script, style, noscript, svg, template, iframe"
+)
+
+// extractStructuredTool describes the extract_structured tool
+func extractStructuredTool() Tool {
+	return Tool{
+		Name:        "extract_structured",
+		Description: "Extract typed data from a web page. Give a JSON Schema describing the object you want (e.g. product price, release version, API parameters); the model fills it from the page, the result is validated against the schema and retried on failure, and the validated object is returned as structuredContent.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"url": map[string]interface{}{
+					"type":        "string",
+					"description": "The page to extract data from",
+				},
+				"schema": map[string]interface{}{
+					"type":        "object",
+					"description": "JSON Schema of the object to extract; the top-level type must be \"object\"",
+				},
+				"instructions": map[string]interface{}{
+					"type":        "string",
+					"description": "Extra guidance for the model, e.g. which table or section to use",
+				},
+				"include_selector": map[string]interface{}{
+					"type":        "string",
+					"description": "CSS selector for the parts of the page to extract from",
+				},
+				"exclude_selector": map[string]interface{}{
+					"type":        "string",
+					"description": "CSS selector for elements to ignore",
+				},
+				"max_retries": map[string]interface{}{
+					"type":        "integer",
+					"description": "Attempts to correct an answer that fails validation (default: 2, max: 5)",
+				},
+				"model": map[string]interface{}{
+					"type":        "string",
+					"description": "AI model to use for extraction (default: " + defaultModel + ")",
+				},
+				"maxTokens": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum tokens in response (default: 4000)",
+				},
+				"temperature": map[string]interface{}{
+					"type":        "number",
+					"description": "AI temperature 0-1 (default: 0.1)",
+				},
+			},
+			"required": []string{"url", "schema"},
+		},
+	}
+}
+
+// handleExtractStructured processes the extract_structured tool call
+func handleExtractStructured(id interface{}, args map[string]interface{}) *JSONRPCMessage {
+	pageURL, ok := args["url"].(string)
+	if !ok || pageURL == "" {
+		return newErrorResponse(id, -32602, "missing required parameter: url")
+	}
+	if _, err := url.Parse(pageURL); err != nil {
+		return newErrorResponse(id, -32602, fmt.Sprintf("invalid URL format: %v", err))
+	}
+	schema, ok := args["schema"].(map[string]interface{})
+	if !ok {
+		return newErrorResponse(id, -32602, "missing required parameter: schema")
+	}
+	if t, _ := schema["type"].(string); t != "object" {
+		return newErrorResponse(id, -32602, "schema must describe an object (\"type\": \"object\")")
+	}
+
+	instructions, _ := args["instructions"].(string)
+	include, _ := args["include_selector"].(string)
+	exclude, _ := args["exclude_selector"].(string)
+	for _, sel := range []string{include, exclude} {
+		if _, err := parseSelector(sel); sel != "" && err != nil {
+			return newErrorResponse(id, -32602, err.Error())
+		}
+	}
+	retries := defaultExtractRetries
+	if v, ok := args["max_retries"].(float64); ok && v >= 0 {
+		retries = min(int(v), maxExtractRetries)
+	}
+	model, _ := args["model"].(string)
+	maxTokens := 0
+	if v, ok := args["maxTokens"].(float64); ok {
+		maxTokens = int(v)
+	}
+	temperature := extractTemperature
+	if v, ok := args["temperature"].(float64); ok {
+		temperature = v
+	}
+
+	log.Printf("Extracting structured data from: %s", pageURL)
+
+	htmlContent, err := fetchWebContent(context.Background(), pageURL)
+	if err != nil {
+		return newErrorResponse(id, -1, fmt.Sprintf("Failed to fetch web content: %v", err))
+	}
+	if htmlContent, err = scopeHTML(htmlContent, "", extractNoiseSelector); err == nil {
+		htmlContent, err = scopeHTML(htmlContent, include, exclude)
+	}
+	if err != nil {
+		return newErrorResponse(id, -2, fmt.Sprintf("Failed to apply selectors: %v", err))
+	}
+	htmlContent = truncateHTML(htmlContent, maxExtractInputBytes)
+
+	schemaJSON, _ := json.MarshalIndent(schema, "", "  ")
+	prompt := fmt.Sprintf("JSON Schema:\n%s\n\nPage URL: %s\n\n", schemaJSON, pageURL)
+	if instructions != "" {
+		prompt += "Instructions: " + instructions + "\n\n"
+	}
+	prompt += "Page HTML:\n" + htmlContent
+
+	messages := []AIMessage{
+		{
+			Role: "system",
+			Content: "You extract structured data from web pages. " +
+				"Reply with a single JSON object that conforms to the JSON Schema you are given. " +
+				"Use only information present on the page; use null for values the page does not provide when the schema allows it, and never invent values. " +
+				"Return ONLY the JSON object, without Markdown code fences or explanations.",
+		},
+		{
+			Role:    "user",
+			Content: prompt,
+		},
+	}
+
+	var errs []string
+	var usage TokenUsage
+	for attempt := 0; attempt <= retries; attempt++ {
+		reply, replyUsage, err := callAI(context.Background(), messages, model, maxTokens, temperature)
+		usage.add(replyUsage)
+		if err != nil {
+			return newErrorResponse(id, -2, fmt.Sprintf("Failed to extract data: %v", err))
+		}
+
+		value, err := parseJSONReply(reply)
+		if err != nil {
+			errs = []string{fmt.Sprintf("reply is not valid JSON: %v", err)}
+		} else {
+			errs = validateJSONSchema(schema, value, "")
+		}
+		if len(errs) == 0 {
+			// structuredContent must match the caller's schema, so usage is only reported in the text
+			pretty, _ := json.MarshalIndent(value, "", "  ")
+			return newToolResult(id, []interface{}{
+				TextContent{
+					Type: "text",
+					Text: string(pretty),
+				},
+				TextContent{
+					Type: "text",
+					Text: "---\n" + formatTokenUsage(usage.report()),
+				},
+			}, value)
+		}
+
+		log.Printf("Extraction attempt %d for %s failed validation: %s", attempt+1, pageURL, strings.Join(errs, "; "))
+		messages = append(messages,
+			AIMessage{Role: "assistant", Content: reply},
+			AIMessage{Role: "user", Content: "Your answer does not conform to the schema:\n- " + strings.Join(errs, "\n- ") +
+				"\n\nReply again with the corrected JSON object only."},
+		)
+	}
+
+	return newErrorResponse(id, -2, fmt.Sprintf("Extracted data failed schema validation after %d attempts: %s", retries+1, strings.Join(errs, "; ")))
+}
+
+// parseJSONReply decodes a JSON object from a model reply, tolerating code
+// fences and text around the object
+func parseJSONReply(reply string) (interface{}, error) {
+	reply = strings.TrimSpace(reply)
+	reply = strings.TrimPrefix(reply, "```json")
+	reply = strings.TrimPrefix(reply, "```")
+	reply = strings.TrimSuffix(reply, "```")
+	reply = strings.TrimSpace(reply)
+
+	var value interface{}
+	err := json.Unmarshal([]byte(reply), &value)
+	if err != nil {
+		start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+		if start < 0 || end <= start {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(reply[start:end+1]), &value); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// truncateHTML cuts HTML to at most maxBytes, ending after the last complete
+// tag so neither a tag nor a UTF-8 character is split
+func truncateHTML(htmlContent string, maxBytes int) string {
+	if len(htmlContent) <= maxBytes {
+		return htmlContent
+	}
+	cut := htmlContent[:maxBytes]
+	if end := strings.LastIndexByte(cut, '>'); end >= 0 {
+		return cut[:end+1]
+	}
+	for len(cut) > 0 && !utf8.RuneStart(htmlContent[len(cut)]) {
+		cut = cut[:len(cut)-1]
+	}
+	return cut
+}
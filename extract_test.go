@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		maxBytes int
+		want     string
+	}{
+		{"under the limit", "<p>short</p>", 100, "<p>short</p>"},
+		{"exactly the limit", "<p>ab</p>", 9, "<p>ab</p>"},
+		{"ends after the last complete tag", "<p>one</p><p>two</p>", 15, "<p>one</p><p>"},
+		{"does not split a tag", "<p>one</p><a href=\"/x\">", 16, "<p>one</p>"},
+		{"text without tags backs off to a character boundary", "añb", 2, "a"},
+		{"multi-byte text without tags", "日本語", 7, "日本"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateHTML(tt.html, tt.maxBytes)
+			if got != tt.want {
+				t.Errorf("truncateHTML(%q, %d) = %q, want %q", tt.html, tt.maxBytes, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncateHTML(%q, %d) returned invalid UTF-8", tt.html, tt.maxBytes)
+			}
+		})
+	}
+}
@@ -0,0 +1,650 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultFeedMaxEntries = 20
+	feedSummaryLength     = 500
+)
+
+// Feed formats reported in ReadFeedOutput.Format
+const (
+	feedFormatRSS  = "rss"
+	feedFormatRDF  = "rdf"
+	feedFormatAtom = "atom"
+	feedFormatJSON = "json"
+)
+
+// feedLinkTypes are the <link rel="alternate"> types recognised during autodiscovery
+var feedLinkTypes = []string{
+	"application/rss+xml",
+	"application/atom+xml",
+	"application/feed+json",
+	"application/json",
+	"application/rdf+xml",
+}
+
+// FeedEntry is one item of a feed
+type FeedEntry struct {
+	ID        string           `json:"id,omitempty"`
+	Title     string           `json:"title,omitempty"`
+	Link      string           `json:"link,omitempty"`
+	Published string           `json:"published,omitempty"`
+	Updated   string           `json:"updated,omitempty"`
+	Author    string           `json:"author,omitempty"`
+	Summary   string           `json:"summary,omitempty"`
+	Page      *BatchReadResult `json:"page,omitempty"` // set when read_entries is enabled
+}
+
+// ReadFeedOutput is the structuredContent returned by read_feed
+type ReadFeedOutput struct {
+	FeedURL     string      `json:"feed_url"`
+	Format      string      `json:"format"`
+	Title       string      `json:"title,omitempty"`
+	HomeURL     string      `json:"home_url,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Discovered  []string    `json:"discovered,omitempty"` // feeds advertised by the page, when a page URL was given
+	Entries     []FeedEntry `json:"entries"`
+	TotalItems  int         `json:"total_items"`
+	Usage       *TokenUsage `json:"usage,omitempty"` // AI tokens spent reading entries
+}
+
+// feed is a parsed feed before entries are truncated and read
+type feed struct {
+	Format      string
+	Title       string
+	HomeURL     string
+	Description string
+	Entries     []FeedEntry
+}
+
+// rssLink matches both RSS <link>text</link> and Atom-style <atom:link href=""/> inside RSS
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Text string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string    `xml:"title"`
+	Links       []rssLink `xml:"link"`
+	GUID        string    `xml:"guid"`
+	PubDate     string    `xml:"pubDate"`
+	DCDate      string    `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Author      string    `xml:"author"`
+	Creator     string    `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Description string    `xml:"description"`
+	Content     string    `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+}
+
+// rssDocument covers RSS 0.9x/2.0 and RSS 1.0 (RDF), whose items sit beside the channel
+type rssDocument struct {
+	XMLName xml.Name
+	Channel struct {
+		Title       string    `xml:"title"`
+		Links       []rssLink `xml:"link"`
+		Description string    `xml:"description"`
+		Items       []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Items []rssItem `xml:"item"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// atomText is an Atom text construct; xhtml content is kept as markup
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID        string       `xml:"id"`
+	Title     atomText     `xml:"title"`
+	Links     []atomLink   `xml:"link"`
+	Published string       `xml:"published"`
+	Updated   string       `xml:"updated"`
+	Authors   []atomPerson `xml:"author"`
+	Summary   atomText     `xml:"summary"`
+	Content   atomText     `xml:"content"`
+}
+
+type atomFeed struct {
+	Title    atomText     `xml:"title"`
+	Subtitle atomText     `xml:"subtitle"`
+	Links    []atomLink   `xml:"link"`
+	Authors  []atomPerson `xml:"author"`
+	Entries  []atomEntry  `xml:"entry"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	Description string           `json:"description"`
+	Author      *jsonFeedAuthor  `json:"author"`
+	Authors     []jsonFeedAuthor `json:"authors"`
+	Items       []struct {
+		ID            interface{}      `json:"id"`
+		URL           string           `json:"url"`
+		ExternalURL   string           `json:"external_url"`
+		Title         string           `json:"title"`
+		Summary       string           `json:"summary"`
+		ContentHTML   string           `json:"content_html"`
+		ContentText   string           `json:"content_text"`
+		DatePublished string           `json:"date_published"`
+		DateModified  string           `json:"date_modified"`
+		Author        *jsonFeedAuthor  `json:"author"`
+		Authors       []jsonFeedAuthor `json:"authors"`
+	} `json:"items"`
+}
+
+// readFeedTool describes the read_feed tool
+func readFeedTool() Tool {
+	properties := map[string]interface{}{
+		"url": map[string]interface{}{
+			"type":        "string",
+			"description": "The feed URL (RSS, Atom or JSON Feed), or a page that advertises a feed with <link rel=\"alternate\">",
+		},
+		"max_entries": map[string]interface{}{
+			"type":        "integer",
+			"description": "Maximum number of entries to return, newest first as ordered by the feed (default: 20)",
+		},
+		"read_entries": map[string]interface{}{
+			"type":        "boolean",
+			"description": fmt.Sprintf("Also read each entry's link through web_reader (at most %d entries); the web_reader options below apply", maxBatchURLs),
+		},
+		"concurrency": map[string]interface{}{
+			"type":        "integer",
+			"description": "Number of entry links read in parallel when read_entries is set (default: 4, max: 10)",
+		},
+		"timeout_seconds": map[string]interface{}{
+			"type":        "number",
+			"description": "Overall time limit for reading entry links in seconds (default: 120)",
+		},
+	}
+	for name, schema := range webReaderTool().InputSchema["properties"].(map[string]interface{}) {
+		if _, ok := properties[name]; !ok {
+			properties[name] = schema
+		}
+	}
+
+	return Tool{
+		Name:        "read_feed",
+		Description: "Read an RSS 2.0, RSS 1.0, Atom or JSON Feed, auto-discovering the feed when given a regular page. Returns entries with title, link, date, author and summary, and can read each entry's page to Markdown.",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   []string{"url"},
+		},
+		OutputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"feed_url": map[string]interface{}{"type": "string"},
+				"format": map[string]interface{}{
+					"type": "string",
+					"enum": []string{feedFormatRSS, feedFormatRDF, feedFormatAtom, feedFormatJSON},
+				},
+				"title":       map[string]interface{}{"type": "string"},
+				"home_url":    map[string]interface{}{"type": "string"},
+				"description": map[string]interface{}{"type": "string"},
+				"discovered":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				"entries": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"id":        map[string]interface{}{"type": "string"},
+							"title":     map[string]interface{}{"type": "string"},
+							"link":      map[string]interface{}{"type": "string"},
+							"published": map[string]interface{}{"type": "string"},
+							"updated":   map[string]interface{}{"type": "string"},
+							"author":    map[string]interface{}{"type": "string"},
+							"summary":   map[string]interface{}{"type": "string"},
+							"page":      map[string]interface{}{"type": "object"},
+						},
+					},
+				},
+				"total_items": map[string]interface{}{"type": "integer"},
+				"usage":       tokenUsageSchema,
+			},
+			"required": []string{"feed_url", "format", "entries", "total_items"},
+		},
+	}
+}
+
+// handleReadFeed processes the read_feed tool call
+func handleReadFeed(id interface{}, args map[string]interface{}, progressToken interface{}) *JSONRPCMessage {
+	sourceURL, ok := args["url"].(string)
+	if !ok || sourceURL == "" {
+		return newErrorResponse(id, -32602, "missing required parameter: url")
+	}
+	parsedURL, err := url.Parse(sourceURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		return newErrorResponse(id, -32602, fmt.Sprintf("invalid URL format: %s", sourceURL))
+	}
+
+	maxEntries := defaultFeedMaxEntries
+	if v, ok := args["max_entries"].(float64); ok && v > 0 {
+		maxEntries = int(v)
+	}
+	readEntries, _ := args["read_entries"].(bool)
+	concurrency := defaultBatchConcurrency
+	if v, ok := args["concurrency"].(float64); ok && v > 0 {
+		concurrency = min(int(v), maxBatchConcurrency)
+	}
+	timeout := defaultBatchTimeout
+	if v, ok := args["timeout_seconds"].(float64); ok && v > 0 {
+		timeout = time.Duration(v * float64(time.Second))
+	}
+
+	log.Printf("Reading feed: %s", sourceURL)
+
+	content, err := fetchWebContent(context.Background(), sourceURL)
+	if err != nil {
+		return newErrorResponse(id, -1, fmt.Sprintf("Failed to fetch web content: %v", err))
+	}
+
+	feedURL := parsedURL
+	var discovered []string
+	parsed, err := parseFeed(content, feedURL)
+	if err != nil {
+		// Not a feed: treat it as a page and follow the first advertised feed
+		discovered = discoverFeeds(content, parsedURL)
+		if len(discovered) == 0 {
+			return newErrorResponse(id, -2, fmt.Sprintf("No feed found at %s: %v", sourceURL, err))
+		}
+		log.Printf("Discovered feed %s on %s", discovered[0], sourceURL)
+
+		feedURL, _ = url.Parse(discovered[0])
+		if content, err = fetchWebContent(context.Background(), discovered[0]); err != nil {
+			return newErrorResponse(id, -1, fmt.Sprintf("Failed to fetch feed %s: %v", discovered[0], err))
+		}
+		if parsed, err = parseFeed(content, feedURL); err != nil {
+			return newErrorResponse(id, -2, fmt.Sprintf("Failed to parse feed %s: %v", discovered[0], err))
+		}
+	}
+
+	output := ReadFeedOutput{
+		FeedURL:     feedURL.String(),
+		Format:      parsed.Format,
+		Title:       parsed.Title,
+		HomeURL:     parsed.HomeURL,
+		Description: parsed.Description,
+		Discovered:  discovered,
+		Entries:     parsed.Entries,
+		TotalItems:  len(parsed.Entries),
+	}
+	if len(output.Entries) > maxEntries {
+		output.Entries = output.Entries[:maxEntries]
+	}
+	if output.Entries == nil {
+		output.Entries = []FeedEntry{}
+	}
+
+	var pageContent []interface{}
+	if readEntries {
+		var inputs []*WebReaderInput
+		var entryIndex []int
+		for i, entry := range output.Entries {
+			if entry.Link == "" || len(inputs) >= maxBatchURLs {
+				continue
+			}
+			entryArgs := make(map[string]interface{}, len(args))
+			for k, v := range args {
+				entryArgs[k] = v
+			}
+			entryArgs["url"] = entry.Link
+
+			input, err := parseWebReaderInput(entryArgs)
+			if err != nil {
+				return newErrorResponse(id, -32602, fmt.Sprintf("%s: %v", entry.Link, err))
+			}
+			inputs = append(inputs, input)
+			entryIndex = append(entryIndex, i)
+		}
+
+		results, pages := readBatch(inputs, concurrency, timeout, progressToken)
+		var usage TokenUsage
+		for j, i := range entryIndex {
+			result := results[j]
+			output.Entries[i].Page = &result
+			if page := pages[j]; page != nil {
+				usage.add(page.Usage)
+				pageContent = append(pageContent, buildToolResponse(page, inputs[j].ImagesAsContent)...)
+			}
+		}
+		output.Usage = usage.report()
+	}
+
+	return newToolResult(id, append([]interface{}{
+		TextContent{
+			Type: "text",
+			Text: formatFeedReport(output),
+		},
+	}, pageContent...), output)
+}
+
+// parseFeed detects the feed format and parses it into entries with absolute links
+func parseFeed(content string, feedURL *url.URL) (*feed, error) {
+	trimmed := strings.TrimSpace(strings.TrimPrefix(content, "\ufeff"))
+
+	var f *feed
+	var err error
+	switch {
+	case strings.HasPrefix(trimmed, "{"):
+		f, err = parseJSONFeed(trimmed)
+	case strings.HasPrefix(trimmed, "<"):
+		f, err = parseXMLFeed(trimmed)
+	default:
+		err = fmt.Errorf("unrecognised feed format")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f.HomeURL = resolveFeedLink(feedURL, f.HomeURL)
+	for i := range f.Entries {
+		f.Entries[i].Link = resolveFeedLink(feedURL, f.Entries[i].Link)
+		f.Entries[i].Summary = truncateRunes(f.Entries[i].Summary, feedSummaryLength)
+	}
+	return f, nil
+}
+
+// parseXMLFeed parses RSS and Atom documents, dispatching on the root element
+func parseXMLFeed(content string) (*feed, error) {
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal([]byte(content), &root); err != nil {
+		return nil, fmt.Errorf("invalid XML: %w", err)
+	}
+
+	switch strings.ToLower(root.XMLName.Local) {
+	case "rss", "rdf":
+		var doc rssDocument
+		if err := xml.Unmarshal([]byte(content), &doc); err != nil {
+			return nil, fmt.Errorf("invalid RSS: %w", err)
+		}
+		f := &feed{
+			Format:      feedFormatRSS,
+			Title:       htmlText(doc.Channel.Title),
+			HomeURL:     rssLinkURL(doc.Channel.Links),
+			Description: htmlText(doc.Channel.Description),
+		}
+		items := doc.Channel.Items
+		if strings.EqualFold(root.XMLName.Local, "rdf") {
+			f.Format = feedFormatRDF
+			items = append(items, doc.Items...)
+		}
+		for _, item := range items {
+			f.Entries = append(f.Entries, FeedEntry{
+				ID:        strings.TrimSpace(item.GUID),
+				Title:     htmlText(item.Title),
+				Link:      firstNonEmpty(rssLinkURL(item.Links), guidLink(item.GUID)),
+				Published: normalizeFeedDate(firstNonEmpty(item.PubDate, item.DCDate)),
+				Author:    firstNonEmpty(item.Creator, item.Author),
+				Summary:   htmlText(firstNonEmpty(item.Description, item.Content)),
+			})
+		}
+		return f, nil
+
+	case "feed":
+		var doc atomFeed
+		if err := xml.Unmarshal([]byte(content), &doc); err != nil {
+			return nil, fmt.Errorf("invalid Atom: %w", err)
+		}
+		f := &feed{
+			Format:      feedFormatAtom,
+			Title:       doc.Title.text(),
+			HomeURL:     atomLinkURL(doc.Links),
+			Description: doc.Subtitle.text(),
+		}
+		for _, entry := range doc.Entries {
+			authors := entry.Authors
+			if len(authors) == 0 {
+				authors = doc.Authors
+			}
+			f.Entries = append(f.Entries, FeedEntry{
+				ID:        strings.TrimSpace(entry.ID),
+				Title:     entry.Title.text(),
+				Link:      atomLinkURL(entry.Links),
+				Published: normalizeFeedDate(firstNonEmpty(entry.Published, entry.Updated)),
+				Updated:   normalizeFeedDate(entry.Updated),
+				Author:    atomAuthors(authors),
+				Summary:   firstNonEmpty(entry.Summary.text(), entry.Content.text()),
+			})
+		}
+		return f, nil
+	}
+
+	return nil, fmt.Errorf("not a feed: root element <%s>", root.XMLName.Local)
+}
+
+// parseJSONFeed parses a JSON Feed 1.0 or 1.1 document
+func parseJSONFeed(content string) (*feed, error) {
+	var doc jsonFeed
+	if err := json.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if !strings.Contains(doc.Version, "jsonfeed.org") {
+		return nil, fmt.Errorf("not a JSON Feed: missing version")
+	}
+
+	f := &feed{
+		Format:      feedFormatJSON,
+		Title:       doc.Title,
+		HomeURL:     doc.HomePageURL,
+		Description: doc.Description,
+	}
+	feedAuthors := jsonFeedAuthors(doc.Author, doc.Authors)
+	for _, item := range doc.Items {
+		f.Entries = append(f.Entries, FeedEntry{
+			ID:        jsonFeedID(item.ID),
+			Title:     item.Title,
+			Link:      firstNonEmpty(item.URL, item.ExternalURL),
+			Published: normalizeFeedDate(firstNonEmpty(item.DatePublished, item.DateModified)),
+			Updated:   normalizeFeedDate(item.DateModified),
+			Author:    firstNonEmpty(jsonFeedAuthors(item.Author, item.Authors), feedAuthors),
+			Summary:   firstNonEmpty(item.Summary, item.ContentText, htmlText(item.ContentHTML)),
+		})
+	}
+	return f, nil
+}
+
+// jsonFeedID reads an item id, which the spec says is a string but some feeds
+// write as a number. A missing or other id is left empty.
+func jsonFeedID(id interface{}) string {
+	switch v := id.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// discoverFeeds returns the feeds a page advertises with <link rel="alternate">
+func discoverFeeds(htmlContent string, baseURL *url.URL) []string {
+	var feeds []string
+	seen := make(map[string]bool)
+	for _, tag := range linkTagRegex.FindAllString(htmlContent, -1) {
+		rel := strings.Fields(strings.ToLower(tagAttr(tag, "rel")))
+		if !containsFold(rel, "alternate") || !containsFold(feedLinkTypes, strings.TrimSpace(tagAttr(tag, "type"))) {
+			continue
+		}
+		href := strings.TrimSpace(tagAttr(tag, "href"))
+		if href == "" {
+			continue
+		}
+		u, err := resolveURL(baseURL, href)
+		if err != nil || seen[u.String()] {
+			continue
+		}
+		seen[u.String()] = true
+		feeds = append(feeds, u.String())
+	}
+	return feeds
+}
+
+// text returns an Atom text construct as plain text
+func (t atomText) text() string {
+	if strings.EqualFold(t.Type, "xhtml") {
+		return htmlText(t.Inner)
+	}
+	return htmlText(t.Text)
+}
+
+// rssLinkURL returns the first RSS <link> text, or the href of an atom:link
+func rssLinkURL(links []rssLink) string {
+	for _, l := range links {
+		if s := strings.TrimSpace(l.Text); s != "" {
+			return s
+		}
+	}
+	for _, l := range links {
+		if l.Href != "" {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+// guidLink returns an RSS guid when it is a URL, as permalink guids are
+func guidLink(guid string) string {
+	guid = strings.TrimSpace(guid)
+	if strings.HasPrefix(guid, "http://") || strings.HasPrefix(guid, "https://") {
+		return guid
+	}
+	return ""
+}
+
+// atomLinkURL prefers the rel="alternate" link, which Atom treats as the default
+func atomLinkURL(links []atomLink) string {
+	for _, l := range links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+func atomAuthors(authors []atomPerson) string {
+	var names []string
+	for _, a := range authors {
+		if name := strings.TrimSpace(a.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func jsonFeedAuthors(author *jsonFeedAuthor, authors []jsonFeedAuthor) string {
+	if author != nil {
+		authors = append([]jsonFeedAuthor{*author}, authors...)
+	}
+	var names []string
+	for _, a := range authors {
+		if name := strings.TrimSpace(a.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// resolveFeedLink makes a feed link absolute against the feed URL
+func resolveFeedLink(feedURL *url.URL, link string) string {
+	link = strings.TrimSpace(link)
+	if link == "" || feedURL == nil {
+		return link
+	}
+	if u, err := resolveURL(feedURL, link); err == nil {
+		return u.String()
+	}
+	return link
+}
+
+// normalizeFeedDate converts RSS (RFC 822) and Atom (RFC 3339) dates to RFC 3339,
+// leaving dates it cannot parse untouched
+func normalizeFeedDate(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	for _, layout := range []string{
+		time.RFC3339Nano,
+		time.RFC1123Z,
+		time.RFC1123,
+		"Mon, 2 Jan 2006 15:04:05 -0700",
+		"Mon, 2 Jan 2006 15:04:05 MST",
+		"2 Jan 2006 15:04:05 -0700",
+		time.RFC822Z,
+		time.RFC822,
+		"2006-01-02",
+	} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC().Format(time.RFC3339)
+		}
+	}
+	return s
+}
+
+// formatFeedReport renders the feed entries as Markdown
+func formatFeedReport(output ReadFeedOutput) string {
+	var b strings.Builder
+
+	title := firstNonEmpty(output.Title, output.FeedURL)
+	fmt.Fprintf(&b, "# Feed: %s\n\n", title)
+	fmt.Fprintf(&b, "- Feed URL: %s\n", output.FeedURL)
+	fmt.Fprintf(&b, "- Format: %s\n", output.Format)
+	if output.HomeURL != "" {
+		fmt.Fprintf(&b, "- Site: %s\n", output.HomeURL)
+	}
+	if output.Description != "" {
+		fmt.Fprintf(&b, "- Description: %s\n", truncateRunes(output.Description, 200))
+	}
+	fmt.Fprintf(&b, "- Entries: %d of %d\n", len(output.Entries), output.TotalItems)
+	b.WriteString(formatTokenUsage(output.Usage))
+
+	for _, entry := range output.Entries {
+		fmt.Fprintf(&b, "\n## %s\n\n", firstNonEmpty(entry.Title, entry.Link, entry.ID))
+		if entry.Link != "" {
+			fmt.Fprintf(&b, "- Link: %s\n", entry.Link)
+		}
+		if entry.Published != "" {
+			fmt.Fprintf(&b, "- Published: %s\n", entry.Published)
+		}
+		if entry.Author != "" {
+			fmt.Fprintf(&b, "- Author: %s\n", entry.Author)
+		}
+		if entry.Page != nil && entry.Page.Status != batchStatusOK {
+			fmt.Fprintf(&b, "- Page not read (%s): %s\n", entry.Page.Status, entry.Page.Error)
+		}
+		if entry.Summary != "" {
+			fmt.Fprintf(&b, "\n%s\n", entry.Summary)
+		}
+	}
+
+	return b.String()
+}
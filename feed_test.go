@@ -0,0 +1,224 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParseFeed(t *testing.T) {
+	feedURL, _ := url.Parse("https://example.com/blog/feed")
+
+	tests := []struct {
+		name    string
+		content string
+		want    *feed
+	}{
+		{
+			name: "rss",
+			content: "\ufeff" + `<?xml version="1.0"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:atom="http://www.w3.org/2005/Atom">
+<channel>
+  <title>Example &amp; Co</title>
+  <atom:link href="https://example.com/blog/feed" rel="self"/>
+  <link>https://example.com/blog/</link>
+  <description>News</description>
+  <item>
+    <title>First</title>
+    <link>/blog/first</link>
+    <guid isPermaLink="false">post-1</guid>
+    <pubDate>Tue, 02 Jan 2024 15:04:05 +0100</pubDate>
+    <dc:creator>Ada</dc:creator>
+    <description>&lt;p&gt;Hello &lt;b&gt;world&lt;/b&gt;&lt;/p&gt;</description>
+  </item>
+  <item>
+    <title>Second</title>
+    <guid>https://example.com/blog/second</guid>
+  </item>
+</channel>
+</rss>`,
+			want: &feed{
+				Format:      feedFormatRSS,
+				Title:       "Example & Co",
+				HomeURL:     "https://example.com/blog/",
+				Description: "News",
+				Entries: []FeedEntry{
+					{
+						ID:        "post-1",
+						Title:     "First",
+						Link:      "https://example.com/blog/first",
+						Published: "2024-01-02T14:04:05Z",
+						Author:    "Ada",
+						Summary:   "Hello world",
+					},
+					{
+						ID:    "https://example.com/blog/second",
+						Title: "Second",
+						Link:  "https://example.com/blog/second",
+					},
+				},
+			},
+		},
+		{
+			name: "rdf",
+			content: `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel>
+    <title>RDF site</title>
+    <link>https://example.com/</link>
+  </channel>
+  <item>
+    <title>Entry</title>
+    <link>https://example.com/entry</link>
+    <dc:date>2024-03-01T08:00:00Z</dc:date>
+  </item>
+</rdf:RDF>`,
+			want: &feed{
+				Format:  feedFormatRDF,
+				Title:   "RDF site",
+				HomeURL: "https://example.com/",
+				Entries: []FeedEntry{
+					{
+						Title:     "Entry",
+						Link:      "https://example.com/entry",
+						Published: "2024-03-01T08:00:00Z",
+					},
+				},
+			},
+		},
+		{
+			name: "atom",
+			content: `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title type="html">Atom &lt;em&gt;blog&lt;/em&gt;</title>
+  <subtitle>Notes</subtitle>
+  <link rel="self" href="/blog/feed"/>
+  <link href="/blog/"/>
+  <author><name>Feed Author</name></author>
+  <entry>
+    <id>urn:uuid:1</id>
+    <title>Post</title>
+    <link rel="alternate" href="posts/1"/>
+    <published>2024-05-01T10:00:00+02:00</published>
+    <updated>2024-05-02T10:00:00Z</updated>
+    <summary>Short</summary>
+  </entry>
+  <entry>
+    <id>urn:uuid:2</id>
+    <title type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml">Rich <b>title</b></div></title>
+    <updated>2024-05-03T00:00:00Z</updated>
+    <author><name>One</name></author>
+    <author><name>Two</name></author>
+    <content type="html">&lt;p&gt;Body&lt;/p&gt;</content>
+  </entry>
+</feed>`,
+			want: &feed{
+				Format:      feedFormatAtom,
+				Title:       "Atom blog",
+				HomeURL:     "https://example.com/blog/",
+				Description: "Notes",
+				Entries: []FeedEntry{
+					{
+						ID:        "urn:uuid:1",
+						Title:     "Post",
+						Link:      "https://example.com/blog/posts/1",
+						Published: "2024-05-01T08:00:00Z",
+						Updated:   "2024-05-02T10:00:00Z",
+						Author:    "Feed Author",
+						Summary:   "Short",
+					},
+					{
+						ID:        "urn:uuid:2",
+						Title:     "Rich title",
+						Published: "2024-05-03T00:00:00Z",
+						Updated:   "2024-05-03T00:00:00Z",
+						Author:    "One, Two",
+						Summary:   "Body",
+					},
+				},
+			},
+		},
+		{
+			name: "json feed",
+			content: `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "JSON blog",
+  "home_page_url": "https://example.com/",
+  "authors": [{"name": "Feed Author"}],
+  "items": [
+    {"id": "a1", "url": "/posts/a", "title": "A", "content_html": "<p>Html <i>body</i></p>", "date_published": "2024-06-01T12:00:00Z"},
+    {"id": 42, "external_url": "https://other.example/b", "summary": "B summary", "authors": [{"name": "Item Author"}]},
+    {"id": {"bad": true}, "content_text": "Plain"}
+  ]
+}`,
+			want: &feed{
+				Format:  feedFormatJSON,
+				Title:   "JSON blog",
+				HomeURL: "https://example.com/",
+				Entries: []FeedEntry{
+					{
+						ID:        "a1",
+						Title:     "A",
+						Link:      "https://example.com/posts/a",
+						Published: "2024-06-01T12:00:00Z",
+						Author:    "Feed Author",
+						Summary:   "Html body",
+					},
+					{
+						ID:      "42",
+						Link:    "https://other.example/b",
+						Author:  "Item Author",
+						Summary: "B summary",
+					},
+					{
+						Author:  "Feed Author",
+						Summary: "Plain",
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFeed(tt.content, feedURL)
+			if err != nil {
+				t.Fatalf("parseFeed() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFeed() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFeedErrors(t *testing.T) {
+	for _, content := range []string{
+		"",
+		"plain text",
+		`<html><body>Not a feed</body></html>`,
+		`{"title": "no version"}`,
+		`<rss><channel>`,
+	} {
+		if _, err := parseFeed(content, nil); err == nil {
+			t.Errorf("parseFeed(%q) succeeded, want error", content)
+		}
+	}
+}
+
+func TestParseFeedTruncatesSummaryByCharacter(t *testing.T) {
+	content := `{"version":"https://jsonfeed.org/version/1","items":[{"summary":"` + strings.Repeat("é", feedSummaryLength+50) + `"}]}`
+	f, err := parseFeed(content, nil)
+	if err != nil {
+		t.Fatalf("parseFeed() error = %v", err)
+	}
+	summary := f.Entries[0].Summary
+	if !utf8.ValidString(summary) {
+		t.Errorf("summary is not valid UTF-8")
+	}
+	if want := feedSummaryLength + len("..."); utf8.RuneCountInString(summary) != want {
+		t.Errorf("summary has %d characters, want %d", utf8.RuneCountInString(summary), want)
+	}
+}
@@ -1,3 +1,9 @@
 module web-reader-mcp
 
 go 1.25.5
+
+require (
+	github.com/andybalholm/cascadia v1.3.3
+	golang.org/x/image v0.25.0
+	golang.org/x/net v0.47.0
+)
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	tagStripRegex   = regexp.MustCompile(`<[^>]+>`)
+	whitespaceRegex = regexp.MustCompile(`\s+`)
+	metaTagRegex    = regexp.MustCompile(`(?i)<meta\s[^>]*>`)
+)
+
+// htmlRegexCache holds the expressions built from attribute and element names,
+// which come from a small fixed set, so each is compiled only once
+var htmlRegexCache sync.Map // expression -> *regexp.Regexp
+
+// cachedRegexp returns the compiled form of expr, compiling it on first use
+func cachedRegexp(expr string) *regexp.Regexp {
+	if re, ok := htmlRegexCache.Load(expr); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, _ := htmlRegexCache.LoadOrStore(expr, regexp.MustCompile(expr))
+	return re.(*regexp.Regexp)
+}
+
+// tagAttr returns the unescaped value of the named attribute in an opening tag.
+// The match is anchored on whitespace so "src" does not match "data-src".
+func tagAttr(tag, name string) string {
+	attrRegex := cachedRegexp(`(?i)\s` + regexp.QuoteMeta(name) + `\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	match := attrRegex.FindStringSubmatch(tag)
+	if match == nil {
+		return ""
+	}
+	for _, v := range match[1:] {
+		if v != "" {
+			return html.UnescapeString(v)
+		}
+	}
+	return ""
+}
+
+// htmlText strips tags and entities and collapses whitespace
+func htmlText(fragment string) string {
+	text := tagStripRegex.ReplaceAllString(fragment, " ")
+	text = html.UnescapeString(text)
+	return strings.TrimSpace(whitespaceRegex.ReplaceAllString(text, " "))
+}
+
+// elementSpans returns the byte offsets of every <name>...</name> element
+func elementSpans(htmlContent, name string) [][]int {
+	spanRegex := cachedRegexp(`(?is)<` + name + `\b[^>]*>.*?</` + name + `\s*>`)
+	return spanRegex.FindAllStringIndex(htmlContent, -1)
+}
+
+// spanContaining returns the index of the span that contains pos, or -1
+func spanContaining(spans [][]int, pos int) int {
+	for i, span := range spans {
+		if pos >= span[0] && pos < span[1] {
+			return i
+		}
+	}
+	return -1
+}
+
+// metaContent returns the content of the first <meta> tag whose name,
+// property or itemprop matches one of the given keys, in order of preference
+func metaContent(htmlContent string, keys ...string) string {
+	tags := metaTagRegex.FindAllString(htmlContent, -1)
+	for _, key := range keys {
+		for _, tag := range tags {
+			name := tagAttr(tag, "property")
+			if name == "" {
+				name = tagAttr(tag, "name")
+			}
+			if name == "" {
+				name = tagAttr(tag, "itemprop")
+			}
+			if strings.EqualFold(name, key) {
+				if content := strings.TrimSpace(tagAttr(tag, "content")); content != "" {
+					return content
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestTagAttr(t *testing.T) {
+	tests := []struct {
+		tag  string
+		name string
+		want string
+	}{
+		{`<img src="/a.png" alt="A &amp; B">`, "src", "/a.png"},
+		{`<img src="/a.png" alt="A &amp; B">`, "alt", "A & B"},
+		{`<img data-src="/lazy.png" src="/placeholder.gif">`, "src", "/placeholder.gif"},
+		{`<img data-src="/lazy.png">`, "src", ""},
+		{`<img data-src="/lazy.png">`, "data-src", "/lazy.png"},
+		{`<IMG SRC='/single.png'>`, "src", "/single.png"},
+		{`<img src=/bare.png width=10>`, "src", "/bare.png"},
+		{`<img srcset="/a.png 1x, /b.png 2x">`, "srcset", "/a.png 1x, /b.png 2x"},
+		{`<img alt="">`, "alt", ""},
+	}
+
+	for _, tt := range tests {
+		// Twice, so the second call goes through the cached expression
+		for i := 0; i < 2; i++ {
+			if got := tagAttr(tt.tag, tt.name); got != tt.want {
+				t.Errorf("tagAttr(%q, %q) = %q, want %q", tt.tag, tt.name, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestElementSpans(t *testing.T) {
+	htmlContent := `<nav><a href="/x">x</a></nav><main><a href="/y">y</a></main><NAV class="b">z</NAV >`
+	spans := elementSpans(htmlContent, "nav")
+	if len(spans) != 2 {
+		t.Fatalf("elementSpans(nav) = %v, want 2 spans", spans)
+	}
+	if got := htmlContent[spans[1][0]:spans[1][1]]; got != `<NAV class="b">z</NAV >` {
+		t.Errorf("second span = %q", got)
+	}
+	if i := spanContaining(spans, len(`<nav><a href="/x">`)); i != 0 {
+		t.Errorf("spanContaining() = %d, want 0", i)
+	}
+	if i := spanContaining(spans, len(`<nav><a href="/x">x</a></nav><main>`)); i != -1 {
+		t.Errorf("spanContaining() = %d, want -1", i)
+	}
+}
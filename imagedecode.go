@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	defaultMinImageDimension = 4
+	downscaleJPEGQuality     = 85
+	maxDecodePixels          = 40 * 1000 * 1000 // refuse to decode larger images into memory
+)
+
+// decodeImageConfig reads the true dimensions and format (png, jpeg, gif, webp)
+// from the image header without decoding the pixel data
+func decodeImageConfig(data []byte) (int, int, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, "", err
+	}
+	return cfg.Width, cfg.Height, format, nil
+}
+
+// isTinyImage reports whether known dimensions mark the image as a tracking pixel or spacer
+func isTinyImage(width, height, minDimension int) bool {
+	if minDimension <= 0 {
+		return false
+	}
+	return (width > 0 && width < minDimension) || (height > 0 && height < minDimension)
+}
+
+// downscaleImage shrinks an image so its longest edge is maxEdge and re-encodes it.
+// Formats that may carry transparency are written as PNG, everything else as JPEG.
+// Images over maxDecodePixels are refused before decoding so a small, highly
+// compressed file cannot expand into gigabytes of pixel data.
+func downscaleImage(data []byte, maxEdge int) ([]byte, string, error) {
+	width, height, _, err := decodeImageConfig(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	if int64(width)*int64(height) > maxDecodePixels {
+		return nil, "", fmt.Errorf("%w: %dx%d pixels", errImageTooLarge, width, height)
+	}
+
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	width, height = bounds.Dx(), bounds.Dy()
+	if width <= maxEdge && height <= maxEdge {
+		return data, "image/" + format, nil
+	}
+
+	if width >= height {
+		height = max(1, height*maxEdge/width)
+		width = maxEdge
+	} else {
+		width = max(1, width*maxEdge/height)
+		height = maxEdge
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	switch format {
+	case "png", "gif", "webp":
+		if err := png.Encode(&buf, dst); err != nil {
+			return nil, "", fmt.Errorf("failed to encode PNG: %w", err)
+		}
+		return buf.Bytes(), "image/png", nil
+	default:
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: downscaleJPEGQuality}); err != nil {
+			return nil, "", fmt.Errorf("failed to encode JPEG: %w", err)
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+}
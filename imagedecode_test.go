@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"testing"
+)
+
+func TestDecodeImageConfig(t *testing.T) {
+	var gifData, jpegData bytes.Buffer
+	if err := gif.Encode(&gifData, image.NewPaletted(image.Rect(0, 0, 3, 2), color.Palette{color.Black}), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(&jpegData, image.NewGray(image.Rect(0, 0, 40, 30)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		data          []byte
+		width, height int
+		format        string
+		wantErr       bool
+	}{
+		{name: "png", data: testPNG(t, 20, 10), width: 20, height: 10, format: "png"},
+		{name: "gif", data: gifData.Bytes(), width: 3, height: 2, format: "gif"},
+		{name: "jpeg", data: jpegData.Bytes(), width: 40, height: 30, format: "jpeg"},
+		{name: "not an image", data: []byte("<svg></svg>"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height, format, err := decodeImageConfig(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeImageConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if width != tt.width || height != tt.height || format != tt.format {
+				t.Errorf("decodeImageConfig() = %d, %d, %q, want %d, %d, %q", width, height, format, tt.width, tt.height, tt.format)
+			}
+		})
+	}
+}
+
+func TestIsTinyImage(t *testing.T) {
+	tests := []struct {
+		width, height, minDimension int
+		want                        bool
+	}{
+		{1, 1, 4, true},
+		{100, 1, 4, true},
+		{1, 100, 4, true},
+		{4, 4, 4, false},
+		{0, 0, 4, false}, // unknown dimensions are kept
+		{1, 1, 0, false},
+	}
+	for _, tt := range tests {
+		if got := isTinyImage(tt.width, tt.height, tt.minDimension); got != tt.want {
+			t.Errorf("isTinyImage(%d, %d, %d) = %v, want %v", tt.width, tt.height, tt.minDimension, got, tt.want)
+		}
+	}
+}
+
+func TestDownscaleImage(t *testing.T) {
+	var jpegData bytes.Buffer
+	if err := jpeg.Encode(&jpegData, image.NewGray(image.Rect(0, 0, 300, 600)), nil); err != nil {
+		t.Fatal(err)
+	}
+	small := testPNG(t, 50, 20)
+
+	tests := []struct {
+		name          string
+		data          []byte
+		maxEdge       int
+		mimeType      string
+		width, height int
+	}{
+		{name: "wide png stays png", data: testPNG(t, 400, 100), maxEdge: 200, mimeType: "image/png", width: 200, height: 50},
+		{name: "tall jpeg stays jpeg", data: jpegData.Bytes(), maxEdge: 100, mimeType: "image/jpeg", width: 50, height: 100},
+		{name: "thin edge kept at one pixel", data: testPNG(t, 1000, 2), maxEdge: 100, mimeType: "image/png", width: 100, height: 1},
+		{name: "already small enough", data: small, maxEdge: 100, mimeType: "image/png", width: 50, height: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scaled, mimeType, err := downscaleImage(tt.data, tt.maxEdge)
+			if err != nil {
+				t.Fatalf("downscaleImage() error = %v", err)
+			}
+			if mimeType != tt.mimeType {
+				t.Errorf("MIME type = %q, want %q", mimeType, tt.mimeType)
+			}
+			width, height, _, err := decodeImageConfig(scaled)
+			if err != nil || width != tt.width || height != tt.height {
+				t.Errorf("scaled image = %dx%d (%v), want %dx%d", width, height, err, tt.width, tt.height)
+			}
+		})
+	}
+
+	if scaled, _, _ := downscaleImage(small, 100); !bytes.Equal(scaled, small) {
+		t.Error("an image within maxEdge should be returned unchanged")
+	}
+}
+
+func TestDownscaleImageRefusesHugeImages(t *testing.T) {
+	// A tiny file whose header claims 10000x10000 pixels must not be decoded
+	data := testPNG(t, 1, 1)
+	ihdr := data[12:29] // chunk type and data of the IHDR chunk that follows the signature
+	binary.BigEndian.PutUint32(ihdr[4:8], 10000)
+	binary.BigEndian.PutUint32(ihdr[8:12], 10000)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(ihdr))
+
+	if width, height, _, err := decodeImageConfig(data); err != nil || width != 10000 || height != 10000 {
+		t.Fatalf("patched header decodes as %dx%d (%v)", width, height, err)
+	}
+	if _, _, err := downscaleImage(data, 100); !errors.Is(err, errImageTooLarge) {
+		t.Errorf("downscaleImage() error = %v, want errImageTooLarge", err)
+	}
+}
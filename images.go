@@ -0,0 +1,483 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxImages     = 20
+	defaultMaxImageBytes = 20 * 1024 * 1024
+	imageDownloadWorkers = 4
+	imageDownloadTimeout = 15 * time.Second
+)
+
+// Per-image download status reported in ImageInfo.Status
+const (
+	imageStatusOK       = "ok"
+	imageStatusTooLarge = "too_large"
+	imageStatusTimeout  = "timeout"
+	imageStatusSkipped  = "skipped"
+	imageStatusFailed   = "failed"
+)
+
+var errImageTooLarge = errors.New("image too large")
+
+// imageOptions controls which images are downloaded during extraction
+type imageOptions struct {
+	KeepDataURL    bool
+	MaxImages      int   // maximum number of images downloaded per call
+	MaxBytes       int64 // total bytes budget for downloaded images per call
+	MaxEdge        int   // downscale downloaded images whose longest edge exceeds this, 0 keeps originals
+	MinDimension   int   // drop images narrower or shorter than this many pixels, 0 keeps all
+	PreferredWidth int   // srcset candidate width to aim for, 0 picks the largest
+}
+
+// extractImages extracts all images from HTML content, including lazy-loaded
+// and responsive images, figure captions and the page's og:image
+func extractImages(htmlContent string, baseURL *url.URL, opts imageOptions) ([]ImageInfo, error) {
+	var images []ImageInfo
+
+	imgRegex := regexp.MustCompile(`(?i)<img\s[^>]*>`)
+	sourceRegex := regexp.MustCompile(`(?i)<source\s[^>]*>`)
+
+	pictureSpans := elementSpans(htmlContent, "picture")
+	figureSpans := elementSpans(htmlContent, "figure")
+	figcaptionRegex := regexp.MustCompile(`(?is)<figcaption[^>]*>(.*?)</figcaption>`)
+
+	seen := make(map[string]bool)
+
+	add := func(info ImageInfo, src string) {
+		if src == "" || strings.HasPrefix(src, "data:") {
+			return
+		}
+		imgURL, err := resolveURL(baseURL, src)
+		if err != nil {
+			return
+		}
+		info.OriginalURL = imgURL.String()
+		if seen[info.OriginalURL] || isTinyImage(info.Width, info.Height, opts.MinDimension) {
+			return
+		}
+		seen[info.OriginalURL] = true
+		images = append(images, info)
+	}
+
+	for _, loc := range imgRegex.FindAllStringIndex(htmlContent, -1) {
+		imgTag := htmlContent[loc[0]:loc[1]]
+
+		imageInfo := ImageInfo{
+			Alt:    tagAttr(imgTag, "alt"),
+			Source: "img",
+		}
+		fmt.Sscanf(tagAttr(imgTag, "width"), "%d", &imageInfo.Width)
+		fmt.Sscanf(tagAttr(imgTag, "height"), "%d", &imageInfo.Height)
+
+		candidates := imageCandidates(imgTag)
+
+		// Inside <picture>, every <source srcset> is a candidate for the same image
+		if i := spanContaining(pictureSpans, loc[0]); i >= 0 {
+			picture := htmlContent[pictureSpans[i][0]:pictureSpans[i][1]]
+			for _, sourceTag := range sourceRegex.FindAllString(picture, -1) {
+				candidates = append(candidates, parseSrcset(tagAttr(sourceTag, "srcset"))...)
+				candidates = append(candidates, parseSrcset(tagAttr(sourceTag, "data-srcset"))...)
+			}
+			imageInfo.Source = "picture"
+		}
+
+		if i := spanContaining(figureSpans, loc[0]); i >= 0 {
+			figure := htmlContent[figureSpans[i][0]:figureSpans[i][1]]
+			if match := figcaptionRegex.FindStringSubmatch(figure); len(match) >= 2 {
+				imageInfo.Caption = htmlText(match[1])
+			}
+		}
+
+		add(imageInfo, selectImageCandidate(candidates, lazyImageSrc(imgTag), opts.PreferredWidth))
+	}
+
+	if ogImage := metaContent(htmlContent, "og:image", "og:image:url", "og:image:secure_url", "twitter:image"); ogImage != "" {
+		add(ImageInfo{
+			Alt:    metaContent(htmlContent, "og:image:alt", "twitter:image:alt"),
+			Source: "og:image",
+		}, ogImage)
+	}
+
+	if opts.KeepDataURL {
+		images = downloadImages(images, opts)
+	}
+
+	return images, nil
+}
+
+// imageCandidate is one entry of a srcset attribute
+type imageCandidate struct {
+	URL     string
+	Width   int     // from a "640w" descriptor
+	Density float64 // from a "2x" descriptor
+}
+
+// imageCandidates collects srcset candidates from an <img> tag's responsive and lazy-load attributes
+func imageCandidates(imgTag string) []imageCandidate {
+	var candidates []imageCandidate
+	for _, attr := range []string{"data-srcset", "data-lazy-srcset", "srcset"} {
+		candidates = append(candidates, parseSrcset(tagAttr(imgTag, attr))...)
+	}
+	return candidates
+}
+
+// lazyImageSrc returns the real image source of an <img>, preferring lazy-load
+// attributes over src, which on lazy pages is usually a placeholder
+func lazyImageSrc(imgTag string) string {
+	for _, attr := range []string{"data-src", "data-original", "data-lazy-src", "data-url", "src"} {
+		if v := strings.TrimSpace(tagAttr(imgTag, attr)); v != "" && !strings.HasPrefix(v, "data:") {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseSrcset splits a srcset attribute into candidates. URLs may contain
+// commas, so each URL runs until whitespace and its descriptor until a comma.
+func parseSrcset(srcset string) []imageCandidate {
+	var candidates []imageCandidate
+
+	rest := strings.TrimSpace(srcset)
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " \t\n\r,")
+		if rest == "" {
+			break
+		}
+
+		end := strings.IndexAny(rest, " \t\n\r")
+		if end < 0 {
+			end = len(rest)
+		}
+		candidateURL := rest[:end]
+		rest = rest[end:]
+
+		descriptor := ""
+		if strings.HasSuffix(candidateURL, ",") {
+			candidateURL = strings.TrimRight(candidateURL, ",")
+		} else {
+			comma := strings.Index(rest, ",")
+			if comma < 0 {
+				comma = len(rest)
+			}
+			descriptor = strings.TrimSpace(rest[:comma])
+			rest = rest[comma:]
+		}
+
+		if candidateURL == "" || strings.HasPrefix(candidateURL, "data:") {
+			continue
+		}
+
+		candidate := imageCandidate{URL: candidateURL, Density: 1}
+		switch {
+		case strings.HasSuffix(descriptor, "w"):
+			fmt.Sscanf(descriptor, "%dw", &candidate.Width)
+		case strings.HasSuffix(descriptor, "x"):
+			fmt.Sscanf(descriptor, "%gx", &candidate.Density)
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	return candidates
+}
+
+// selectImageCandidate picks the srcset candidate closest to the preferred width:
+// the smallest one at least that wide, otherwise the largest available. With no
+// preferred width the largest candidate wins. Falls back to src without candidates.
+func selectImageCandidate(candidates []imageCandidate, src string, preferredWidth int) string {
+	if len(candidates) == 0 {
+		return src
+	}
+
+	var best *imageCandidate
+	for i := range candidates {
+		c := &candidates[i]
+		if best == nil {
+			best = c
+			continue
+		}
+
+		if c.Width > 0 || best.Width > 0 {
+			if preferredWidth > 0 && c.Width >= preferredWidth && (best.Width < preferredWidth || c.Width < best.Width) {
+				best = c
+			} else if (preferredWidth <= 0 || best.Width < preferredWidth) && c.Width > best.Width {
+				best = c
+			}
+			continue
+		}
+
+		// Density descriptors only: prefer 1x when a width is requested, else the sharpest
+		if preferredWidth > 0 {
+			if c.Density < best.Density {
+				best = c
+			}
+		} else if c.Density > best.Density {
+			best = c
+		}
+	}
+
+	return best.URL
+}
+
+// imageBudget is the byte budget shared by the workers of one downloadImages call
+type imageBudget struct {
+	mu        sync.Mutex
+	remaining int64
+}
+
+// reserve takes the expected size of an image from the budget before its body
+// is read. An unknown size (-1) reserves what is left, up to maxImageSize.
+// It returns 0 when the image does not fit.
+func (b *imageBudget) reserve(expected int64) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if expected < 0 {
+		expected = min(b.remaining, maxImageSize)
+	}
+	if expected <= 0 || expected > b.remaining {
+		return 0
+	}
+	b.remaining -= expected
+	return expected
+}
+
+// settle gives back the part of a reservation that the stored image does not use
+func (b *imageBudget) settle(reserved, used int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remaining += reserved - used
+}
+
+func (b *imageBudget) exhausted() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.remaining <= 0
+}
+
+// downloadImages fills in data URLs using a bounded worker pool, stopping
+// once the per-call image count or byte budget is used up. Images that
+// decode to tracking-pixel sizes are dropped from the returned slice.
+func downloadImages(images []ImageInfo, opts imageOptions) []ImageInfo {
+	maxImages := opts.MaxImages
+	if maxImages <= 0 {
+		maxImages = defaultMaxImages
+	}
+	budget := &imageBudget{remaining: opts.MaxBytes}
+	if budget.remaining <= 0 {
+		budget.remaining = defaultMaxImageBytes
+	}
+
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	tiny := make([]bool, len(images))
+
+	for w := 0; w < imageDownloadWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if budget.exhausted() {
+					images[i].Status = imageStatusSkipped
+					continue
+				}
+
+				data, contentType, reserved, err := downloadImage(images[i].OriginalURL, budget)
+				if err != nil {
+					images[i].Status = imageDownloadStatus(err)
+					log.Printf("Image download %s: %v", images[i].OriginalURL, err)
+					continue
+				}
+
+				if width, height, format, err := decodeImageConfig(data); err == nil {
+					images[i].Width = width
+					images[i].Height = height
+					images[i].Format = format
+					contentType = "image/" + format
+
+					if isTinyImage(width, height, opts.MinDimension) {
+						budget.settle(reserved, 0)
+						tiny[i] = true
+						continue
+					}
+
+					if opts.MaxEdge > 0 && (width > opts.MaxEdge || height > opts.MaxEdge) {
+						if scaled, scaledType, err := downscaleImage(data, opts.MaxEdge); err == nil && len(scaled) < len(data) {
+							data = scaled
+							contentType = scaledType
+							images[i].Resized = true
+						} else if err != nil {
+							log.Printf("Image downscale %s: %v", images[i].OriginalURL, err)
+						}
+					}
+				}
+
+				size := int64(len(data))
+				budget.settle(reserved, size)
+				images[i].DataURL = fmt.Sprintf("data:%s;base64,%s", contentType, base64.StdEncoding.EncodeToString(data))
+				images[i].Size = size
+				images[i].Status = imageStatusOK
+			}
+		}()
+	}
+
+	for i := range images {
+		if i >= maxImages {
+			images[i].Status = imageStatusSkipped
+			continue
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	kept := images[:0]
+	for i, img := range images {
+		if !tiny[i] {
+			kept = append(kept, img)
+		}
+	}
+	return kept
+}
+
+// imageDownloadStatus maps a download error to an ImageInfo status
+func imageDownloadStatus(err error) string {
+	if errors.Is(err, errImageTooLarge) {
+		return imageStatusTooLarge
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return imageStatusTimeout
+	}
+	return imageStatusFailed
+}
+
+// downloadImage downloads an image and returns its bytes, content type and
+// the bytes reserved for it from the budget, which the caller settles once it
+// knows the stored size. Images over maxImageSize or the remaining budget fail
+// with errImageTooLarge before their body is read.
+func downloadImage(imgURL string, budget *imageBudget) ([]byte, string, int64, error) {
+	client := &http.Client{
+		Timeout:   imageDownloadTimeout,
+		Transport: insecureTransport(),
+	}
+
+	req, err := http.NewRequest("GET", imgURL, nil)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := doLimited(client, req)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", 0, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	if resp.ContentLength > maxImageSize {
+		return nil, "", 0, fmt.Errorf("%w: %d bytes", errImageTooLarge, resp.ContentLength)
+	}
+
+	if resp.ContentLength == 0 {
+		return nil, "", 0, fmt.Errorf("empty response")
+	}
+
+	reserved := budget.reserve(resp.ContentLength)
+	if reserved == 0 {
+		return nil, "", 0, fmt.Errorf("%w: does not fit in the remaining image budget", errImageTooLarge)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, reserved+1))
+	if err != nil {
+		budget.settle(reserved, 0)
+		return nil, "", 0, err
+	}
+
+	if int64(len(data)) > reserved {
+		budget.settle(reserved, 0)
+		return nil, "", 0, fmt.Errorf("%w: more than %d bytes", errImageTooLarge, reserved)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	return data, contentType, reserved, nil
+}
+
+// buildImageContent turns downloaded images into MCP image content blocks,
+// each preceded by a caption with its alt text and source URL
+func buildImageContent(images []ImageInfo) []interface{} {
+	var content []interface{}
+
+	n := 0
+	for _, img := range images {
+		mimeType, data, ok := splitDataURL(img.DataURL)
+		if !ok || !strings.HasPrefix(mimeType, "image/") {
+			continue
+		}
+		n++
+
+		label := img.Caption
+		if label == "" {
+			label = img.Alt
+		}
+
+		caption := fmt.Sprintf("Image %d: %s", n, img.OriginalURL)
+		if label != "" {
+			caption = fmt.Sprintf("Image %d: %s (%s)", n, label, img.OriginalURL)
+		}
+
+		content = append(content,
+			TextContent{
+				Type: "text",
+				Text: caption,
+			},
+			ImageContent{
+				Type: "image",
+				Data: data,
+				MIME: mimeType,
+			},
+		)
+	}
+
+	return content
+}
+
+// splitDataURL returns the MIME type and base64 payload of a base64 data URL
+func splitDataURL(dataURL string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(dataURL, "data:")
+	if !ok {
+		return "", "", false
+	}
+	header, data, ok := strings.Cut(rest, ",")
+	if !ok {
+		return "", "", false
+	}
+	mimeType, ok := strings.CutSuffix(header, ";base64")
+	if !ok {
+		return "", "", false
+	}
+	// Drop parameters such as charset that some servers append
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return mimeType, data, true
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSrcset(t *testing.T) {
+	tests := []struct {
+		name   string
+		srcset string
+		want   []imageCandidate
+	}{
+		{
+			name:   "empty",
+			srcset: "  ",
+			want:   nil,
+		},
+		{
+			name:   "width descriptors",
+			srcset: "small.jpg 320w, large.jpg 1024w",
+			want: []imageCandidate{
+				{URL: "small.jpg", Width: 320, Density: 1},
+				{URL: "large.jpg", Width: 1024, Density: 1},
+			},
+		},
+		{
+			name:   "density descriptors",
+			srcset: "a.png 1x,a@2x.png 2x, a@1.5x.png 1.5x",
+			want: []imageCandidate{
+				{URL: "a.png", Density: 1},
+				{URL: "a@2x.png", Density: 2},
+				{URL: "a@1.5x.png", Density: 1.5},
+			},
+		},
+		{
+			name:   "no descriptor",
+			srcset: "only.jpg",
+			want:   []imageCandidate{{URL: "only.jpg", Density: 1}},
+		},
+		{
+			name:   "trailing comma without descriptor",
+			srcset: "a.jpg, b.jpg 2x",
+			want: []imageCandidate{
+				{URL: "a.jpg", Density: 1},
+				{URL: "b.jpg", Density: 2},
+			},
+		},
+		{
+			name:   "commas inside URLs",
+			srcset: "https://cdn.example.com/img/w_400,h_300/pic.jpg 400w, https://cdn.example.com/img/w_800,h_600/pic.jpg 800w",
+			want: []imageCandidate{
+				{URL: "https://cdn.example.com/img/w_400,h_300/pic.jpg", Width: 400, Density: 1},
+				{URL: "https://cdn.example.com/img/w_800,h_600/pic.jpg", Width: 800, Density: 1},
+			},
+		},
+		{
+			name:   "data URLs skipped",
+			srcset: "data:image/gif;base64,R0lGOD 1x, real.jpg 2x",
+			want:   []imageCandidate{{URL: "real.jpg", Density: 2}},
+		},
+		{
+			name:   "newlines between candidates",
+			srcset: "\n  a.jpg 480w,\n  b.jpg 960w\n",
+			want: []imageCandidate{
+				{URL: "a.jpg", Width: 480, Density: 1},
+				{URL: "b.jpg", Width: 960, Density: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSrcset(tt.srcset); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSrcset(%q) = %+v, want %+v", tt.srcset, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageCandidates(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  string
+		want []imageCandidate
+	}{
+		{
+			name: "plain src only",
+			tag:  `<img src="a.jpg" alt="A">`,
+			want: nil,
+		},
+		{
+			name: "srcset",
+			tag:  `<img src="a.jpg" srcset="a-1x.jpg 1x, a-2x.jpg 2x">`,
+			want: []imageCandidate{
+				{URL: "a-1x.jpg", Density: 1},
+				{URL: "a-2x.jpg", Density: 2},
+			},
+		},
+		{
+			name: "lazy srcset before srcset",
+			tag:  `<img srcset="placeholder.jpg 100w" data-srcset="real-800.jpg 800w">`,
+			want: []imageCandidate{
+				{URL: "real-800.jpg", Width: 800, Density: 1},
+				{URL: "placeholder.jpg", Width: 100, Density: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := imageCandidates(tt.tag); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("imageCandidates(%q) = %+v, want %+v", tt.tag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectImageCandidate(t *testing.T) {
+	widths := []imageCandidate{
+		{URL: "400.jpg", Width: 400, Density: 1},
+		{URL: "800.jpg", Width: 800, Density: 1},
+		{URL: "1600.jpg", Width: 1600, Density: 1},
+	}
+	densities := []imageCandidate{
+		{URL: "1x.jpg", Density: 1},
+		{URL: "2x.jpg", Density: 2},
+	}
+
+	tests := []struct {
+		name       string
+		candidates []imageCandidate
+		preferred  int
+		want       string
+	}{
+		{"no candidates falls back to src", nil, 0, "src.jpg"},
+		{"largest without preference", widths, 0, "1600.jpg"},
+		{"smallest at least preferred", widths, 700, "800.jpg"},
+		{"exact preferred width", widths, 400, "400.jpg"},
+		{"largest when all too small", widths, 2000, "1600.jpg"},
+		{"sharpest density without preference", densities, 0, "2x.jpg"},
+		{"1x density with preference", densities, 640, "1x.jpg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectImageCandidate(tt.candidates, "src.jpg", tt.preferred); got != tt.want {
+				t.Errorf("selectImageCandidate(preferred %d) = %q, want %q", tt.preferred, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageBudget(t *testing.T) {
+	b := &imageBudget{remaining: 100}
+
+	steps := []struct {
+		name      string
+		do        func() int64
+		want      int64 // returned reservation, -1 for settle
+		remaining int64
+	}{
+		{"reserve a known size", func() int64 { return b.reserve(60) }, 60, 40},
+		{"reserve more than is left", func() int64 { return b.reserve(50) }, 0, 40},
+		{"reserve an empty image", func() int64 { return b.reserve(0) }, 0, 40},
+		{"reserve an unknown size takes the rest", func() int64 { return b.reserve(-1) }, 40, 0},
+		{"settle a smaller stored image", func() int64 { b.settle(40, 10); return -1 }, -1, 30},
+		{"settle a failed download", func() int64 { b.settle(60, 0); return -1 }, -1, 90},
+	}
+
+	for _, step := range steps {
+		if got := step.do(); got != step.want {
+			t.Errorf("%s: got %d, want %d", step.name, got, step.want)
+		}
+		if b.remaining != step.remaining {
+			t.Errorf("%s: remaining %d, want %d", step.name, b.remaining, step.remaining)
+		}
+	}
+	if b.exhausted() {
+		t.Error("exhausted() with 90 bytes left")
+	}
+	b.reserve(90)
+	if !b.exhausted() {
+		t.Error("exhausted() = false with nothing left")
+	}
+}
+
+// testPNG encodes a blank PNG of the given size
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDownloadImages(t *testing.T) {
+	useTestLimiter(t)
+
+	square := testPNG(t, 16, 16)
+	pixel := testPNG(t, 1, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/square.png":
+			w.Write(square)
+		case "/pixel.png":
+			w.Write(pixel)
+		case "/chunked.png":
+			// Flushing before the body hides its size, as chunked responses do
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			w.Write(square)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	size := int64(len(square))
+	imageURLs := func(paths ...string) []ImageInfo {
+		images := make([]ImageInfo, len(paths))
+		for i, p := range paths {
+			images[i] = ImageInfo{OriginalURL: server.URL + p}
+		}
+		return images
+	}
+	countStatus := func(images []ImageInfo) map[string]int {
+		counts := make(map[string]int)
+		for _, img := range images {
+			counts[img.Status]++
+		}
+		return counts
+	}
+
+	tests := []struct {
+		name   string
+		paths  []string
+		opts   imageOptions
+		want   map[string]int
+		images int // images returned after tiny ones are dropped
+	}{
+		{
+			// An unknown size holds up to maxImageSize while it downloads, which the default budget leaves room for
+			name:   "all fit",
+			paths:  []string{"/square.png", "/square.png", "/chunked.png"},
+			want:   map[string]int{imageStatusOK: 3},
+			images: 3,
+		},
+		{
+			name:   "byte budget shared by the workers",
+			paths:  []string{"/square.png", "/square.png", "/square.png", "/square.png"},
+			opts:   imageOptions{MaxBytes: 2*size + size/2},
+			want:   map[string]int{imageStatusOK: 2, imageStatusTooLarge: 2},
+			images: 4,
+		},
+		{
+			name:   "image count limit",
+			paths:  []string{"/square.png", "/square.png", "/square.png"},
+			opts:   imageOptions{MaxImages: 1},
+			want:   map[string]int{imageStatusOK: 1, imageStatusSkipped: 2},
+			images: 3,
+		},
+		{
+			name:   "failed download",
+			paths:  []string{"/missing.png", "/square.png"},
+			want:   map[string]int{imageStatusFailed: 1, imageStatusOK: 1},
+			images: 2,
+		},
+		{
+			name:   "tracking pixel dropped",
+			paths:  []string{"/pixel.png", "/square.png"},
+			opts:   imageOptions{MinDimension: defaultMinImageDimension},
+			want:   map[string]int{imageStatusOK: 1},
+			images: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := downloadImages(imageURLs(tt.paths...), tt.opts)
+			if len(got) != tt.images {
+				t.Fatalf("downloadImages() returned %d images, want %d", len(got), tt.images)
+			}
+			if counts := countStatus(got); !reflect.DeepEqual(counts, tt.want) {
+				t.Errorf("statuses = %v, want %v", counts, tt.want)
+			}
+
+			var stored int64
+			for _, img := range got {
+				if img.Status == imageStatusOK {
+					stored += img.Size
+					if img.Width != 16 || img.Format != "png" || !strings.HasPrefix(img.DataURL, "data:image/png;base64,") {
+						t.Errorf("downloaded image = %+v, want a 16px PNG data URL", img)
+					}
+				}
+			}
+			if tt.opts.MaxBytes > 0 && stored > tt.opts.MaxBytes {
+				t.Errorf("stored %d bytes, over the %d byte budget", stored, tt.opts.MaxBytes)
+			}
+		})
+	}
+}
+
+func TestSplitDataURL(t *testing.T) {
+	tests := []struct {
+		dataURL  string
+		mimeType string
+		data     string
+		ok       bool
+	}{
+		{"data:image/png;base64,iVBORw0", "image/png", "iVBORw0", true},
+		{"data:image/svg+xml;charset=utf-8;base64,PHN2Zz4", "image/svg+xml", "PHN2Zz4", true},
+		{"data:image/svg+xml,<svg></svg>", "", "", false},
+		{"data:image/png;base64", "", "", false},
+		{"https://example.com/a.png", "", "", false},
+		{"", "", "", false},
+	}
+	for _, tt := range tests {
+		mimeType, data, ok := splitDataURL(tt.dataURL)
+		if mimeType != tt.mimeType || data != tt.data || ok != tt.ok {
+			t.Errorf("splitDataURL(%q) = %q, %q, %v, want %q, %q, %v", tt.dataURL, mimeType, data, ok, tt.mimeType, tt.data, tt.ok)
+		}
+	}
+}
+
+func TestBuildImageContent(t *testing.T) {
+	images := []ImageInfo{
+		{OriginalURL: "https://example.com/a.png", Alt: "Diagram", DataURL: "data:image/png;base64,AAAA"},
+		{OriginalURL: "https://example.com/b.png", Status: imageStatusTooLarge},
+		{OriginalURL: "https://example.com/c.svg", DataURL: "data:text/html;base64,PGh0bWw+"},
+		{OriginalURL: "https://example.com/d.jpg", Alt: "ignored", Caption: "Figure 2", DataURL: "data:image/jpeg;base64,BBBB"},
+		{OriginalURL: "https://example.com/e.gif", DataURL: "data:image/gif;base64,CCCC"},
+	}
+
+	want := []interface{}{
+		TextContent{Type: "text", Text: "Image 1: Diagram (https://example.com/a.png)"},
+		ImageContent{Type: "image", Data: "AAAA", MIME: "image/png"},
+		TextContent{Type: "text", Text: "Image 2: Figure 2 (https://example.com/d.jpg)"},
+		ImageContent{Type: "image", Data: "BBBB", MIME: "image/jpeg"},
+		TextContent{Type: "text", Text: "Image 3: https://example.com/e.gif"},
+		ImageContent{Type: "image", Data: "CCCC", MIME: "image/gif"},
+	}
+	if got := buildImageContent(images); !reflect.DeepEqual(got, want) {
+		t.Errorf("buildImageContent() = %+v, want %+v", got, want)
+	}
+	if got := buildImageContent(images[1:2]); got != nil {
+		t.Errorf("buildImageContent() without downloads = %+v, want nil", got)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestValidateJSONSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		value  string
+		want   []string
+	}{
+		{
+			name:   "valid object",
+			schema: `{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer","minimum":0}},"required":["name"]}`,
+			value:  `{"name":"Ada","age":36}`,
+		},
+		{
+			name:   "type mismatch stops further checks",
+			schema: `{"type":"string","minLength":3}`,
+			value:  `12`,
+			want:   []string{"$: expected string, got number"},
+		},
+		{
+			name:   "type list",
+			schema: `{"type":["string","null"]}`,
+			value:  `null`,
+		},
+		{
+			name:   "integer rejects fractions",
+			schema: `{"type":"integer"}`,
+			value:  `1.5`,
+			want:   []string{"$: expected integer, got number"},
+		},
+		{
+			name:   "missing required and nested path",
+			schema: `{"type":"object","required":["title","author"],"properties":{"author":{"type":"object","properties":{"name":{"type":"string"}}}}}`,
+			value:  `{"author":{"name":7}}`,
+			want: []string{
+				`$: missing required property "title"`,
+				"$.author.name: expected string, got number",
+			},
+		},
+		{
+			name:   "additionalProperties false",
+			schema: `{"type":"object","properties":{"a":{}},"additionalProperties":false}`,
+			value:  `{"a":1,"c":2,"b":3}`,
+			want: []string{
+				`$: unexpected property "b"`,
+				`$: unexpected property "c"`,
+			},
+		},
+		{
+			name:   "additionalProperties schema",
+			schema: `{"type":"object","additionalProperties":{"type":"number"}}`,
+			value:  `{"x":1,"y":"two"}`,
+			want:   []string{"$.y: expected number, got string"},
+		},
+		{
+			name:   "array items and bounds",
+			schema: `{"type":"array","items":{"type":"string"},"minItems":1,"maxItems":2}`,
+			value:  `["a",2,"c"]`,
+			want: []string{
+				"$: must have at most 2 items",
+				"$[1]: expected string, got number",
+			},
+		},
+		{
+			name:   "uniqueItems",
+			schema: `{"type":"array","uniqueItems":true}`,
+			value:  `[{"a":1},{"a":1},2]`,
+			want:   []string{"$: items 0 and 1 are equal"},
+		},
+		{
+			name:   "enum and const",
+			schema: `{"enum":["red","green"],"const":"green"}`,
+			value:  `"blue"`,
+			want: []string{
+				`$: must be one of ["red","green"]`,
+				`$: must equal "green"`,
+			},
+		},
+		{
+			name:   "string length counts characters",
+			schema: `{"type":"string","maxLength":3}`,
+			value:  `"日本語"`,
+		},
+		{
+			name:   "pattern",
+			schema: `{"type":"string","pattern":"^[A-Z]{3}$"}`,
+			value:  `"usd"`,
+			want:   []string{"$: must match pattern ^[A-Z]{3}$"},
+		},
+		{
+			name:   "formats",
+			schema: `{"type":"object","properties":{"d":{"format":"date"},"t":{"format":"date-time"},"e":{"format":"email"},"u":{"format":"uri"}}}`,
+			value:  `{"d":"2024-02-30","t":"2024-01-01T10:00:00Z","e":"not an email","u":"/relative"}`,
+			want: []string{
+				"$.d: must be a valid date",
+				"$.e: must be a valid email",
+				"$.u: must be a valid uri",
+			},
+		},
+		{
+			name:   "numeric bounds",
+			schema: `{"type":"number","minimum":0,"exclusiveMaximum":10}`,
+			value:  `10`,
+			want:   []string{"$: must be < 10"},
+		},
+		{
+			name:   "anyOf",
+			schema: `{"anyOf":[{"type":"string"},{"type":"integer"}]}`,
+			value:  `true`,
+			want:   []string{"$: must match at least one schema in anyOf"},
+		},
+		{
+			name:   "oneOf matching two",
+			schema: `{"oneOf":[{"type":"number"},{"minimum":0}]}`,
+			value:  `5`,
+			want:   []string{"$: must match exactly one schema in oneOf"},
+		},
+		{
+			name:   "allOf and not",
+			schema: `{"allOf":[{"type":"string"},{"minLength":2}],"not":{"const":"no"}}`,
+			value:  `"no"`,
+			want:   []string{"$: must not match the schema in not"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var schema map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.schema), &schema); err != nil {
+				t.Fatalf("bad schema: %v", err)
+			}
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.value), &value); err != nil {
+				t.Fatalf("bad value: %v", err)
+			}
+			if got := validateJSONSchema(schema, value, ""); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateJSONSchema() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
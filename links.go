@@ -0,0 +1,226 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Link kinds reported in LinkInfo.Kind
+const (
+	linkKindInternal = "internal"
+	linkKindExternal = "external"
+	linkKindSamePage = "same_page"
+)
+
+// Page regions reported in LinkInfo.Region
+const (
+	linkRegionMain   = "main"
+	linkRegionNav    = "nav"
+	linkRegionHeader = "header"
+	linkRegionFooter = "footer"
+	linkRegionAside  = "aside"
+)
+
+// linkFileTypes maps download-like extensions to the file type hint reported in LinkInfo.FileType
+var linkFileTypes = map[string]string{
+	".pdf": "pdf", ".zip": "zip", ".gz": "archive", ".tgz": "archive", ".tar": "archive",
+	".rar": "archive", ".7z": "archive", ".doc": "doc", ".docx": "doc", ".xls": "spreadsheet",
+	".xlsx": "spreadsheet", ".csv": "csv", ".ppt": "presentation", ".pptx": "presentation",
+	".txt": "text", ".json": "json", ".xml": "xml", ".epub": "epub", ".exe": "binary",
+	".dmg": "binary", ".pkg": "binary", ".deb": "binary", ".rpm": "binary", ".apk": "binary",
+	".msi": "binary", ".mp3": "audio", ".wav": "audio", ".mp4": "video", ".mov": "video",
+	".webm": "video", ".png": "image", ".jpg": "image", ".jpeg": "image", ".gif": "image",
+	".svg": "image", ".webp": "image",
+}
+
+// linkFilter narrows extracted links; empty fields match everything
+type linkFilter struct {
+	Kinds           []string
+	Regions         []string
+	ExcludeNofollow bool
+}
+
+// extractLinks extracts all links from HTML content, annotated with kind,
+// rel values, target, file type and the page region they appear in
+func extractLinks(htmlContent string, baseURL *url.URL) []LinkInfo {
+	var links []LinkInfo
+
+	linkRegex := regexp.MustCompile(`(?is)(<a\s[^>]*>)(.*?)</a>`)
+	matches := linkRegex.FindAllStringSubmatchIndex(htmlContent, -1)
+
+	regions := newRegionLocator(htmlContent)
+	seen := make(map[string]bool)
+
+	for _, match := range matches {
+		openTag := htmlContent[match[2]:match[3]]
+		linkText := htmlText(htmlContent[match[4]:match[5]])
+
+		href := strings.TrimSpace(tagAttr(openTag, "href"))
+		if href == "" {
+			continue
+		}
+
+		lower := strings.ToLower(href)
+		if strings.HasPrefix(lower, "javascript:") ||
+			strings.HasPrefix(lower, "mailto:") ||
+			strings.HasPrefix(lower, "tel:") ||
+			strings.HasPrefix(lower, "data:") {
+			continue
+		}
+
+		linkURL, err := resolveURL(baseURL, href)
+		if err != nil {
+			continue
+		}
+
+		linkStr := linkURL.String()
+
+		if seen[linkStr] {
+			continue
+		}
+		seen[linkStr] = true
+
+		linkInfo := LinkInfo{
+			URL:      linkStr,
+			Text:     linkText,
+			Title:    tagAttr(openTag, "title"),
+			Kind:     classifyLink(baseURL, linkURL),
+			Rel:      strings.Fields(strings.ToLower(tagAttr(openTag, "rel"))),
+			Target:   tagAttr(openTag, "target"),
+			FileType: linkFileType(linkURL),
+			Region:   regions.regionAt(match[0]),
+		}
+
+		links = append(links, linkInfo)
+	}
+
+	return links
+}
+
+// classifyLink reports whether a resolved link points to the same page, the same host or elsewhere
+func classifyLink(base, link *url.URL) string {
+	if base == nil {
+		return linkKindExternal
+	}
+	if !strings.EqualFold(base.Hostname(), link.Hostname()) {
+		return linkKindExternal
+	}
+	if link.Fragment != "" && link.Path == base.Path && link.RawQuery == base.RawQuery {
+		return linkKindSamePage
+	}
+	return linkKindInternal
+}
+
+// linkFileType returns a file type hint from the link's path extension
+func linkFileType(link *url.URL) string {
+	return linkFileTypes[strings.ToLower(path.Ext(link.Path))]
+}
+
+// hasRel reports whether the link carries the given rel value
+func (l LinkInfo) hasRel(rel string) bool {
+	for _, r := range l.Rel {
+		if r == rel {
+			return true
+		}
+	}
+	return false
+}
+
+// filterLinks keeps the links that match every non-empty criterion of the filter
+func filterLinks(links []LinkInfo, filter linkFilter) []LinkInfo {
+	if len(filter.Kinds) == 0 && len(filter.Regions) == 0 && !filter.ExcludeNofollow {
+		return links
+	}
+
+	var filtered []LinkInfo
+	for _, link := range links {
+		if len(filter.Kinds) > 0 && !containsFold(filter.Kinds, link.Kind) {
+			continue
+		}
+		if len(filter.Regions) > 0 && !containsFold(filter.Regions, link.Region) {
+			continue
+		}
+		if filter.ExcludeNofollow && (link.hasRel("nofollow") || link.hasRel("sponsored") || link.hasRel("ugc")) {
+			continue
+		}
+		filtered = append(filtered, link)
+	}
+	return filtered
+}
+
+// containsFold reports whether values contains s, ignoring case
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// regionLocator maps byte offsets in a page to the landmark element they sit in
+type regionLocator struct {
+	spans   [][]int
+	names   []string
+	hasMain bool
+}
+
+func newRegionLocator(htmlContent string) *regionLocator {
+	loc := &regionLocator{}
+	for _, tag := range []string{"nav", "header", "footer", "aside", "main", "article"} {
+		region := tag
+		if tag == "article" {
+			region = linkRegionMain
+		}
+		for _, span := range elementSpans(htmlContent, tag) {
+			loc.spans = append(loc.spans, span)
+			loc.names = append(loc.names, region)
+			if region == linkRegionMain {
+				loc.hasMain = true
+			}
+		}
+	}
+	return loc
+}
+
+// regionAt returns the innermost landmark containing pos. Content outside any
+// landmark counts as main content when the page has no <main> or <article>.
+func (r *regionLocator) regionAt(pos int) string {
+	best := -1
+	for i, span := range r.spans {
+		if pos >= span[0] && pos < span[1] && (best < 0 || span[0] > r.spans[best][0]) {
+			best = i
+		}
+	}
+	if best >= 0 {
+		return r.names[best]
+	}
+	if !r.hasMain {
+		return linkRegionMain
+	}
+	return ""
+}
+
+// landmarkRegion is regionAt for a parsed element: the innermost landmark
+// enclosing n, with the same fallback when the page has no main content element
+func landmarkRegion(n *html.Node, hasMain bool) string {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type != html.ElementNode {
+			continue
+		}
+		switch p.Data {
+		case "nav", "header", "footer", "aside":
+			return p.Data
+		case "main", "article":
+			return linkRegionMain
+		}
+	}
+	if !hasMain {
+		return linkRegionMain
+	}
+	return ""
+}
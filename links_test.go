@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestClassifyLink(t *testing.T) {
+	base, _ := url.Parse("https://example.com/docs/page?v=1")
+
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"https://example.com/docs/other", linkKindInternal},
+		{"https://EXAMPLE.com/", linkKindInternal},
+		{"http://example.com:8080/docs/page", linkKindInternal},
+		{"https://example.com/docs/page?v=1#install", linkKindSamePage},
+		{"https://example.com/docs/page?v=2#install", linkKindInternal},
+		{"https://example.com/docs/page?v=1", linkKindInternal},
+		{"https://docs.example.com/docs/page", linkKindExternal},
+		{"https://other.example/", linkKindExternal},
+	}
+	for _, tt := range tests {
+		link, _ := url.Parse(tt.link)
+		if got := classifyLink(base, link); got != tt.want {
+			t.Errorf("classifyLink(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+
+	link, _ := url.Parse("https://example.com/")
+	if got := classifyLink(nil, link); got != linkKindExternal {
+		t.Errorf("classifyLink(nil base) = %q, want %q", got, linkKindExternal)
+	}
+}
+
+func TestLinkFileType(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"https://example.com/report.PDF", "pdf"},
+		{"https://example.com/src.tar.gz", "archive"},
+		{"https://example.com/data.csv?download=1", "csv"},
+		{"https://example.com/page.html", ""},
+		{"https://example.com/docs/", ""},
+	}
+	for _, tt := range tests {
+		link, _ := url.Parse(tt.link)
+		if got := linkFileType(link); got != tt.want {
+			t.Errorf("linkFileType(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+const linksTestHTML = `<html><body>
+<header><a href="/">Home</a></header>
+<nav><a href="/docs/" title="All docs">Docs</a> <a href="#main">Skip</a></nav>
+<main>
+  <p>See <a href="https://other.example/ref" rel="NoFollow Sponsored" target="_blank">the <b>reference</b></a>,
+  <a href="/files/guide.pdf">the PDF</a> and <a href="/docs/">docs again</a>.</p>
+  <aside><a href="/related">Related</a></aside>
+  <a href="javascript:void(0)">JS</a> <a href="mailto:a@example.com">Mail</a> <a name="anchor">No href</a>
+</main>
+<footer><a href="https://social.example/" rel="ugc">Social</a></footer>
+</body></html>`
+
+func TestExtractLinks(t *testing.T) {
+	base, _ := url.Parse("https://example.com/guide")
+
+	want := []LinkInfo{
+		{URL: "https://example.com/", Text: "Home", Kind: linkKindInternal, Region: linkRegionHeader},
+		{URL: "https://example.com/docs/", Text: "Docs", Title: "All docs", Kind: linkKindInternal, Region: linkRegionNav},
+		{URL: "https://example.com/guide#main", Text: "Skip", Kind: linkKindSamePage, Region: linkRegionNav},
+		{URL: "https://other.example/ref", Text: "the reference", Kind: linkKindExternal, Rel: []string{"nofollow", "sponsored"}, Target: "_blank", Region: linkRegionMain},
+		{URL: "https://example.com/files/guide.pdf", Text: "the PDF", Kind: linkKindInternal, FileType: "pdf", Region: linkRegionMain},
+		{URL: "https://example.com/related", Text: "Related", Kind: linkKindInternal, Region: linkRegionAside},
+		{URL: "https://social.example/", Text: "Social", Kind: linkKindExternal, Rel: []string{"ugc"}, Region: linkRegionFooter},
+	}
+	got := extractLinks(linksTestHTML, base)
+	for i := range got {
+		if len(got[i].Rel) == 0 {
+			got[i].Rel = nil // empty and nil both encode as an omitted rel
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extractLinks() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestExtractLinksWithoutMain(t *testing.T) {
+	base, _ := url.Parse("https://example.com/")
+	htmlContent := `<nav><a href="/a">A</a></nav><div><a href="/b">B</a></div>`
+
+	links := extractLinks(htmlContent, base)
+	regions := []string{links[0].Region, links[1].Region}
+	// Without <main> or <article>, content outside the landmarks is the main content
+	if want := []string{linkRegionNav, linkRegionMain}; !reflect.DeepEqual(regions, want) {
+		t.Errorf("regions = %q, want %q", regions, want)
+	}
+}
+
+func TestFilterLinks(t *testing.T) {
+	base, _ := url.Parse("https://example.com/guide")
+	links := extractLinks(linksTestHTML, base)
+
+	urls := func(links []LinkInfo) []string {
+		var out []string
+		for _, l := range links {
+			out = append(out, l.URL)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		filter linkFilter
+		want   []string
+	}{
+		{
+			name:   "external only",
+			filter: linkFilter{Kinds: []string{"EXTERNAL"}},
+			want:   []string{"https://other.example/ref", "https://social.example/"},
+		},
+		{
+			name:   "main and aside",
+			filter: linkFilter{Regions: []string{linkRegionMain, linkRegionAside}},
+			want:   []string{"https://other.example/ref", "https://example.com/files/guide.pdf", "https://example.com/related"},
+		},
+		{
+			name:   "nofollow, sponsored and ugc excluded",
+			filter: linkFilter{Kinds: []string{linkKindExternal}, ExcludeNofollow: true},
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := urls(filterLinks(links, tt.filter)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterLinks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if got := filterLinks(links, linkFilter{}); len(got) != len(links) {
+		t.Errorf("empty filter kept %d of %d links", len(got), len(links))
+	}
+}
@@ -1,8 +1,8 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,9 +10,10 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"regexp"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 // MCP JSON-RPC message structures
@@ -45,9 +46,10 @@ type InitializeResult struct {
 }
 
 type Tool struct {
-	Name        string                 `json:"name"`
-	Description string                 `json:"description"`
-	InputSchema map[string]interface{} `json:"inputSchema"`
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description"`
+	InputSchema  map[string]interface{} `json:"inputSchema"`
+	OutputSchema map[string]interface{} `json:"outputSchema,omitempty"`
 }
 
 type ListToolsResult struct {
@@ -57,19 +59,35 @@ type ListToolsResult struct {
 type CallToolParams struct {
 	Name      string                 `json:"name"`
 	Arguments map[string]interface{} `json:"arguments"`
-	_         string                 `json:"_meta,omitempty"` // Optional metadata
+	Meta      map[string]interface{} `json:"_meta,omitempty"` // Optional metadata
 }
 
 // Tool input structures
 type WebReaderInput struct {
-	URL               string  `json:"url"`
-	Model             string  `json:"model,omitempty"`
-	MaxTokens         int     `json:"maxTokens,omitempty"`
-	Temperature       float64 `json:"temperature,omitempty"`
-	RetainImages      bool    `json:"retain_images,omitempty"`
-	KeepImageDataURL  bool    `json:"keep_img_data_url,omitempty"`
-	WithImagesSummary bool    `json:"with_images_summary,omitempty"`
-	WithLinksSummary  bool    `json:"with_links_summary,omitempty"`
+	URL                 string   `json:"url"`
+	Model               string   `json:"model,omitempty"`
+	MaxTokens           int      `json:"maxTokens,omitempty"`
+	Temperature         float64  `json:"temperature,omitempty"`
+	RetainImages        bool     `json:"retain_images,omitempty"`
+	KeepImageDataURL    bool     `json:"keep_img_data_url,omitempty"`
+	WithImagesSummary   bool     `json:"with_images_summary,omitempty"`
+	WithLinksSummary    bool     `json:"with_links_summary,omitempty"`
+	MaxImages           int      `json:"max_images,omitempty"`
+	MaxImageBytes       int64    `json:"max_image_bytes,omitempty"`
+	ImagesAsContent     bool     `json:"images_as_content,omitempty"`
+	ImageMaxEdge        int      `json:"image_max_edge,omitempty"`
+	MinImageDimension   int      `json:"min_image_dimension,omitempty"`
+	PreferredImageWidth int      `json:"preferred_image_width,omitempty"`
+	MetadataOnly        bool     `json:"metadata_only,omitempty"`
+	LinkKinds           []string `json:"link_kinds,omitempty"`
+	LinkRegions         []string `json:"link_regions,omitempty"`
+	ExcludeNofollow     bool     `json:"exclude_nofollow,omitempty"`
+	IncludeSelector     string   `json:"include_selector,omitempty"`
+	ExcludeSelector     string   `json:"exclude_selector,omitempty"`
+	TablesAsMarkdown    bool     `json:"tables_as_markdown,omitempty"`
+	TargetLanguage      string   `json:"target_language,omitempty"`
+	FidelityCheck       string   `json:"fidelity_check,omitempty"`
+	PromptProfile       string   `json:"prompt_profile,omitempty"`
 }
 
 // AI API structures
@@ -98,6 +116,10 @@ type AIResponse struct {
 		Message string `json:"message"`
 		Type    string `json:"type"`
 	} `json:"error,omitempty"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage,omitempty"`
 }
 
 // Content structures for tool responses
@@ -109,7 +131,7 @@ type TextContent struct {
 type ImageContent struct {
 	Type string `json:"type"`
 	Data string `json:"data"`
-	MIME string `json:"mime_type,omitempty"`
+	MIME string `json:"mimeType"`
 }
 
 // Image and link metadata structures
@@ -120,12 +142,22 @@ type ImageInfo struct {
 	Width       int    `json:"width,omitempty"`
 	Height      int    `json:"height,omitempty"`
 	Size        int64  `json:"size_bytes,omitempty"`
+	Status      string `json:"status,omitempty"` // ok, too_large, timeout, skipped or failed
+	Format      string `json:"format,omitempty"` // decoded format: png, jpeg, gif or webp
+	Resized     bool   `json:"resized,omitempty"`
+	Caption     string `json:"caption,omitempty"` // from an enclosing <figure>'s <figcaption>
+	Source      string `json:"source,omitempty"`  // img, picture or og:image
 }
 
 type LinkInfo struct {
-	URL   string `json:"url"`
-	Text  string `json:"text,omitempty"`
-	Title string `json:"title,omitempty"`
+	URL      string   `json:"url"`
+	Text     string   `json:"text,omitempty"`
+	Title    string   `json:"title,omitempty"`
+	Kind     string   `json:"kind,omitempty"`      // internal, external or same_page
+	Rel      []string `json:"rel,omitempty"`       // e.g. nofollow, sponsored, ugc, next, prev
+	Target   string   `json:"target,omitempty"`    // e.g. _blank
+	FileType string   `json:"file_type,omitempty"` // e.g. pdf, zip, archive
+	Region   string   `json:"region,omitempty"`    // main, nav, header, footer or aside
 }
 
 const (
@@ -133,11 +165,18 @@ const (
 	defaultModel  = "deepseek-ai/DeepSeek-V3"
 	defaultMaxTokens = 4000
 	maxImageSize  = 5 * 1024 * 1024
-	mcpVersion    = "2024-11-05"
+	mcpVersion    = "2025-06-18"
+	userAgent     = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
 )
 
 var (
 	apiKey string
+
+	stdoutMu      sync.Mutex
+	stdoutEncoder = json.NewEncoder(os.Stdout)
+
+	// Protocol versions this server can speak, newest first
+	supportedProtocolVersions = []string{mcpVersion, "2025-03-26", "2024-11-05"}
 )
 
 func main() {
@@ -150,6 +189,11 @@ func main() {
 	log.SetPrefix("[Web-Reader MCP] ")
 	log.Println("Starting Web Reader MCP Server (stdio mode)...")
 
+	configureRateLimits()
+	configureSnapshots()
+	configurePromptProfiles()
+	configureTokenAccounting()
+
 	// Start processing stdin/stdout
 	processStdio()
 }
@@ -157,13 +201,14 @@ func main() {
 // processStdio handles JSON-RPC communication via stdin/stdout
 func processStdio() {
 	decoder := json.NewDecoder(os.Stdin)
-	encoder := json.NewEncoder(os.Stdout)
+	var inFlight sync.WaitGroup
 
 	for {
 		var message JSONRPCMessage
 		if err := decoder.Decode(&message); err != nil {
 			if err == io.EOF {
 				log.Println("Received EOF, shutting down...")
+				inFlight.Wait()
 				return
 			}
 			log.Printf("Error decoding message: %v", err)
@@ -172,12 +217,72 @@ func processStdio() {
 
 		log.Printf("Received message: method=%s, id=%v", message.Method, message.ID)
 
-		response := handleMessage(&message)
-
-		if err := encoder.Encode(response); err != nil {
-			log.Printf("Error encoding response: %v", err)
+		// Tool calls and resource reads can fetch and convert pages for minutes, so
+		// they are handled off the read loop; writeMessage keeps concurrent
+		// responses from interleaving
+		if message.Method == "tools/call" || message.Method == "resources/read" {
+			inFlight.Add(1)
+			go func(message JSONRPCMessage) {
+				defer inFlight.Done()
+				respond(handleMessage(&message))
+			}(message)
+			continue
 		}
+
+		respond(handleMessage(&message))
+	}
+}
+
+// respond writes a response to stdout, logging failures
+func respond(response *JSONRPCMessage) {
+	if err := writeMessage(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// writeMessage encodes a message to stdout. Handlers may send notifications
+// while a request is still being processed, so writes are serialized.
+func writeMessage(msg *JSONRPCMessage) error {
+	stdoutMu.Lock()
+	defer stdoutMu.Unlock()
+	return stdoutEncoder.Encode(msg)
+}
+
+// sendNotification sends a JSON-RPC notification to the client
+func sendNotification(method string, params interface{}) {
+	payload, err := json.Marshal(params)
+	if err != nil {
+		log.Printf("Error encoding %s notification: %v", method, err)
+		return
+	}
+
+	if err := writeMessage(&JSONRPCMessage{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  payload,
+	}); err != nil {
+		log.Printf("Error sending %s notification: %v", method, err)
+	}
+}
+
+// sendProgress reports progress for a request that supplied a progressToken
+func sendProgress(progressToken interface{}, progress, total float64, message string) {
+	if progressToken == nil {
+		return
+	}
+
+	params := map[string]interface{}{
+		"progressToken": progressToken,
+		"progress":      progress,
+	}
+	if total > 0 {
+		params["total"] = total
 	}
+	if message != "" {
+		params["message"] = message
+	}
+
+	sendNotification("notifications/progress", params)
 }
 
 // handleMessage dispatches incoming RPC messages to appropriate handlers
@@ -189,6 +294,20 @@ func handleMessage(msg *JSONRPCMessage) *JSONRPCMessage {
 		return handleListTools(msg)
 	case "tools/call":
 		return handleCallTool(msg)
+	case "prompts/list":
+		return handleListPrompts(msg)
+	case "prompts/get":
+		return handleGetPrompt(msg)
+	case "resources/list":
+		return handleListResources(msg)
+	case "resources/templates/list":
+		return handleListResourceTemplates(msg)
+	case "resources/read":
+		return handleReadResource(msg)
+	case "resources/subscribe":
+		return handleSubscribeResource(msg, true)
+	case "resources/unsubscribe":
+		return handleSubscribeResource(msg, false)
 	case "ping":
 		return handlePing(msg)
 	default:
@@ -219,12 +338,14 @@ func handleInitialize(msg *JSONRPCMessage) *JSONRPCMessage {
 	}
 
 	log.Printf("Initialize request from client: %s", params.ClientInfo["name"])
+	sessionUsage.reset(params.ClientInfo["name"])
 
 	result := InitializeResult{
-		ProtocolVersion: mcpVersion,
+		ProtocolVersion: negotiateProtocolVersion(params.ProtocolVersion),
 		Capabilities: map[string]interface{}{
 			"tools": map[string]bool{},
-			"resources": map[string]bool{},
+			"resources": map[string]bool{"subscribe": true, "listChanged": true},
+			"prompts":   map[string]bool{},
 		},
 		ServerInfo: map[string]string{
 			"name":    "web-reader-mcp",
@@ -239,51 +360,32 @@ func handleInitialize(msg *JSONRPCMessage) *JSONRPCMessage {
 	}
 }
 
+// negotiateProtocolVersion echoes the client's protocol version when supported,
+// otherwise it offers the latest version this server implements
+func negotiateProtocolVersion(requested string) string {
+	for _, v := range supportedProtocolVersions {
+		if v == requested {
+			return v
+		}
+	}
+	return mcpVersion
+}
+
 // handleListTools returns the list of available tools
 func handleListTools(msg *JSONRPCMessage) *JSONRPCMessage {
 	tools := []Tool{
-		{
-			Name:        "web_reader",
-			Description: "Fetch web content and convert it to clean Markdown format. Optionally extract images and links with metadata.",
-			InputSchema: map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"url": map[string]interface{}{
-						"type":        "string",
-						"description": "The URL to fetch content from",
-					},
-					"model": map[string]interface{}{
-						"type":        "string",
-						"description": "AI model to use for conversion (default: deepseek-chat)",
-					},
-					"maxTokens": map[string]interface{}{
-						"type":        "integer",
-						"description": "Maximum tokens in response (default: 4000)",
-					},
-					"temperature": map[string]interface{}{
-						"type":        "number",
-						"description": "AI temperature 0-1 (default: 0.7)",
-					},
-					"retain_images": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Extract images from content",
-					},
-					"keep_img_data_url": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Download and convert images to base64 data URLs",
-					},
-					"with_images_summary": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Include image metadata in response",
-					},
-					"with_links_summary": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Extract and include link metadata",
-					},
-				},
-				"required": []string{"url"},
-			},
-		},
+		webReaderTool(),
+		webReaderBatchTool(),
+		checkLinksTool(),
+		crawlSiteTool(),
+		readSitemapTool(),
+		readFeedTool(),
+		diffPageTool(),
+		extractTablesTool(),
+		extractStructuredTool(),
+		askPageTool(),
+		summarizePageTool(),
+		tokenUsageTool(),
 	}
 
 	result := ListToolsResult{
@@ -297,6 +399,121 @@ func handleListTools(msg *JSONRPCMessage) *JSONRPCMessage {
 	}
 }
 
+// webReaderTool describes the web_reader tool
+func webReaderTool() Tool {
+	return Tool{
+		Name:        "web_reader",
+		Description: "Fetch web content and convert it to clean Markdown format. Optionally extract images and links with metadata.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"url": map[string]interface{}{
+					"type":        "string",
+					"description": "The URL to fetch content from",
+				},
+				"model": map[string]interface{}{
+					"type":        "string",
+					"description": "AI model to use for conversion (default: deepseek-chat)",
+				},
+				"maxTokens": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum tokens in response (default: 4000)",
+				},
+				"temperature": map[string]interface{}{
+					"type":        "number",
+					"description": "AI temperature 0-1 (default: 0.7)",
+				},
+				"retain_images": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Extract images from content",
+				},
+				"keep_img_data_url": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Download and convert images to base64 data URLs",
+				},
+				"with_images_summary": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Include image metadata in response",
+				},
+				"with_links_summary": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Extract and include link metadata",
+				},
+				"max_images": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of images to download when keep_img_data_url is set (default: 20)",
+				},
+				"max_image_bytes": map[string]interface{}{
+					"type":        "integer",
+					"description": "Total bytes of image data to embed per call (default: 20971520)",
+				},
+				"images_as_content": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Download images and return them as MCP image content blocks after the text instead of data URLs in the Markdown",
+				},
+				"image_max_edge": map[string]interface{}{
+					"type":        "integer",
+					"description": "Downscale downloaded images so their longest edge is at most this many pixels (default: no downscaling)",
+				},
+				"min_image_dimension": map[string]interface{}{
+					"type":        "integer",
+					"description": "Drop images narrower or shorter than this many pixels, such as tracking pixels and spacers; 0 keeps all (default: 4)",
+				},
+				"preferred_image_width": map[string]interface{}{
+					"type":        "integer",
+					"description": "Preferred width when choosing among srcset and <picture> candidates (default: largest available)",
+				},
+				"metadata_only": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Skip the AI conversion and return only page metadata (title, author, dates, OpenGraph, JSON-LD) plus any requested images and links",
+				},
+				"link_kinds": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string", "enum": []string{linkKindInternal, linkKindExternal, linkKindSamePage}},
+					"description": "Only return links of these kinds (default: all)",
+				},
+				"link_regions": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string", "enum": []string{linkRegionMain, linkRegionNav, linkRegionHeader, linkRegionFooter, linkRegionAside}},
+					"description": "Only return links found in these page regions, e.g. [\"main\"] for main-content links (default: all)",
+				},
+				"exclude_nofollow": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Drop links marked rel=nofollow, sponsored or ugc",
+				},
+				"include_selector": map[string]interface{}{
+					"type":        "string",
+					"description": "CSS selector for the parts of the page to keep, e.g. \"main article\" or \"#changelog, table.api\"; images, links and Markdown come only from matching elements",
+				},
+				"exclude_selector": map[string]interface{}{
+					"type":        "string",
+					"description": "CSS selector for elements to remove before extraction, e.g. \"nav, footer, .ads\"",
+				},
+				"tables_as_markdown": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Render HTML tables directly as GitHub-flavored Markdown tables instead of having the AI convert them",
+				},
+				"target_language": map[string]interface{}{
+					"type":        "string",
+					"description": "Translate the converted Markdown into this language (e.g. \"en\", \"zh-CN\", \"Japanese\"), keeping code, links and headings intact",
+				},
+				"prompt_profile": map[string]interface{}{
+					"type":        "string",
+					"enum":        promptProfileNames(),
+					"description": "Conversion prompt to use, tuned for a kind of page (default: default)",
+				},
+				"fidelity_check": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{fidelityFlag, fidelityRepair, fidelityOff},
+					"description": "Check that links, images and code blocks in the Markdown exist in the page: flag reports discrepancies, repair also unlinks invented URLs and restores altered code (default: flag)",
+				},
+			},
+			"required": []string{"url"},
+		},
+		OutputSchema: webReaderOutputSchema,
+	}
+}
+
 // handleCallTool executes a tool call
 func handleCallTool(msg *JSONRPCMessage) *JSONRPCMessage {
 	var params CallToolParams
@@ -316,6 +533,28 @@ func handleCallTool(msg *JSONRPCMessage) *JSONRPCMessage {
 	switch params.Name {
 	case "web_reader":
 		return handleWebReader(msg.ID, params.Arguments)
+	case "web_reader_batch":
+		return handleWebReaderBatch(msg.ID, params.Arguments, params.Meta["progressToken"])
+	case "check_links":
+		return handleCheckLinks(msg.ID, params.Arguments)
+	case "crawl_site":
+		return handleCrawlSite(msg.ID, params.Arguments, params.Meta["progressToken"])
+	case "read_sitemap":
+		return handleReadSitemap(msg.ID, params.Arguments)
+	case "read_feed":
+		return handleReadFeed(msg.ID, params.Arguments, params.Meta["progressToken"])
+	case "diff_page":
+		return handleDiffPage(msg.ID, params.Arguments)
+	case "extract_tables":
+		return handleExtractTables(msg.ID, params.Arguments)
+	case "extract_structured":
+		return handleExtractStructured(msg.ID, params.Arguments)
+	case "ask_page":
+		return handleAskPage(msg.ID, params.Arguments)
+	case "summarize_page":
+		return handleSummarizePage(msg.ID, params.Arguments)
+	case "token_usage":
+		return handleTokenUsage(msg.ID, params.Arguments)
 	default:
 		return &JSONRPCMessage{
 			JSONRPC: "2.0",
@@ -330,8 +569,6 @@ func handleCallTool(msg *JSONRPCMessage) *JSONRPCMessage {
 
 // handleWebReader processes the web_reader tool call
 func handleWebReader(id interface{}, args map[string]interface{}) *JSONRPCMessage {
-	startTime := time.Now()
-
 	// Parse input arguments
 	input, err := parseWebReaderInput(args)
 	if err != nil {
@@ -345,18 +582,87 @@ func handleWebReader(id interface{}, args map[string]interface{}) *JSONRPCMessag
 		}
 	}
 
+	page, rpcErr := readWebPage(context.Background(), input)
+	if rpcErr != nil {
+		return &JSONRPCMessage{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error:   rpcErr,
+		}
+	}
+
+	content := buildToolResponse(page, input.ImagesAsContent)
+
+	return &JSONRPCMessage{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result: map[string]interface{}{
+			"content":           content,
+			"structuredContent": page.output(),
+		},
+	}
+}
+
+// webPage is one URL read through the web_reader pipeline
+type webPage struct {
+	URL            string
+	HTML           string // the fetched HTML after selectors were applied
+	Markdown       string
+	Page           *PageMetadata
+	Images         []ImageInfo
+	Links          []LinkInfo
+	FetchedAt      time.Time
+	ProcessingTime float64 // milliseconds
+	Translation    *TranslationInfo
+	Fidelity       *FidelityReport
+	Usage          TokenUsage // AI tokens spent on conversion and translation
+
+	ImagesAsContent bool // images are sent as image content blocks
+}
+
+// output returns the structuredContent for the page
+func (p *webPage) output() WebReaderOutput {
+	images := p.Images
+	if p.ImagesAsContent {
+		// The image blocks already carry the data; repeating it here would double the response
+		images = make([]ImageInfo, len(p.Images))
+		for i, img := range p.Images {
+			img.DataURL = ""
+			images[i] = img
+		}
+	}
+
+	output := buildWebReaderOutput(p.Markdown, p.URL, p.FetchedAt, p.ProcessingTime, p.Page, images, p.Links)
+	output.Translation = p.Translation
+	output.Fidelity = p.Fidelity
+	output.Usage = p.Usage.report()
+	return output
+}
+
+// readWebPage fetches a page, extracts its metadata, images and links and converts it to Markdown.
+// Cancelling ctx aborts the fetch and any AI calls still to be made. When it
+// fails after the model was called, the returned page carries only the URL and
+// the usage, which is also reported in the error's data.
+func readWebPage(ctx context.Context, input *WebReaderInput) (*webPage, *RPCError) {
+	startTime := time.Now()
+
+	var usage TokenUsage
+	failedAfterAI := func(message string) (*webPage, *RPCError) {
+		rpcErr := &RPCError{Code: -2, Message: message, Data: usageErrorData(usage)}
+		if usage.Calls == 0 {
+			return nil, rpcErr
+		}
+		return &webPage{URL: input.URL, FetchedAt: startTime, Usage: usage}, rpcErr
+	}
+
 	log.Printf("Fetching URL: %s", input.URL)
 
 	// Step 1: Fetch web content
-	htmlContent, err := fetchWebContent(input.URL)
+	htmlContent, err := fetchWebContent(ctx, input.URL)
 	if err != nil {
-		return &JSONRPCMessage{
-			JSONRPC: "2.0",
-			ID:      id,
-			Error: &RPCError{
-				Code:    -1,
-				Message: fmt.Sprintf("Failed to fetch web content: %v", err),
-			},
+		return nil, &RPCError{
+			Code:    -1,
+			Message: fmt.Sprintf("Failed to fetch web content: %v", err),
 		}
 	}
 
@@ -366,51 +672,145 @@ func handleWebReader(id interface{}, args map[string]interface{}) *JSONRPCMessag
 
 	parsedURL, _ := url.Parse(input.URL)
 
-	if input.RetainImages || input.WithImagesSummary {
+	pageMeta := extractPageMetadata(htmlContent, parsedURL)
+
+	// Narrow the document to the requested parts; metadata above still describes the whole page
+	if input.IncludeSelector != "" || input.ExcludeSelector != "" {
+		htmlContent, err = scopeHTML(htmlContent, input.IncludeSelector, input.ExcludeSelector)
+		if err != nil {
+			return nil, &RPCError{
+				Code:    -2,
+				Message: fmt.Sprintf("Failed to apply selectors: %v", err),
+			}
+		}
+	}
+
+	if input.RetainImages || input.WithImagesSummary || input.ImagesAsContent {
 		log.Println("Extracting images...")
-		images, _ = extractImages(htmlContent, parsedURL, input.KeepImageDataURL)
+		images, _ = extractImages(htmlContent, parsedURL, imageOptions{
+			KeepDataURL:    input.KeepImageDataURL || input.ImagesAsContent,
+			MaxImages:      input.MaxImages,
+			MaxBytes:       input.MaxImageBytes,
+			MaxEdge:        input.ImageMaxEdge,
+			MinDimension:   input.MinImageDimension,
+			PreferredWidth: input.PreferredImageWidth,
+		})
 	}
 
 	if input.WithLinksSummary {
 		log.Println("Extracting links...")
-		links = extractLinks(htmlContent, parsedURL)
+		links = filterLinks(extractLinks(htmlContent, parsedURL), linkFilter{
+			Kinds:           input.LinkKinds,
+			Regions:         input.LinkRegions,
+			ExcludeNofollow: input.ExcludeNofollow,
+		})
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, &RPCError{
+			Code:    -2,
+			Message: fmt.Sprintf("Read abandoned: %v", err),
+		}
 	}
 
 	// Step 3: Convert to Markdown using AI
-	log.Println("Converting to Markdown...")
-	markdownContent, err := convertToMarkdown(htmlContent, input.Model, input.MaxTokens, input.Temperature)
-	if err != nil {
-		return &JSONRPCMessage{
-			JSONRPC: "2.0",
-			ID:      id,
-			Error: &RPCError{
+	var markdownContent string
+	var fidelity *FidelityReport
+	if !input.MetadataOnly {
+		log.Println("Converting to Markdown...")
+
+		// Tables are rendered here and kept away from the model, which tends to mangle them
+		conversionHTML := htmlContent
+		var tables []ExtractedTable
+		if input.TablesAsMarkdown {
+			conversionHTML, tables = replaceTablesWithPlaceholders(htmlContent)
+		}
+
+		systemPrompt, err := conversionPrompt(input.PromptProfile, input.URL, pageMeta)
+		if err != nil {
+			return nil, &RPCError{
 				Code:    -2,
-				Message: fmt.Sprintf("Failed to convert content: %v", err),
-			},
+				Message: fmt.Sprintf("Failed to render prompt profile %q: %v", input.PromptProfile, err),
+			}
+		}
+
+		var conversionUsage TokenUsage
+		markdownContent, conversionUsage, err = convertToMarkdown(ctx, conversionHTML, systemPrompt, input.Model, input.MaxTokens, input.Temperature)
+		usage.add(conversionUsage)
+		if err != nil {
+			return failedAfterAI(fmt.Sprintf("Failed to convert content: %v", err))
+		}
+		if len(tables) > 0 {
+			markdownContent = fillTablePlaceholders(markdownContent, tables)
+		}
+
+		if input.FidelityCheck != fidelityOff {
+			markdownContent, fidelity = verifyMarkdown(markdownContent, htmlContent, parsedURL, input.FidelityCheck)
 		}
 	}
 
 	// Step 4: Post-process Markdown if needed
-	if input.RetainImages && input.KeepImageDataURL && len(images) > 0 {
+	if input.RetainImages && input.KeepImageDataURL && !input.ImagesAsContent && len(images) > 0 {
 		markdownContent = updateImageReferences(markdownContent, images)
 	}
 
-	// Step 5: Build response content
-	processingTime := float64(time.Since(startTime).Microseconds()) / 1000.0
-	content := buildToolResponse(markdownContent, input.URL, processingTime, images, links)
-
-	return &JSONRPCMessage{
-		JSONRPC: "2.0",
-		ID:      id,
-		Result: map[string]interface{}{
-			"content": content,
-		},
+	// Step 5: Translate the Markdown if requested
+	var translation *TranslationInfo
+	if input.TargetLanguage != "" && markdownContent != "" {
+		declared := ""
+		if pageMeta != nil {
+			declared = pageMeta.Language
+		}
+		var translationUsage TokenUsage
+		markdownContent, translation, translationUsage, err = translateMarkdown(ctx, markdownContent, input.TargetLanguage, declared, input.Model, input.MaxTokens)
+		usage.add(translationUsage)
+		if err != nil {
+			return failedAfterAI(fmt.Sprintf("Failed to translate content: %v", err))
+		}
 	}
+
+	// Only plain reads stand for the URL's resource; scoped, translated or
+	// re-prompted Markdown would replace it for every subscriber
+	if input.defaultConversion() {
+		title := ""
+		if pageMeta != nil {
+			title = pageMeta.Title
+		}
+		pageResources.add(input.URL, title, markdownContent, startTime)
+	}
+
+	return &webPage{
+		URL:            input.URL,
+		HTML:           htmlContent,
+		Markdown:       markdownContent,
+		Page:           pageMeta,
+		Images:         images,
+		Links:          links,
+		FetchedAt:      startTime,
+		ProcessingTime: float64(time.Since(startTime).Microseconds()) / 1000.0,
+		Translation:    translation,
+		Fidelity:       fidelity,
+		Usage:          usage,
+
+		ImagesAsContent: input.ImagesAsContent,
+	}, nil
+}
+
+// defaultConversion reports whether the input converts the page with the
+// default options, leaving out everything that changes the Markdown itself
+func (input *WebReaderInput) defaultConversion() bool {
+	return input.IncludeSelector == "" && input.ExcludeSelector == "" &&
+		input.TargetLanguage == "" && !input.TablesAsMarkdown && !input.KeepImageDataURL &&
+		input.FidelityCheck != fidelityRepair &&
+		(input.PromptProfile == "" || input.PromptProfile == defaultPromptProfile)
 }
 
 // parseWebReaderInput parses and validates the tool input arguments
 func parseWebReaderInput(args map[string]interface{}) (*WebReaderInput, error) {
-	input := &WebReaderInput{}
+	input := &WebReaderInput{
+		MinImageDimension: defaultMinImageDimension,
+		FidelityCheck:     fidelityFlag,
+	}
 
 	// Required parameter: url
 	if urlVal, ok := args["url"].(string); ok {
@@ -446,30 +846,119 @@ func parseWebReaderInput(args map[string]interface{}) (*WebReaderInput, error) {
 	if v, ok := args["with_links_summary"].(bool); ok {
 		input.WithLinksSummary = v
 	}
+	if v, ok := args["max_images"].(float64); ok {
+		input.MaxImages = int(v)
+	}
+	if v, ok := args["max_image_bytes"].(float64); ok {
+		input.MaxImageBytes = int64(v)
+	}
+	if v, ok := args["images_as_content"].(bool); ok {
+		input.ImagesAsContent = v
+	}
+	if v, ok := args["image_max_edge"].(float64); ok {
+		input.ImageMaxEdge = int(v)
+	}
+	if v, ok := args["min_image_dimension"].(float64); ok {
+		input.MinImageDimension = int(v)
+	}
+	if v, ok := args["preferred_image_width"].(float64); ok {
+		input.PreferredImageWidth = int(v)
+	}
+	if v, ok := args["metadata_only"].(bool); ok {
+		input.MetadataOnly = v
+	}
+	input.LinkKinds = stringSliceArg(args, "link_kinds")
+	input.LinkRegions = stringSliceArg(args, "link_regions")
+	if v, ok := args["exclude_nofollow"].(bool); ok {
+		input.ExcludeNofollow = v
+	}
+	if v, ok := args["include_selector"].(string); ok {
+		if _, err := parseSelector(v); v != "" && err != nil {
+			return nil, fmt.Errorf("include_selector: %w", err)
+		}
+		input.IncludeSelector = v
+	}
+	if v, ok := args["exclude_selector"].(string); ok {
+		if _, err := parseSelector(v); v != "" && err != nil {
+			return nil, fmt.Errorf("exclude_selector: %w", err)
+		}
+		input.ExcludeSelector = v
+	}
+	if v, ok := args["tables_as_markdown"].(bool); ok {
+		input.TablesAsMarkdown = v
+	}
+	if v, ok := args["target_language"].(string); ok {
+		input.TargetLanguage = strings.TrimSpace(v)
+	}
+	if v, ok := args["prompt_profile"].(string); ok && v != "" {
+		if _, known := promptProfiles[v]; !known {
+			return nil, fmt.Errorf("unknown prompt_profile %q, available: %s", v, strings.Join(promptProfileNames(), ", "))
+		}
+		input.PromptProfile = v
+	}
+	if v, ok := args["fidelity_check"].(string); ok && v != "" {
+		if v != fidelityFlag && v != fidelityRepair && v != fidelityOff {
+			return nil, fmt.Errorf("fidelity_check must be one of %s, %s or %s", fidelityFlag, fidelityRepair, fidelityOff)
+		}
+		input.FidelityCheck = v
+	}
 
 	return input, nil
 }
 
+// stringSliceArg reads an array of strings from tool arguments, also accepting a single string
+func stringSliceArg(args map[string]interface{}, key string) []string {
+	switch v := args[key].(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
 // buildToolResponse constructs the content array for the tool response
-func buildToolResponse(markdown, sourceURL string, processingTime float64, images []ImageInfo, links []LinkInfo) []interface{} {
+func buildToolResponse(page *webPage, imagesAsContent bool) []interface{} {
+	markdown, sourceURL := page.Markdown, page.URL
+	images, links := page.Images, page.Links
+
 	content := []interface{}{
 		TextContent{
 			Type: "text",
 			Text: fmt.Sprintf("# Web Content from %s\n\n", sourceURL),
 		},
-		TextContent{
+	}
+
+	if markdown != "" {
+		content = append(content, TextContent{
 			Type: "text",
 			Text: markdown,
-		},
+		})
+	}
+
+	if imagesAsContent {
+		content = append(content, buildImageContent(images)...)
 	}
 
 	// Add metadata summary
 	metadata := fmt.Sprintf("\n\n---\n**Metadata:**\n")
 	metadata += fmt.Sprintf("- Source: %s\n", sourceURL)
-	metadata += fmt.Sprintf("- Processing time: %.2fms\n", processingTime)
+	metadata += formatPageMetadata(page.Page)
+	metadata += fmt.Sprintf("- Processing time: %.2fms\n", page.ProcessingTime)
 	metadata += fmt.Sprintf("- Word count: %d\n", len(strings.Fields(markdown)))
 	metadata += fmt.Sprintf("- Images found: %d\n", len(images))
 	metadata += fmt.Sprintf("- Links found: %d\n", len(links))
+	metadata += formatTranslationInfo(page.Translation)
+	metadata += formatTokenUsage(page.Usage.report())
+	metadata += formatFidelityReport(page.Fidelity)
 
 	if len(images) > 0 {
 		metadata += "\n**Images:**\n"
@@ -482,9 +971,15 @@ func buildToolResponse(markdown, sourceURL string, processingTime float64, image
 			if img.Alt != "" {
 				metadata += fmt.Sprintf(" (Alt: %s)", img.Alt)
 			}
+			if img.Caption != "" {
+				metadata += fmt.Sprintf(" (Caption: %s)", truncateString(img.Caption, 80))
+			}
 			if img.Width > 0 && img.Height > 0 {
 				metadata += fmt.Sprintf(" [%dx%d]", img.Width, img.Height)
 			}
+			if img.Status != "" {
+				metadata += fmt.Sprintf(" {%s}", img.Status)
+			}
 			metadata += "\n"
 		}
 	}
@@ -500,6 +995,19 @@ func buildToolResponse(markdown, sourceURL string, processingTime float64, image
 			if link.Text != "" {
 				metadata += fmt.Sprintf(" - %s", truncateString(link.Text, 50))
 			}
+			if link.Kind != "" {
+				metadata += fmt.Sprintf(" [%s", link.Kind)
+				if link.Region != "" {
+					metadata += ", " + link.Region
+				}
+				if link.FileType != "" {
+					metadata += ", " + link.FileType
+				}
+				metadata += "]"
+			}
+			if len(link.Rel) > 0 {
+				metadata += fmt.Sprintf(" (rel: %s)", strings.Join(link.Rel, " "))
+			}
 			metadata += "\n"
 		}
 	}
@@ -512,6 +1020,34 @@ func buildToolResponse(markdown, sourceURL string, processingTime float64, image
 	return content
 }
 
+// newErrorResponse builds a JSON-RPC error response
+func newErrorResponse(id interface{}, code int, message string) *JSONRPCMessage {
+	return &JSONRPCMessage{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error: &RPCError{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
+
+// newToolResult builds a tools/call result with text content and, when
+// non-nil, the matching structuredContent
+func newToolResult(id interface{}, content []interface{}, structured interface{}) *JSONRPCMessage {
+	result := map[string]interface{}{
+		"content": content,
+	}
+	if structured != nil {
+		result["structuredContent"] = structured
+	}
+	return &JSONRPCMessage{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result:  result,
+	}
+}
+
 // handlePing responds to ping requests
 func handlePing(msg *JSONRPCMessage) *JSONRPCMessage {
 	return &JSONRPCMessage{
@@ -522,26 +1058,22 @@ func handlePing(msg *JSONRPCMessage) *JSONRPCMessage {
 }
 
 // fetchWebContent fetches the HTML content from the given URL
-func fetchWebContent(targetURL string) (string, error) {
+func fetchWebContent(ctx context.Context, targetURL string) (string, error) {
 	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
-		},
+		Timeout:   30 * time.Second,
+		Transport: insecureTransport(),
 	}
 
-	req, err := http.NewRequest("GET", targetURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	req.Header.Set("User-Agent", userAgent)
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
 	req.Header.Set("Accept-Language", "en-US,en;q=0.9,zh-CN;q=0.8,zh;q=0.7")
 
-	resp, err := client.Do(req)
+	resp, err := doLimited(client, req)
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch URL: %w", err)
 	}
@@ -559,174 +1091,13 @@ func fetchWebContent(targetURL string) (string, error) {
 	return string(body), nil
 }
 
-// extractImages extracts all images from HTML content
-func extractImages(htmlContent string, baseURL *url.URL, keepDataURL bool) ([]ImageInfo, error) {
-	var images []ImageInfo
-
-	imgRegex := regexp.MustCompile(`<img[^>]+>`)
-	matches := imgRegex.FindAllString(htmlContent, -1)
-
-	srcRegex := regexp.MustCompile(`src=["']([^"']+)["']`)
-	altRegex := regexp.MustCompile(`alt=["']([^"']*)["']`)
-	widthRegex := regexp.MustCompile(`width=["'](\d+)["']`)
-	heightRegex := regexp.MustCompile(`height=["'](\d+)["']`)
-
-	for _, imgTag := range matches {
-		srcMatch := srcRegex.FindStringSubmatch(imgTag)
-		if len(srcMatch) < 2 {
-			continue
-		}
-
-		imgSrc := srcMatch[1]
-		if strings.HasPrefix(imgSrc, "data:") || imgSrc == "" {
-			continue
-		}
-
-		imgURL, err := resolveURL(baseURL, imgSrc)
-		if err != nil {
-			continue
-		}
-
-		imageInfo := ImageInfo{
-			OriginalURL: imgURL.String(),
-		}
-
-		if altMatch := altRegex.FindStringSubmatch(imgTag); len(altMatch) >= 2 {
-			imageInfo.Alt = altMatch[1]
-		}
-
-		if widthMatch := widthRegex.FindStringSubmatch(imgTag); len(widthMatch) >= 2 {
-			fmt.Sscanf(widthMatch[1], "%d", &imageInfo.Width)
-		}
-		if heightMatch := heightRegex.FindStringSubmatch(imgTag); len(heightMatch) >= 2 {
-			fmt.Sscanf(heightMatch[1], "%d", &imageInfo.Height)
-		}
-
-		if keepDataURL {
-			if dataURL, size, err := downloadAndConvertImage(imgURL.String()); err == nil {
-				imageInfo.DataURL = dataURL
-				imageInfo.Size = size
-			}
-		}
-
-		images = append(images, imageInfo)
-	}
-
-	return images, nil
-}
-
-// downloadAndConvertImage downloads an image and converts it to base64 data URL
-func downloadAndConvertImage(imgURL string) (string, int64, error) {
-	client := &http.Client{
-		Timeout: 15 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
+// insecureTransport returns a transport that skips TLS certificate verification
+func insecureTransport() *http.Transport {
+	return &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
 		},
 	}
-
-	resp, err := client.Get(imgURL)
-	if err != nil {
-		return "", 0, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", 0, fmt.Errorf("HTTP %d", resp.StatusCode)
-	}
-
-	if resp.ContentLength > maxImageSize {
-		return "", 0, fmt.Errorf("image too large: %d bytes", resp.ContentLength)
-	}
-
-	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize))
-	if err != nil {
-		return "", 0, err
-	}
-
-	if len(data) > maxImageSize {
-		return "", 0, fmt.Errorf("image too large: %d bytes", len(data))
-	}
-
-	contentType := resp.Header.Get("Content-Type")
-	if contentType == "" {
-		contentType = http.DetectContentType(data)
-	}
-
-	dataURL := fmt.Sprintf("data:%s;base64,%s", contentType, base64.StdEncoding.EncodeToString(data))
-
-	return dataURL, int64(len(data)), nil
-}
-
-// extractLinks extracts all links from HTML content
-func extractLinks(htmlContent string, baseURL *url.URL) []LinkInfo {
-	var links []LinkInfo
-
-	linkRegex := regexp.MustCompile(`<a[^>]+>(.*?)</a>`)
-	matches := linkRegex.FindAllStringSubmatch(htmlContent, -1)
-
-	hrefRegex := regexp.MustCompile(`href=["']([^"']+)["']`)
-
-	seen := make(map[string]bool)
-
-	for _, match := range matches {
-		if len(match) < 2 {
-			continue
-		}
-
-		linkTag := match[0]
-		linkText := strings.TrimSpace(match[1])
-
-		textRegex := regexp.MustCompile(`<[^>]+>`)
-		linkText = textRegex.ReplaceAllString(linkText, "")
-		linkText = strings.TrimSpace(linkText)
-
-		hrefMatch := hrefRegex.FindStringSubmatch(linkTag)
-		if len(hrefMatch) < 2 {
-			continue
-		}
-
-		href := hrefMatch[1]
-
-		if strings.HasPrefix(href, "javascript:") ||
-			strings.HasPrefix(href, "mailto:") ||
-			strings.HasPrefix(href, "tel:") ||
-			strings.HasPrefix(href, "#") {
-			continue
-		}
-
-		linkURL, err := resolveURL(baseURL, href)
-		if err != nil {
-			continue
-		}
-
-		linkStr := linkURL.String()
-
-		if seen[linkStr] {
-			continue
-		}
-		seen[linkStr] = true
-
-		linkInfo := LinkInfo{
-			URL:   linkStr,
-			Text:  linkText,
-			Title: extractTitle(linkTag),
-		}
-
-		links = append(links, linkInfo)
-	}
-
-	return links
-}
-
-// extractTitle extracts the title attribute from a link tag
-func extractTitle(linkTag string) string {
-	titleRegex := regexp.MustCompile(`title=["']([^"']*)["']`)
-	if match := titleRegex.FindStringSubmatch(linkTag); len(match) >= 2 {
-		return match[1]
-	}
-	return ""
 }
 
 // resolveURL resolves a potentially relative URL against a base URL
@@ -761,8 +1132,38 @@ func stripURLFragment(rawURL string) string {
 	return u.String()
 }
 
-// convertToMarkdown calls the AI API to convert HTML to Markdown
-func convertToMarkdown(htmlContent, model string, maxTokens int, temperature float64) (string, error) {
+// defaultConversionPrompt is the system prompt of the default prompt profile
+const defaultConversionPrompt = "You are a web content extractor. Your task is to convert HTML content to clean, well-formatted Markdown. " +
+	"Extract only the main content, removing ads, navigation, scripts, and other non-essential elements. " +
+	"Preserve the structure with proper Markdown headings, lists, links, and formatting. " +
+	"Keep all image references in Markdown format: ![alt text](image_url). " +
+	"Keep all links in Markdown format: [link text](url). " +
+	"Return ONLY the Markdown content without any explanations or additional text."
+
+// convertToMarkdown calls the AI API to convert HTML to Markdown. An empty
+// systemPrompt uses defaultConversionPrompt.
+func convertToMarkdown(ctx context.Context, htmlContent, systemPrompt, model string, maxTokens int, temperature float64) (string, TokenUsage, error) {
+	if systemPrompt == "" {
+		systemPrompt = defaultConversionPrompt
+	}
+
+	messages := []AIMessage{
+		{
+			Role:    "system",
+			Content: systemPrompt,
+		},
+		{
+			Role: "user",
+			Content: "Please convert the following HTML content to Markdown:\n\n" + htmlContent,
+		},
+	}
+
+	return callAI(ctx, messages, model, maxTokens, temperature)
+}
+
+// callAI sends a chat completion request to the AI API and returns the reply
+// text and the tokens it used, which are also added to the session totals
+func callAI(ctx context.Context, messages []AIMessage, model string, maxTokens int, temperature float64) (string, TokenUsage, error) {
 	if model == "" {
 		model = defaultModel
 	}
@@ -773,21 +1174,13 @@ func convertToMarkdown(htmlContent, model string, maxTokens int, temperature flo
 		temperature = 0.7
 	}
 
-	messages := []AIMessage{
-		{
-			Role: "system",
-			Content: "You are a web content extractor. Your task is to convert HTML content to clean, well-formatted Markdown. " +
-				"Extract only the main content, removing ads, navigation, scripts, and other non-essential elements. " +
-				"Preserve the structure with proper Markdown headings, lists, links, and formatting. " +
-				"Keep all image references in Markdown format: ![alt text](image_url). " +
-				"Keep all links in Markdown format: [link text](url). " +
-				"Return ONLY the Markdown content without any explanations or additional text.",
-		},
-		{
-			Role: "user",
-			Content: "Please convert the following HTML content to Markdown:\n\n" + htmlContent,
-		},
+	// Hold the call's worst case against the budget until it is recorded;
+	// failures before the model answers give the reservation back
+	reserved := estimatePromptTokens(messages) + maxTokens
+	if err := sessionUsage.reserve(reserved); err != nil {
+		return "", TokenUsage{}, err
 	}
+	defer func() { sessionUsage.release(reserved) }()
 
 	aiReq := AIRequest{
 		Temperature:       temperature,
@@ -801,16 +1194,16 @@ func convertToMarkdown(htmlContent, model string, maxTokens int, temperature flo
 
 	payload, err := json.Marshal(aiReq)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return "", TokenUsage{}, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	client := &http.Client{
 		Timeout: 60 * time.Second,
 	}
 
-	req, err := http.NewRequest("POST", aiAPIURL, strings.NewReader(string(payload)))
+	req, err := http.NewRequestWithContext(ctx, "POST", aiAPIURL, strings.NewReader(string(payload)))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return "", TokenUsage{}, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -819,34 +1212,44 @@ func convertToMarkdown(htmlContent, model string, maxTokens int, temperature flo
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to call AI API: %w", err)
+		return "", TokenUsage{}, fmt.Errorf("failed to call AI API: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return "", TokenUsage{}, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	var aiResp AIResponse
 	if err := json.Unmarshal(body, &aiResp); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+		return "", TokenUsage{}, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if aiResp.Error != nil {
-		return "", fmt.Errorf("AI API error: %s", aiResp.Error.Message)
+		return "", TokenUsage{}, fmt.Errorf("AI API error: %s", aiResp.Error.Message)
 	}
 
 	if len(aiResp.Choices) == 0 {
-		return "", fmt.Errorf("no response from AI API")
+		return "", TokenUsage{}, fmt.Errorf("no response from AI API")
 	}
 
+	// Count the call once the model has answered, estimating when the provider sends no usage
 	content := strings.TrimSpace(aiResp.Choices[0].Message.Content)
+	var usage TokenUsage
+	if aiResp.Usage != nil {
+		usage = aiCallUsage(messages, content, aiResp.Usage.PromptTokens, aiResp.Usage.CompletionTokens)
+	} else {
+		usage = aiCallUsage(messages, content, 0, 0)
+	}
+	usage = sessionUsage.record(model, reserved, usage)
+	reserved = 0
+
 	if content == "" {
-		return "", fmt.Errorf("empty response from AI API")
+		return "", usage, fmt.Errorf("empty response from AI API")
 	}
 
-	return content, nil
+	return content, usage, nil
 }
 
 // truncateString truncates a string to a maximum length
@@ -856,3 +1259,11 @@ func truncateString(s string, maxLen int) string {
 	}
 	return s[:maxLen] + "..."
 }
+
+// truncateRunes truncates a string to a maximum number of characters without splitting one
+func truncateRunes(s string, maxRunes int) string {
+	if utf8.RuneCountInString(s) <= maxRunes {
+		return s
+	}
+	return string([]rune(s)[:maxRunes]) + "..."
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PageMetadata describes the page itself, collected from the HTML head,
+// OpenGraph/Twitter card tags and schema.org JSON-LD without the AI step
+type PageMetadata struct {
+	Title         string            `json:"title,omitempty"`
+	Description   string            `json:"description,omitempty"`
+	CanonicalURL  string            `json:"canonical_url,omitempty"`
+	Language      string            `json:"language,omitempty"`
+	Author        string            `json:"author,omitempty"`
+	SiteName      string            `json:"site_name,omitempty"`
+	PublishedTime string            `json:"published_time,omitempty"`
+	ModifiedTime  string            `json:"modified_time,omitempty"`
+	OpenGraph     map[string]string `json:"open_graph,omitempty"`
+	Twitter       map[string]string `json:"twitter,omitempty"`
+	JSONLD        []interface{}     `json:"json_ld,omitempty"`
+}
+
+var (
+	titleRegex     = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	htmlTagRegex   = regexp.MustCompile(`(?i)<html\s[^>]*>`)
+	linkTagRegex   = regexp.MustCompile(`(?i)<link\s[^>]*>`)
+	jsonLDRegex    = regexp.MustCompile(`(?is)<script[^>]+type=["']application/ld\+json["'][^>]*>(.*?)</script>`)
+	timeTagRegex   = regexp.MustCompile(`(?i)<time\s[^>]*>`)
+	relAuthorRegex = regexp.MustCompile(`(?is)<a\s[^>]*rel=["'][^"']*\bauthor\b[^"']*["'][^>]*>(.*?)</a>`)
+)
+
+// extractPageMetadata collects page-level metadata from HTML content
+func extractPageMetadata(htmlContent string, baseURL *url.URL) *PageMetadata {
+	meta := &PageMetadata{
+		OpenGraph: make(map[string]string),
+		Twitter:   make(map[string]string),
+	}
+
+	for _, tag := range metaTagRegex.FindAllString(htmlContent, -1) {
+		name := firstNonEmpty(tagAttr(tag, "property"), tagAttr(tag, "name"))
+		name = strings.ToLower(strings.TrimSpace(name))
+		content := strings.TrimSpace(tagAttr(tag, "content"))
+		if content == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(name, "og:"):
+			if _, ok := meta.OpenGraph[name]; !ok {
+				meta.OpenGraph[name] = content
+			}
+		case strings.HasPrefix(name, "twitter:"):
+			if _, ok := meta.Twitter[name]; !ok {
+				meta.Twitter[name] = content
+			}
+		}
+	}
+
+	if match := titleRegex.FindStringSubmatch(htmlContent); len(match) >= 2 {
+		meta.Title = htmlText(match[1])
+	}
+	if meta.Title == "" {
+		meta.Title = firstNonEmpty(meta.OpenGraph["og:title"], meta.Twitter["twitter:title"])
+	}
+
+	meta.Description = firstNonEmpty(
+		metaContent(htmlContent, "description"),
+		meta.OpenGraph["og:description"],
+		meta.Twitter["twitter:description"],
+	)
+	meta.SiteName = meta.OpenGraph["og:site_name"]
+
+	if tag := htmlTagRegex.FindString(htmlContent); tag != "" {
+		meta.Language = firstNonEmpty(tagAttr(tag, "lang"), tagAttr(tag, "xml:lang"))
+	}
+	if meta.Language == "" {
+		meta.Language = firstNonEmpty(metaContent(htmlContent, "content-language", "language"), meta.OpenGraph["og:locale"])
+	}
+
+	canonical := ""
+	for _, tag := range linkTagRegex.FindAllString(htmlContent, -1) {
+		if strings.EqualFold(strings.TrimSpace(tagAttr(tag, "rel")), "canonical") {
+			canonical = tagAttr(tag, "href")
+			break
+		}
+	}
+	if canonical == "" {
+		canonical = meta.OpenGraph["og:url"]
+	}
+	if canonical != "" && baseURL != nil {
+		if u, err := resolveURL(baseURL, canonical); err == nil {
+			canonical = u.String()
+		}
+	}
+	meta.CanonicalURL = canonical
+
+	meta.Author = metaContent(htmlContent, "author", "article:author", "dc.creator", "twitter:creator")
+	meta.PublishedTime = metaContent(htmlContent,
+		"article:published_time", "og:published_time", "datePublished", "date", "pubdate", "dc.date.issued", "dc.date")
+	meta.ModifiedTime = metaContent(htmlContent,
+		"article:modified_time", "og:updated_time", "dateModified", "last-modified", "dc.date.modified")
+
+	for _, match := range jsonLDRegex.FindAllStringSubmatch(htmlContent, -1) {
+		var data interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(match[1])), &data); err != nil {
+			continue
+		}
+		meta.JSONLD = append(meta.JSONLD, data)
+		fillFromJSONLD(meta, data)
+	}
+
+	// Last resorts for byline and date that some templates only render in the body
+	if meta.Author == "" {
+		if match := relAuthorRegex.FindStringSubmatch(htmlContent); len(match) >= 2 {
+			meta.Author = htmlText(match[1])
+		}
+	}
+	if meta.PublishedTime == "" {
+		for _, tag := range timeTagRegex.FindAllString(htmlContent, -1) {
+			if strings.Contains(strings.ToLower(tag), "pubdate") || strings.Contains(tag, "datePublished") {
+				meta.PublishedTime = tagAttr(tag, "datetime")
+				break
+			}
+		}
+	}
+
+	if len(meta.OpenGraph) == 0 {
+		meta.OpenGraph = nil
+	}
+	if len(meta.Twitter) == 0 {
+		meta.Twitter = nil
+	}
+
+	return meta
+}
+
+// fillFromJSONLD fills fields still missing from schema.org objects, including @graph members
+func fillFromJSONLD(meta *PageMetadata, data interface{}) {
+	switch v := data.(type) {
+	case []interface{}:
+		for _, item := range v {
+			fillFromJSONLD(meta, item)
+		}
+	case map[string]interface{}:
+		if graph, ok := v["@graph"]; ok {
+			fillFromJSONLD(meta, graph)
+		}
+		if meta.Title == "" {
+			meta.Title = jsonLDString(v["headline"])
+		}
+		if meta.Description == "" {
+			meta.Description = jsonLDString(v["description"])
+		}
+		if meta.Author == "" {
+			meta.Author = jsonLDString(v["author"])
+		}
+		if meta.PublishedTime == "" {
+			meta.PublishedTime = jsonLDString(v["datePublished"])
+		}
+		if meta.ModifiedTime == "" {
+			meta.ModifiedTime = jsonLDString(v["dateModified"])
+		}
+		if meta.Language == "" {
+			meta.Language = jsonLDString(v["inLanguage"])
+		}
+	}
+}
+
+// jsonLDString flattens a JSON-LD value (string, {"name": ...} object or list) into text
+func jsonLDString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return strings.TrimSpace(val)
+	case map[string]interface{}:
+		return jsonLDString(val["name"])
+	case []interface{}:
+		var parts []string
+		for _, item := range val {
+			if s := jsonLDString(item); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, ", ")
+	}
+	return ""
+}
+
+// jsonLDTypes lists the @type of each JSON-LD object, including @graph members
+func (m *PageMetadata) jsonLDTypes() []string {
+	var types []string
+	var collect func(interface{})
+	collect = func(data interface{}) {
+		switch v := data.(type) {
+		case []interface{}:
+			for _, item := range v {
+				collect(item)
+			}
+		case map[string]interface{}:
+			if graph, ok := v["@graph"]; ok {
+				collect(graph)
+			}
+			if t := jsonLDString(v["@type"]); t != "" {
+				types = append(types, t)
+			}
+		}
+	}
+	for _, data := range m.JSONLD {
+		collect(data)
+	}
+	return types
+}
+
+// firstNonEmpty returns the first argument that is not blank
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// formatPageMetadata renders page metadata as Markdown list items for the metadata block
+func formatPageMetadata(m *PageMetadata) string {
+	if m == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	fields := []struct{ label, value string }{
+		{"Title", m.Title},
+		{"Description", truncateString(m.Description, 200)},
+		{"Canonical URL", m.CanonicalURL},
+		{"Language", m.Language},
+		{"Author", m.Author},
+		{"Site", m.SiteName},
+		{"Published", m.PublishedTime},
+		{"Modified", m.ModifiedTime},
+	}
+	for _, f := range fields {
+		if f.value != "" {
+			fmt.Fprintf(&b, "- %s: %s\n", f.label, f.value)
+		}
+	}
+
+	if len(m.OpenGraph) > 0 {
+		fmt.Fprintf(&b, "- OpenGraph: %s\n", formatMetaMap(m.OpenGraph))
+	}
+	if len(m.Twitter) > 0 {
+		fmt.Fprintf(&b, "- Twitter card: %s\n", formatMetaMap(m.Twitter))
+	}
+	if types := m.jsonLDTypes(); len(types) > 0 {
+		fmt.Fprintf(&b, "- JSON-LD: %s\n", strings.Join(types, ", "))
+	}
+
+	return b.String()
+}
+
+// formatMetaMap renders tag/value pairs in a stable order
+func formatMetaMap(values map[string]string) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, truncateString(values[k], 100)))
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestExtractPageMetadata(t *testing.T) {
+	baseURL, _ := url.Parse("https://example.com/blog/post")
+
+	tests := []struct {
+		name  string
+		html  string
+		want  PageMetadata
+		types []string
+	}{
+		{
+			name: "head tags",
+			html: `<html lang="en-GB"><head>
+<title>Release &amp; Notes</title>
+<meta name="description" content="What changed">
+<meta property="og:title" content="OG title">
+<meta property="og:site_name" content="Example Blog">
+<meta property="og:title" content="Second OG title">
+<meta name="twitter:card" content="summary">
+<meta name="author" content="Ada Lovelace">
+<meta property="article:published_time" content="2024-05-01T10:00:00Z">
+<meta property="article:modified_time" content="2024-05-02T10:00:00Z">
+<link rel="canonical" href="/blog/release-notes">
+</head><body></body></html>`,
+			want: PageMetadata{
+				Title:         "Release & Notes",
+				Description:   "What changed",
+				CanonicalURL:  "https://example.com/blog/release-notes",
+				Language:      "en-GB",
+				Author:        "Ada Lovelace",
+				SiteName:      "Example Blog",
+				PublishedTime: "2024-05-01T10:00:00Z",
+				ModifiedTime:  "2024-05-02T10:00:00Z",
+				OpenGraph:     map[string]string{"og:title": "OG title", "og:site_name": "Example Blog"},
+				Twitter:       map[string]string{"twitter:card": "summary"},
+			},
+		},
+		{
+			name: "OpenGraph fallbacks",
+			html: `<html><head>
+<meta property="og:title" content="Only OG">
+<meta property="og:description" content="OG description">
+<meta property="og:url" content="https://example.com/canonical">
+<meta property="og:locale" content="fr_FR">
+</head></html>`,
+			want: PageMetadata{
+				Title:        "Only OG",
+				Description:  "OG description",
+				CanonicalURL: "https://example.com/canonical",
+				Language:     "fr_FR",
+				OpenGraph: map[string]string{
+					"og:title":       "Only OG",
+					"og:description": "OG description",
+					"og:url":         "https://example.com/canonical",
+					"og:locale":      "fr_FR",
+				},
+			},
+		},
+		{
+			name: "JSON-LD graph fills the gaps",
+			html: `<html><head><title>Page</title>
+<script type="application/ld+json">{"@context":"https://schema.org","@graph":[
+  {"@type":"WebSite","name":"Example"},
+  {"@type":"BlogPosting","headline":"Ignored","author":[{"@type":"Person","name":"Ada"},{"name":"Grace"}],
+   "datePublished":"2024-01-01","dateModified":"2024-01-02","inLanguage":"de"}
+]}</script>
+<script type="application/ld+json">{not json}</script>
+</head></html>`,
+			want: PageMetadata{
+				Title:         "Page",
+				Author:        "Ada, Grace",
+				PublishedTime: "2024-01-01",
+				ModifiedTime:  "2024-01-02",
+				Language:      "de",
+			},
+			types: []string{"WebSite", "BlogPosting"},
+		},
+		{
+			name: "byline and date from the body",
+			html: `<html><body><article>
+<p>By <a href="/authors/ada" rel="author">Ada <b>Lovelace</b></a></p>
+<time datetime="2023-01-01">New Year</time>
+<time pubdate datetime="2023-12-24">Christmas Eve</time>
+</article></body></html>`,
+			want: PageMetadata{
+				Author:        "Ada Lovelace",
+				PublishedTime: "2023-12-24",
+			},
+		},
+		{
+			name: "empty page",
+			html: "<p>No head at all</p>",
+			want: PageMetadata{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractPageMetadata(tt.html, baseURL)
+			types := got.jsonLDTypes()
+			got.JSONLD = nil // checked through jsonLDTypes
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("extractPageMetadata() =\n%+v\nwant\n%+v", *got, tt.want)
+			}
+			if !reflect.DeepEqual(types, tt.types) {
+				t.Errorf("jsonLDTypes() = %q, want %q", types, tt.types)
+			}
+		})
+	}
+}
+
+func TestJSONLDString(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{" Ada ", "Ada"},
+		{map[string]interface{}{"@type": "Person", "name": "Ada"}, "Ada"},
+		{[]interface{}{"Ada", map[string]interface{}{"name": "Grace"}, 42.0, ""}, "Ada, Grace"},
+		{42.0, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := jsonLDString(tt.value); got != tt.want {
+			t.Errorf("jsonLDString(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
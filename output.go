@@ -0,0 +1,193 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// WebReaderOutput is the structuredContent returned by web_reader
+type WebReaderOutput struct {
+	SourceURL        string           `json:"source_url"`
+	FetchedAt        string           `json:"fetched_at"`
+	ProcessingTimeMs float64          `json:"processing_time_ms"`
+	WordCount        int              `json:"word_count"`
+	ImageCount       int              `json:"image_count"`
+	LinkCount        int              `json:"link_count"`
+	Markdown         string           `json:"markdown"`
+	Page             *PageMetadata    `json:"page,omitempty"`
+	Images           []ImageInfo      `json:"images"`
+	Links            []LinkInfo       `json:"links"`
+	Translation      *TranslationInfo `json:"translation,omitempty"`
+	Fidelity         *FidelityReport  `json:"fidelity,omitempty"`
+	Usage            *TokenUsage      `json:"usage,omitempty"`
+}
+
+// buildWebReaderOutput assembles the structured result of a web_reader call
+func buildWebReaderOutput(markdown, sourceURL string, fetchedAt time.Time, processingTime float64, page *PageMetadata, images []ImageInfo, links []LinkInfo) WebReaderOutput {
+	if images == nil {
+		images = []ImageInfo{}
+	}
+	if links == nil {
+		links = []LinkInfo{}
+	}
+
+	return WebReaderOutput{
+		SourceURL:        sourceURL,
+		FetchedAt:        fetchedAt.UTC().Format(time.RFC3339),
+		ProcessingTimeMs: processingTime,
+		WordCount:        len(strings.Fields(markdown)),
+		ImageCount:       len(images),
+		LinkCount:        len(links),
+		Markdown:         markdown,
+		Page:             page,
+		Images:           images,
+		Links:            links,
+	}
+}
+
+// JSON Schemas shared by tool outputSchema declarations
+var (
+	imageInfoSchema = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"original_url": map[string]interface{}{"type": "string"},
+			"data_url":     map[string]interface{}{"type": "string"},
+			"alt":          map[string]interface{}{"type": "string"},
+			"width":        map[string]interface{}{"type": "integer"},
+			"height":       map[string]interface{}{"type": "integer"},
+			"size_bytes":   map[string]interface{}{"type": "integer"},
+			"status": map[string]interface{}{
+				"type": "string",
+				"enum": []string{imageStatusOK, imageStatusTooLarge, imageStatusTimeout, imageStatusSkipped, imageStatusFailed},
+			},
+			"format":  map[string]interface{}{"type": "string"},
+			"resized": map[string]interface{}{"type": "boolean"},
+			"caption": map[string]interface{}{"type": "string"},
+			"source":  map[string]interface{}{"type": "string"},
+		},
+		"required": []string{"original_url"},
+	}
+
+	linkInfoSchema = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"url":   map[string]interface{}{"type": "string"},
+			"text":  map[string]interface{}{"type": "string"},
+			"title": map[string]interface{}{"type": "string"},
+			"kind": map[string]interface{}{
+				"type": "string",
+				"enum": []string{linkKindInternal, linkKindExternal, linkKindSamePage},
+			},
+			"rel":       map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"target":    map[string]interface{}{"type": "string"},
+			"file_type": map[string]interface{}{"type": "string"},
+			"region":    map[string]interface{}{"type": "string"},
+		},
+		"required": []string{"url"},
+	}
+
+	stringMapSchema = map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": map[string]interface{}{"type": "string"},
+	}
+
+	pageMetadataSchema = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"title":          map[string]interface{}{"type": "string"},
+			"description":    map[string]interface{}{"type": "string"},
+			"canonical_url":  map[string]interface{}{"type": "string"},
+			"language":       map[string]interface{}{"type": "string"},
+			"author":         map[string]interface{}{"type": "string"},
+			"site_name":      map[string]interface{}{"type": "string"},
+			"published_time": map[string]interface{}{"type": "string"},
+			"modified_time":  map[string]interface{}{"type": "string"},
+			"open_graph":     stringMapSchema,
+			"twitter":        stringMapSchema,
+			"json_ld":        map[string]interface{}{"type": "array"},
+		},
+	}
+
+	translationInfoSchema = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"target_language": map[string]interface{}{"type": "string"},
+			"source_language": map[string]interface{}{"type": "string"},
+			"detection":       map[string]interface{}{"type": "string", "enum": []string{"script", "html_lang", "default"}},
+			"translated":      map[string]interface{}{"type": "boolean"},
+			"chunks":          map[string]interface{}{"type": "integer"},
+			"warnings":        map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+	}
+
+	fidelityReportSchema = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"mode":                   map[string]interface{}{"type": "string", "enum": []string{fidelityFlag, fidelityRepair}},
+			"score":                  map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1},
+			"links_checked":          map[string]interface{}{"type": "integer"},
+			"links_unverified":       map[string]interface{}{"type": "integer"},
+			"images_checked":         map[string]interface{}{"type": "integer"},
+			"images_unverified":      map[string]interface{}{"type": "integer"},
+			"code_blocks_checked":    map[string]interface{}{"type": "integer"},
+			"code_blocks_unverified": map[string]interface{}{"type": "integer"},
+			"repaired":               map[string]interface{}{"type": "integer"},
+			"issues": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"kind":   map[string]interface{}{"type": "string", "enum": []string{"link", "image", "code_block"}},
+						"value":  map[string]interface{}{"type": "string"},
+						"action": map[string]interface{}{"type": "string", "enum": []string{"flagged", "unlinked", "removed", "restored"}},
+					},
+				},
+			},
+		},
+	}
+
+	tokenUsageSchema = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"prompt_tokens":     map[string]interface{}{"type": "integer"},
+			"completion_tokens": map[string]interface{}{"type": "integer"},
+			"total_tokens":      map[string]interface{}{"type": "integer"},
+			"calls":             map[string]interface{}{"type": "integer"},
+			"estimated":         map[string]interface{}{"type": "boolean"},
+			"cost_usd":          map[string]interface{}{"type": "number"},
+		},
+	}
+
+	modelUsageSchema = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"model":             map[string]interface{}{"type": "string"},
+			"prompt_tokens":     map[string]interface{}{"type": "integer"},
+			"completion_tokens": map[string]interface{}{"type": "integer"},
+			"total_tokens":      map[string]interface{}{"type": "integer"},
+			"calls":             map[string]interface{}{"type": "integer"},
+			"estimated":         map[string]interface{}{"type": "boolean"},
+			"cost_usd":          map[string]interface{}{"type": "number"},
+		},
+	}
+
+	webReaderOutputSchema = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"source_url":         map[string]interface{}{"type": "string"},
+			"fetched_at":         map[string]interface{}{"type": "string", "format": "date-time"},
+			"processing_time_ms": map[string]interface{}{"type": "number"},
+			"word_count":         map[string]interface{}{"type": "integer"},
+			"image_count":        map[string]interface{}{"type": "integer"},
+			"link_count":         map[string]interface{}{"type": "integer"},
+			"markdown":           map[string]interface{}{"type": "string"},
+			"page":               pageMetadataSchema,
+			"images":             map[string]interface{}{"type": "array", "items": imageInfoSchema},
+			"links":              map[string]interface{}{"type": "array", "items": linkInfoSchema},
+			"translation":        translationInfoSchema,
+			"fidelity":           fidelityReportSchema,
+			"usage":              tokenUsageSchema,
+		},
+		"required": []string{"source_url", "fetched_at", "processing_time_ms", "word_count", "image_count", "link_count", "markdown", "images", "links"},
+	}
+)
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildWebReaderOutput(t *testing.T) {
+	fetchedAt := time.Date(2025, 3, 1, 12, 30, 0, 0, time.FixedZone("CET", 3600))
+	page := &PageMetadata{Title: "Guide"}
+	images := []ImageInfo{{OriginalURL: "https://example.com/a.png"}}
+	links := []LinkInfo{{URL: "https://example.com/b"}, {URL: "https://example.com/c"}}
+
+	got := buildWebReaderOutput("# Guide\n\nTwo  words.", "https://example.com/", fetchedAt, 12.5, page, images, links)
+	want := WebReaderOutput{
+		SourceURL:        "https://example.com/",
+		FetchedAt:        "2025-03-01T11:30:00Z",
+		ProcessingTimeMs: 12.5,
+		WordCount:        4,
+		ImageCount:       1,
+		LinkCount:        2,
+		Markdown:         "# Guide\n\nTwo  words.",
+		Page:             page,
+		Images:           images,
+		Links:            links,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("buildWebReaderOutput() = %+v, want %+v", got, want)
+	}
+}
+
+func TestBuildWebReaderOutputRequiredFields(t *testing.T) {
+	output := buildWebReaderOutput("", "https://example.com/", time.Now(), 0, nil, nil, nil)
+	if output.Images == nil || output.Links == nil {
+		t.Fatalf("images and links should be empty arrays, got %v and %v", output.Images, output.Links)
+	}
+
+	data, err := json.Marshal(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	// Every field the outputSchema requires must be present, even for an empty page
+	for _, name := range webReaderOutputSchema["required"].([]string) {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("structuredContent is missing required field %q", name)
+		}
+	}
+	for name := range fields {
+		if _, ok := webReaderOutputSchema["properties"].(map[string]interface{})[name]; !ok {
+			t.Errorf("structuredContent field %q is not declared in the outputSchema", name)
+		}
+	}
+}
+
+func TestWebPageOutputImagesAsContent(t *testing.T) {
+	images := []ImageInfo{{OriginalURL: "https://example.com/a.png", DataURL: "data:image/png;base64,AAAA", Status: imageStatusOK}}
+	page := &webPage{URL: "https://example.com/", Markdown: "text", Images: images}
+
+	if got := page.output().Images[0].DataURL; got != images[0].DataURL {
+		t.Errorf("data_url = %q, want it kept when images are not sent as content", got)
+	}
+
+	page.ImagesAsContent = true
+	output := page.output()
+	if output.Images[0].DataURL != "" {
+		t.Errorf("data_url = %q, want it left out when the image blocks carry the data", output.Images[0].DataURL)
+	}
+	if output.Images[0].OriginalURL != images[0].OriginalURL || output.Images[0].Status != imageStatusOK {
+		t.Errorf("image = %+v, want the rest of the image info kept", output.Images[0])
+	}
+	if images[0].DataURL == "" {
+		t.Error("output() cleared the data URL on the page's own images")
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const defaultPromptProfile = "default"
+
+// promptDescriptionRegex reads a profile description from a leading {{/* ... */}} comment
+var promptDescriptionRegex = regexp.MustCompile(`^\s*\{\{-?\s*/\*\s*(.*?)\s*\*/\s*-?\}\}`)
+
+// promptProfile is a named system prompt template used to convert pages to Markdown
+type promptProfile struct {
+	Name        string
+	Description string
+	Source      string // "builtin" or the template file path
+	Template    *template.Template
+}
+
+// promptData is what conversion prompt templates can refer to: the page's
+// URL and host plus every PageMetadata field, e.g. {{.Title}} or {{.OpenGraph.type}}
+type promptData struct {
+	URL  string
+	Host string
+	PageMetadata
+}
+
+// builtinPromptProfiles are available without any configuration; template
+// files with the same name replace them
+var builtinPromptProfiles = []struct {
+	name, description, text string
+}{
+	{
+		defaultPromptProfile,
+		"General-purpose conversion of a page's main content",
+		defaultConversionPrompt,
+	},
+	{
+		"docs",
+		"Technical documentation: keeps code samples, admonitions and heading hierarchy exactly",
+		"You are converting a technical documentation page{{if .Title}} titled \"{{.Title}}\"{{end}}{{if .SiteName}} from {{.SiteName}}{{end}} to Markdown. " +
+			"Extract only the documentation content, dropping site navigation, version pickers, search boxes, feedback widgets and footers. " +
+			"Keep the heading hierarchy exactly as on the page. Reproduce code samples verbatim in fenced code blocks tagged with their language. " +
+			"Render notes, warnings and tips as blockquotes starting with **Note:**, **Warning:** or **Tip:**. " +
+			"Keep all links in Markdown format: [link text](url), and all images as ![alt text](image_url). " +
+			"Return ONLY the Markdown content without any explanations or additional text.",
+	},
+	{
+		"news",
+		"News and blog articles: headline, byline and dateline, then the article body",
+		"You are converting a news or blog article{{if .Title}} titled \"{{.Title}}\"{{end}} to Markdown. " +
+			"Start with the headline as a level-one heading, followed by a line with the byline and date" +
+			"{{if .Author}} (the author is {{.Author}}){{end}}{{if .PublishedTime}} (published {{.PublishedTime}}){{end}}. " +
+			"Then give the full article body with its paragraphs, subheadings, quotes and images with captions. " +
+			"Drop related-article lists, newsletter sign-ups, comments, share buttons and advertising. " +
+			"Keep all links in Markdown format: [link text](url), and all images as ![alt text](image_url). " +
+			"Return ONLY the Markdown content without any explanations or additional text.",
+	},
+	{
+		"forum-thread",
+		"Forum, Q&A and discussion threads: one section per post with author and date",
+		"You are converting a discussion thread{{if .Title}} titled \"{{.Title}}\"{{end}}{{if .SiteName}} on {{.SiteName}}{{end}} to Markdown. " +
+			"Render the thread title as a level-one heading, then each post in page order as a level-three heading with the author and date, " +
+			"followed by the post body. Mark accepted answers and include vote counts when the page shows them. " +
+			"Keep quoted replies as blockquotes and code verbatim in fenced code blocks. " +
+			"Drop signatures, user badges, sidebars and reply forms. " +
+			"Keep all links in Markdown format: [link text](url). " +
+			"Return ONLY the Markdown content without any explanations or additional text.",
+	},
+	{
+		"api-reference",
+		"API reference pages: endpoints, signatures, parameter tables and examples",
+		"You are converting an API reference page{{if .Title}} titled \"{{.Title}}\"{{end}} to Markdown. " +
+			"Give each endpoint, function or type its own heading, with the HTTP method and path or the full signature in a code span. " +
+			"Render parameters, fields, headers and response codes as Markdown tables with name, type, required and description columns. " +
+			"Reproduce request and response examples verbatim in fenced code blocks tagged with their language. " +
+			"Omit nothing from parameter lists, even when they are long. Drop navigation, SDK pickers and feedback widgets. " +
+			"Keep all links in Markdown format: [link text](url). " +
+			"Return ONLY the Markdown content without any explanations or additional text.",
+	},
+}
+
+// promptProfiles holds the conversion prompt profiles by name; it is filled
+// at startup by configurePromptProfiles and only read afterwards
+var promptProfiles = make(map[string]*promptProfile)
+
+// configurePromptProfiles registers the built-in profiles and then every
+// *.tmpl file in PROMPT_TEMPLATES_DIR, named after the file
+func configurePromptProfiles() {
+	for _, builtin := range builtinPromptProfiles {
+		promptProfiles[builtin.name] = &promptProfile{
+			Name:        builtin.name,
+			Description: builtin.description,
+			Source:      "builtin",
+			Template:    template.Must(template.New(builtin.name).Option("missingkey=zero").Parse(builtin.text)),
+		}
+	}
+
+	dir := os.Getenv("PROMPT_TEMPLATES_DIR")
+	if dir == "" {
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil || len(files) == 0 {
+		log.Printf("No prompt templates found in PROMPT_TEMPLATES_DIR %q", dir)
+		return
+	}
+	for _, file := range files {
+		profile, err := loadPromptProfile(file)
+		if err != nil {
+			log.Printf("Ignoring prompt template %s: %v", file, err)
+			continue
+		}
+		promptProfiles[profile.Name] = profile
+		log.Printf("Loaded prompt profile %q from %s", profile.Name, file)
+	}
+}
+
+// loadPromptProfile parses a template file into a prompt profile
+func loadPromptProfile(file string) (*promptProfile, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(file), ".tmpl")
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// Render against empty data once so broken field references fail at startup
+	if _, err := renderPrompt(tmpl, "", nil); err != nil {
+		return nil, err
+	}
+
+	profile := &promptProfile{Name: name, Source: file, Template: tmpl}
+	if match := promptDescriptionRegex.FindStringSubmatch(string(data)); match != nil {
+		profile.Description = match[1]
+	}
+	return profile, nil
+}
+
+// promptProfileNames returns the configured profile names, default first
+func promptProfileNames() []string {
+	names := make([]string, 0, len(promptProfiles))
+	for name := range promptProfiles {
+		if name != defaultPromptProfile {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{defaultPromptProfile}, names...)
+}
+
+// conversionPrompt renders the system prompt of a profile for a page
+func conversionPrompt(profile, pageURL string, page *PageMetadata) (string, error) {
+	if profile == "" {
+		profile = defaultPromptProfile
+	}
+	p, ok := promptProfiles[profile]
+	if !ok {
+		// Profiles are registered at startup; callers outside the server get the default prompt
+		return defaultConversionPrompt, nil
+	}
+	return renderPrompt(p.Template, pageURL, page)
+}
+
+// renderPrompt executes a prompt template with the page's URL and metadata
+func renderPrompt(tmpl *template.Template, pageURL string, page *PageMetadata) (string, error) {
+	data := promptData{URL: pageURL}
+	if u, err := url.Parse(pageURL); err == nil {
+		data.Host = u.Host
+	}
+	if page != nil {
+		data.PageMetadata = *page
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// MCP prompt structures
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type PromptMessage struct {
+	Role    string      `json:"role"`
+	Content TextContent `json:"content"`
+}
+
+type ListPromptsResult struct {
+	Prompts []Prompt `json:"prompts"`
+}
+
+type GetPromptParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments"`
+}
+
+type GetPromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// handleListPrompts lists the reading workflows and the conversion prompt profiles as MCP prompts
+func handleListPrompts(msg *JSONRPCMessage) *JSONRPCMessage {
+	var prompts []Prompt
+	for _, workflow := range promptWorkflows {
+		prompts = append(prompts, workflow.Prompt)
+	}
+	for _, name := range promptProfileNames() {
+		profile := promptProfiles[name]
+		description := profile.Description
+		if description == "" {
+			description = fmt.Sprintf("Read a page with the %q conversion profile", name)
+		}
+		prompts = append(prompts, Prompt{
+			Name:        "convert-" + name,
+			Description: description,
+			Arguments: []PromptArgument{
+				{Name: "url", Description: "The page to read", Required: true},
+			},
+		})
+	}
+
+	return &JSONRPCMessage{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  ListPromptsResult{Prompts: prompts},
+	}
+}
+
+// handleGetPrompt renders one of the prompts returned by prompts/list
+func handleGetPrompt(msg *JSONRPCMessage) *JSONRPCMessage {
+	var params GetPromptParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return newErrorResponse(msg.ID, -32602, "Invalid params")
+	}
+
+	if workflow, ok := findPromptWorkflow(params.Name); ok {
+		if missing := missingPromptArgument(workflow.Prompt, params.Arguments); missing != "" {
+			return newErrorResponse(msg.ID, -32602, fmt.Sprintf("Missing required argument: %s", missing))
+		}
+		return &JSONRPCMessage{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Result: GetPromptResult{
+				Description: workflow.Description,
+				Messages: []PromptMessage{
+					{Role: "user", Content: TextContent{Type: "text", Text: workflow.render(params.Arguments)}},
+				},
+			},
+		}
+	}
+
+	name, isProfile := strings.CutPrefix(params.Name, "convert-")
+	profile, ok := promptProfiles[name]
+	if !isProfile || !ok {
+		return newErrorResponse(msg.ID, -32602, fmt.Sprintf("Unknown prompt: %s", params.Name))
+	}
+	pageURL := strings.TrimSpace(params.Arguments["url"])
+	if pageURL == "" {
+		return newErrorResponse(msg.ID, -32602, "Missing required argument: url")
+	}
+
+	instructions, err := renderPrompt(profile.Template, pageURL, nil)
+	if err != nil {
+		return newErrorResponse(msg.ID, -2, fmt.Sprintf("Failed to render prompt: %v", err))
+	}
+
+	text := fmt.Sprintf("Read %s with the web_reader tool, passing prompt_profile %q.\n\n"+
+		"The page will be converted with these instructions:\n\n%s", pageURL, name, instructions)
+
+	return &JSONRPCMessage{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result: GetPromptResult{
+			Description: profile.Description,
+			Messages: []PromptMessage{
+				{Role: "user", Content: TextContent{Type: "text", Text: text}},
+			},
+		},
+	}
+}
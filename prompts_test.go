@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// usePromptProfiles registers the built-in profiles plus the templates in dir
+// for the duration of a test
+func usePromptProfiles(t *testing.T, dir string) {
+	t.Helper()
+	saved := promptProfiles
+	promptProfiles = make(map[string]*promptProfile)
+	t.Setenv("PROMPT_TEMPLATES_DIR", dir)
+	configurePromptProfiles()
+	t.Cleanup(func() { promptProfiles = saved })
+}
+
+func TestRenderPrompt(t *testing.T) {
+	tmpl := template.Must(template.New("t").Option("missingkey=zero").Parse(
+		`{{/* test */}} Convert {{.URL}} from {{.Host}}{{if .Title}}, titled "{{.Title}}"{{end}}{{with .OpenGraph}} ({{index . "og:type"}}){{end}}. `))
+
+	tests := []struct {
+		name    string
+		pageURL string
+		page    *PageMetadata
+		want    string
+	}{
+		{
+			name:    "URL only",
+			pageURL: "https://example.com/docs",
+			want:    "Convert https://example.com/docs from example.com.",
+		},
+		{
+			name:    "page metadata",
+			pageURL: "https://example.com/post",
+			page:    &PageMetadata{Title: "Hello", OpenGraph: map[string]string{"og:type": "article"}},
+			want:    `Convert https://example.com/post from example.com, titled "Hello" (article).`,
+		},
+		{
+			name: "empty data",
+			want: "Convert  from .",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderPrompt(tmpl, tt.pageURL, tt.page)
+			if err != nil {
+				t.Fatalf("renderPrompt() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("renderPrompt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPromptProfile(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		file        string
+		content     string
+		description string
+		wantErr     bool
+	}{
+		{
+			file:        "recipes.tmpl",
+			content:     "{{- /* Recipes: ingredients first */ -}}\nConvert the recipe{{if .Title}} {{.Title}}{{end}}.",
+			description: "Recipes: ingredients first",
+		},
+		{
+			file:    "plain.tmpl",
+			content: "Convert {{.URL}}.",
+		},
+		{
+			file:    "syntax.tmpl",
+			content: "Convert {{.Title",
+			wantErr: true,
+		},
+		{
+			file:    "field.tmpl",
+			content: "Convert {{.NoSuchField}}.",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			profile, err := loadPromptProfile(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadPromptProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if name := strings.TrimSuffix(tt.file, ".tmpl"); profile.Name != name || profile.Source != path {
+				t.Errorf("profile = %q from %q, want %q from %q", profile.Name, profile.Source, name, path)
+			}
+			if profile.Description != tt.description {
+				t.Errorf("description = %q, want %q", profile.Description, tt.description)
+			}
+		})
+	}
+
+	if _, err := loadPromptProfile(filepath.Join(dir, "missing.tmpl")); err == nil {
+		t.Error("loadPromptProfile() of a missing file succeeded")
+	}
+}
+
+func TestConfigurePromptProfiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"docs.tmpl":   "{{/* Our docs */}}Convert our docs at {{.URL}}.",
+		"alpha.tmpl":  "Alpha {{.Host}}.",
+		"broken.tmpl": "{{.Nope}}",
+		"notes.txt":   "not a template",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	usePromptProfiles(t, dir)
+
+	want := []string{defaultPromptProfile, "alpha", "api-reference", "docs", "forum-thread", "news"}
+	if got := promptProfileNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("promptProfileNames() = %q, want %q", got, want)
+	}
+
+	// A template file replaces the built-in profile of the same name
+	if got, err := conversionPrompt("docs", "https://example.com/a", nil); err != nil || got != "Convert our docs at https://example.com/a." {
+		t.Errorf("conversionPrompt(docs) = %q, %v", got, err)
+	}
+	if got, _ := conversionPrompt("", "https://example.com/a", nil); got != defaultConversionPrompt {
+		t.Errorf("conversionPrompt(\"\") = %q, want the default prompt", got)
+	}
+	if got, _ := conversionPrompt("news", "https://example.com/a", &PageMetadata{Title: "Launch", Author: "Ada"}); !strings.Contains(got, `titled "Launch"`) || !strings.Contains(got, "the author is Ada") {
+		t.Errorf("conversionPrompt(news) = %q, want the title and author filled in", got)
+	}
+}
+
+func TestHandleGetPromptProfiles(t *testing.T) {
+	usePromptProfiles(t, "")
+
+	tests := []struct {
+		name      string
+		params    string
+		wantError string
+		contains  []string
+	}{
+		{
+			name:     "conversion profile",
+			params:   `{"name":"convert-api-reference","arguments":{"url":" https://example.com/api "}}`,
+			contains: []string{`Read https://example.com/api with the web_reader tool, passing prompt_profile "api-reference".`, "You are converting an API reference page"},
+		},
+		{
+			name:      "missing url",
+			params:    `{"name":"convert-news","arguments":{}}`,
+			wantError: "Missing required argument: url",
+		},
+		{
+			name:      "unknown profile",
+			params:    `{"name":"convert-recipes","arguments":{"url":"https://example.com/"}}`,
+			wantError: "Unknown prompt: convert-recipes",
+		},
+		{
+			name:      "profile name without the prefix",
+			params:    `{"name":"news","arguments":{"url":"https://example.com/"}}`,
+			wantError: "Unknown prompt: news",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := handleGetPrompt(&JSONRPCMessage{JSONRPC: "2.0", ID: 1, Method: "prompts/get", Params: json.RawMessage(tt.params)})
+			if tt.wantError != "" {
+				if resp.Error == nil || resp.Error.Message != tt.wantError {
+					t.Fatalf("error = %+v, want %q", resp.Error, tt.wantError)
+				}
+				return
+			}
+			if resp.Error != nil {
+				t.Fatalf("unexpected error %+v", resp.Error)
+			}
+			text := resp.Result.(GetPromptResult).Messages[0].Content.Text
+			for _, s := range tt.contains {
+				if !strings.Contains(text, s) {
+					t.Errorf("prompt does not contain %q:\n%s", s, text)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHostRate     = 2.0
+	defaultHostBurst    = 4
+	defaultHostMaxConns = 4
+
+	// maxLimiterWait bounds how long a request queues for a host before failing
+	maxLimiterWait = 2 * maxCrawlDelay
+	// robotsRetryInterval is how long to wait before asking an unreachable host for robots.txt again
+	robotsRetryInterval = time.Minute
+)
+
+// hostLimiter is a token bucket plus a connection semaphore for a single host
+type hostLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+	conns  chan struct{}
+
+	robotsMu      sync.Mutex // held while robots.txt is fetched so the host sees one request
+	robotsChecked bool
+	robotsRetryAt time.Time
+}
+
+// hostLimiterPool hands out per-host limiters shared by every tool in the process
+type hostLimiterPool struct {
+	mu            sync.Mutex
+	hosts         map[string]*hostLimiter
+	rate          float64
+	burst         int
+	maxConns      int
+	respectRobots bool
+}
+
+// errNoSlot is returned when a request gave up waiting for its host's limiter
+// without having been sent
+var errNoSlot = errors.New("rate limiter: no slot")
+
+var outboundLimiter = newHostLimiterPool(defaultHostRate, defaultHostBurst, defaultHostMaxConns, true)
+
+func newHostLimiterPool(rate float64, burst, maxConns int, respectRobots bool) *hostLimiterPool {
+	if burst < 1 {
+		burst = 1
+	}
+	if maxConns < 1 {
+		maxConns = 1
+	}
+	return &hostLimiterPool{
+		hosts:         make(map[string]*hostLimiter),
+		rate:          rate,
+		burst:         burst,
+		maxConns:      maxConns,
+		respectRobots: respectRobots,
+	}
+}
+
+// configureRateLimits reads the per-host limits from the environment
+func configureRateLimits() {
+	rate := defaultHostRate
+	burst := defaultHostBurst
+	maxConns := defaultHostMaxConns
+	respectRobots := true
+
+	if v := os.Getenv("HOST_RATE_LIMIT"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			rate = f
+		} else {
+			log.Printf("Ignoring invalid HOST_RATE_LIMIT %q: %v", v, err)
+		}
+	}
+	if v := os.Getenv("HOST_RATE_BURST"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			burst = n
+		} else {
+			log.Printf("Ignoring invalid HOST_RATE_BURST %q: %v", v, err)
+		}
+	}
+	if v := os.Getenv("HOST_MAX_CONNECTIONS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			maxConns = n
+		} else {
+			log.Printf("Ignoring invalid HOST_MAX_CONNECTIONS %q: %v", v, err)
+		}
+	}
+	if v := os.Getenv("RESPECT_CRAWL_DELAY"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			respectRobots = b
+		} else {
+			log.Printf("Ignoring invalid RESPECT_CRAWL_DELAY %q: %v", v, err)
+		}
+	}
+
+	outboundLimiter = newHostLimiterPool(rate, burst, maxConns, respectRobots)
+	log.Printf("Outbound limits: %.2f req/s per host (burst %d), %d connections per host, crawl-delay respected: %v",
+		rate, burst, maxConns, respectRobots)
+}
+
+// get returns the limiter for the given URL's host, creating it on first use.
+// Unless checkRobots is false, which the robots.txt fetch itself uses, the
+// host's Crawl-delay is looked up before the limiter is first handed out.
+func (p *hostLimiterPool) get(u *url.URL, checkRobots bool) *hostLimiter {
+	host := strings.ToLower(u.Host)
+
+	p.mu.Lock()
+	hl, ok := p.hosts[host]
+	if !ok {
+		hl = &hostLimiter{
+			rate:   p.rate,
+			burst:  float64(p.burst),
+			tokens: float64(p.burst),
+			last:   time.Now(),
+			conns:  make(chan struct{}, p.maxConns),
+		}
+		p.hosts[host] = hl
+	}
+	p.mu.Unlock()
+
+	if checkRobots && p.respectRobots && (u.Scheme == "http" || u.Scheme == "https") {
+		hl.checkCrawlDelay(u)
+	}
+
+	return hl
+}
+
+// checkCrawlDelay applies the host's robots.txt Crawl-delay once it has been
+// read. A host that could not be asked is asked again after robotsRetryInterval.
+func (hl *hostLimiter) checkCrawlDelay(u *url.URL) {
+	hl.robotsMu.Lock()
+	defer hl.robotsMu.Unlock()
+	if hl.robotsChecked || time.Now().Before(hl.robotsRetryAt) {
+		return
+	}
+
+	delay, err := fetchCrawlDelay(u)
+	if err != nil {
+		hl.robotsRetryAt = time.Now().Add(robotsRetryInterval)
+		log.Printf("Could not read robots.txt for %s, retrying in %v: %v", u.Host, robotsRetryInterval, err)
+		return
+	}
+	hl.robotsChecked = true
+	if delay > 0 {
+		hl.applyCrawlDelay(delay)
+		log.Printf("Applying robots.txt Crawl-delay of %v for %s", delay, u.Host)
+	}
+}
+
+// applyCrawlDelay lowers the token rate so requests are at least delay apart
+func (hl *hostLimiter) applyCrawlDelay(delay time.Duration) {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+
+	delayRate := 1 / delay.Seconds()
+	if hl.rate <= 0 || delayRate < hl.rate {
+		hl.rate = delayRate
+	}
+	hl.burst = 1
+	if hl.tokens > 1 {
+		hl.tokens = 1
+	}
+}
+
+// acquire blocks until a connection slot and a rate token are available.
+// The returned function must be called to release the connection slot.
+func (hl *hostLimiter) acquire(ctx context.Context) (func(), error) {
+	select {
+	case hl.conns <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	release := func() { <-hl.conns }
+
+	if err := hl.waitToken(ctx); err != nil {
+		release()
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// waitToken takes one token from the bucket, sleeping until one is refilled
+func (hl *hostLimiter) waitToken(ctx context.Context) error {
+	for {
+		hl.mu.Lock()
+		if hl.rate <= 0 {
+			// A non-positive rate disables rate limiting
+			hl.mu.Unlock()
+			return nil
+		}
+
+		now := time.Now()
+		hl.tokens += now.Sub(hl.last).Seconds() * hl.rate
+		if hl.tokens > hl.burst {
+			hl.tokens = hl.burst
+		}
+		hl.last = now
+
+		if hl.tokens >= 1 {
+			hl.tokens--
+			hl.mu.Unlock()
+			return nil
+		}
+
+		wait := time.Duration((1 - hl.tokens) / hl.rate * float64(time.Second))
+		hl.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// limitedBody releases the host connection slot when the response body is closed
+type limitedBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *limitedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// doLimited performs an HTTP request subject to the per-host rate and connection limits
+func doLimited(client *http.Client, req *http.Request) (*http.Response, error) {
+	return outboundLimiter.do(client, req, true)
+}
+
+// acquire waits for a slot on the request's host, at most maxLimiterWait.
+// The returned function must be called to release the connection slot.
+func (p *hostLimiterPool) acquire(req *http.Request, checkRobots bool) (func(), error) {
+	ctx, cancel := context.WithTimeout(req.Context(), maxLimiterWait)
+	defer cancel()
+
+	release, err := p.get(req.URL, checkRobots).acquire(ctx)
+	if err != nil {
+		if req.Context().Err() == nil {
+			return nil, fmt.Errorf("%w for %s within %v", errNoSlot, req.URL.Host, maxLimiterWait)
+		}
+		return nil, fmt.Errorf("rate limiter: %w", err)
+	}
+	return release, nil
+}
+
+// do performs a request once the host's limiter lets it through
+func (p *hostLimiterPool) do(client *http.Client, req *http.Request, checkRobots bool) (*http.Response, error) {
+	release, err := p.acquire(req, checkRobots)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	resp.Body = &limitedBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
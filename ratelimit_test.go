@@ -0,0 +1,12 @@
+package main
+
+import "testing"
+
+// useTestLimiter swaps in a limiter that neither throttles nor asks for
+// robots.txt, so tests against a local server are not slowed down
+func useTestLimiter(t *testing.T) {
+	t.Helper()
+	saved := outboundLimiter
+	outboundLimiter = newHostLimiterPool(1000, 1000, 32, false)
+	t.Cleanup(func() { outboundLimiter = saved })
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	pageResourcePrefix = "webreader://page/"
+	urlResourcePrefix  = "webreader://url/"
+	markdownMimeType   = "text/markdown"
+
+	maxPageResources = 200
+	// maxPageResourceBytes keeps a few very long pages from holding most of the store's memory
+	maxPageResourceBytes = 1024 * 1024
+	// pageResourceTTL is how long resources/read on a URL resource serves the
+	// cached page before fetching it again
+	pageResourceTTL = 10 * time.Minute
+	// resourceReadTimeout bounds the fetch and conversion of an uncached URL resource
+	resourceReadTimeout  = 2 * time.Minute
+	resourceListPageSize = 100
+)
+
+// MCP resource structures
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+	Size        int    `json:"size,omitempty"`
+}
+
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text"`
+}
+
+type ListResourcesResult struct {
+	Resources  []Resource `json:"resources"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+type ListResourceTemplatesResult struct {
+	ResourceTemplates []ResourceTemplate `json:"resourceTemplates"`
+}
+
+type ReadResourceResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+// ResourceParams are the params of resources/read, resources/subscribe and resources/unsubscribe
+type ResourceParams struct {
+	URI string `json:"uri"`
+}
+
+// pageResource is a converted page kept for the resources capability
+type pageResource struct {
+	URL       string
+	Hash      string
+	Title     string
+	Markdown  string
+	FetchedAt time.Time
+}
+
+// uri returns the page's webreader://page/{hash} URI
+func (r *pageResource) uri() string {
+	return pageResourcePrefix + r.Hash
+}
+
+// resource returns the resources/list entry for the page
+func (r *pageResource) resource() Resource {
+	name := r.Title
+	if name == "" {
+		name = r.URL
+	}
+	return Resource{
+		URI:         r.uri(),
+		Name:        name,
+		Title:       r.Title,
+		Description: fmt.Sprintf("%s, converted %s", r.URL, r.FetchedAt.UTC().Format(time.RFC3339)),
+		MimeType:    markdownMimeType,
+		Size:        len(r.Markdown),
+	}
+}
+
+// pageResourceStore holds the most recently converted pages and the
+// resource URIs clients subscribed to
+type pageResourceStore struct {
+	mu            sync.Mutex
+	pages         map[string]*pageResource   // by hash
+	subscriptions map[string]map[string]bool // subscribed URIs by page hash, as the client spelled them
+}
+
+var pageResources = &pageResourceStore{
+	pages:         make(map[string]*pageResource),
+	subscriptions: make(map[string]map[string]bool),
+}
+
+// pageResourceHash identifies a URL's resource; it stays the same when the
+// page is refreshed so subscriptions keep working
+func pageResourceHash(pageURL string) string {
+	return markdownHash(pageURL)[:16]
+}
+
+// add stores a converted page. Clients are told when the list of pages
+// changes, and subscribers when a page they follow gets new content.
+// Pages over maxPageResourceBytes are not kept.
+func (s *pageResourceStore) add(pageURL, title, markdown string, fetchedAt time.Time) {
+	if markdown == "" || len(markdown) > maxPageResourceBytes {
+		return
+	}
+	hash := pageResourceHash(pageURL)
+
+	s.mu.Lock()
+	previous, existed := s.pages[hash]
+	if !existed && len(s.pages) >= maxPageResources {
+		s.evictOldest()
+	}
+	page := &pageResource{URL: pageURL, Hash: hash, Title: title, Markdown: markdown, FetchedAt: fetchedAt}
+	s.pages[hash] = page
+
+	var updated []string
+	if existed && previous.Markdown != markdown {
+		for uri := range s.subscriptions[hash] {
+			updated = append(updated, uri)
+		}
+	}
+	s.mu.Unlock()
+
+	if !existed {
+		sendNotification("notifications/resources/list_changed", map[string]interface{}{})
+	}
+	for _, uri := range updated {
+		log.Printf("Resource updated: %s", uri)
+		sendNotification("notifications/resources/updated", ResourceParams{URI: uri})
+	}
+}
+
+// evictOldest drops the page converted longest ago. The caller holds s.mu.
+func (s *pageResourceStore) evictOldest() {
+	oldest := ""
+	for hash, page := range s.pages {
+		if oldest == "" || page.FetchedAt.Before(s.pages[oldest].FetchedAt) {
+			oldest = hash
+		}
+	}
+	delete(s.pages, oldest)
+}
+
+// get returns a copy of a stored page by hash
+func (s *pageResourceStore) get(hash string) (pageResource, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	page, ok := s.pages[hash]
+	if !ok {
+		return pageResource{}, false
+	}
+	return *page, true
+}
+
+// list returns the stored pages, most recently converted first
+func (s *pageResourceStore) list() []pageResource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pages := make([]pageResource, 0, len(s.pages))
+	for _, page := range s.pages {
+		pages = append(pages, *page)
+	}
+	sort.Slice(pages, func(i, j int) bool {
+		if !pages[i].FetchedAt.Equal(pages[j].FetchedAt) {
+			return pages[i].FetchedAt.After(pages[j].FetchedAt)
+		}
+		return pages[i].URL < pages[j].URL
+	})
+	return pages
+}
+
+// subscribe records or removes a subscription to a resource URI of a page
+func (s *pageResourceStore) subscribe(hash, uri string, on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !on {
+		delete(s.subscriptions[hash], uri)
+		return
+	}
+	if s.subscriptions[hash] == nil {
+		s.subscriptions[hash] = make(map[string]bool)
+	}
+	s.subscriptions[hash][uri] = true
+}
+
+// parseResourceURI splits a webreader:// URI into its kind ("page" or "url")
+// and the page hash or decoded page URL
+func parseResourceURI(uri string) (kind, value string, err error) {
+	if hash, ok := strings.CutPrefix(uri, pageResourcePrefix); ok && hash != "" {
+		return "page", hash, nil
+	}
+	if encoded, ok := strings.CutPrefix(uri, urlResourcePrefix); ok && encoded != "" {
+		pageURL, err := url.PathUnescape(encoded)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid URL encoding: %w", err)
+		}
+		u, err := url.Parse(pageURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", "", fmt.Errorf("not an http(s) URL: %s", pageURL)
+		}
+		return "url", pageURL, nil
+	}
+	return "", "", fmt.Errorf("unsupported resource URI: %s", uri)
+}
+
+// handleListResources lists the converted pages, newest first
+func handleListResources(msg *JSONRPCMessage) *JSONRPCMessage {
+	var params struct {
+		Cursor string `json:"cursor"`
+	}
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return newErrorResponse(msg.ID, -32602, "Invalid params")
+		}
+	}
+
+	start := 0
+	if params.Cursor != "" {
+		n, err := strconv.Atoi(params.Cursor)
+		if err != nil || n < 0 {
+			return newErrorResponse(msg.ID, -32602, "Invalid cursor")
+		}
+		start = n
+	}
+
+	pages := pageResources.list()
+	result := ListResourcesResult{Resources: []Resource{}}
+	for i := start; i < len(pages) && i < start+resourceListPageSize; i++ {
+		result.Resources = append(result.Resources, pages[i].resource())
+	}
+	if start+resourceListPageSize < len(pages) {
+		result.NextCursor = strconv.Itoa(start + resourceListPageSize)
+	}
+
+	return &JSONRPCMessage{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  result,
+	}
+}
+
+// handleListResourceTemplates describes the webreader:// URI schemes
+func handleListResourceTemplates(msg *JSONRPCMessage) *JSONRPCMessage {
+	return &JSONRPCMessage{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result: ListResourceTemplatesResult{
+			ResourceTemplates: []ResourceTemplate{
+				{
+					URITemplate: urlResourcePrefix + "{encoded}",
+					Name:        "web-page",
+					Title:       "Web page by URL",
+					Description: "Any http(s) page converted to Markdown; {encoded} is the percent-encoded page URL. Fetched on first read and refreshed after 10 minutes.",
+					MimeType:    markdownMimeType,
+				},
+				{
+					URITemplate: pageResourcePrefix + "{hash}",
+					Name:        "converted-page",
+					Title:       "Converted page",
+					Description: "A page already converted by one of the tools, as listed by resources/list",
+					MimeType:    markdownMimeType,
+				},
+			},
+		},
+	}
+}
+
+// handleReadResource returns a converted page, fetching URL resources that
+// are not cached or have gone stale
+func handleReadResource(msg *JSONRPCMessage) *JSONRPCMessage {
+	var params ResourceParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil || params.URI == "" {
+		return newErrorResponse(msg.ID, -32602, "Invalid params")
+	}
+
+	kind, value, err := parseResourceURI(params.URI)
+	if err != nil {
+		return newErrorResponse(msg.ID, -32602, err.Error())
+	}
+
+	var page pageResource
+	switch kind {
+	case "page":
+		cached, ok := pageResources.get(value)
+		if !ok {
+			return newErrorResponse(msg.ID, -32002, fmt.Sprintf("Resource not found: %s", params.URI))
+		}
+		page = cached
+	case "url":
+		cached, ok := pageResources.get(pageResourceHash(value))
+		if !ok || time.Since(cached.FetchedAt) > pageResourceTTL {
+			input, err := parseWebReaderInput(map[string]interface{}{"url": value})
+			if err != nil {
+				return newErrorResponse(msg.ID, -32602, err.Error())
+			}
+			// readWebPage stores the page and notifies subscribers unless it is too large to keep
+			ctx, cancel := context.WithTimeout(context.Background(), resourceReadTimeout)
+			read, rpcErr := readWebPage(ctx, input)
+			cancel()
+			if rpcErr != nil {
+				return &JSONRPCMessage{JSONRPC: "2.0", ID: msg.ID, Error: rpcErr}
+			}
+			if read.Markdown == "" {
+				return newErrorResponse(msg.ID, -2, fmt.Sprintf("No content could be converted from %s", value))
+			}
+			cached = pageResource{URL: value, Hash: pageResourceHash(value), Markdown: read.Markdown, FetchedAt: read.FetchedAt}
+		}
+		page = cached
+	}
+
+	return &JSONRPCMessage{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result: ReadResourceResult{
+			Contents: []ResourceContents{
+				{URI: params.URI, MimeType: markdownMimeType, Text: page.Markdown},
+			},
+		},
+	}
+}
+
+// handleSubscribeResource handles resources/subscribe and resources/unsubscribe
+func handleSubscribeResource(msg *JSONRPCMessage, subscribe bool) *JSONRPCMessage {
+	var params ResourceParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil || params.URI == "" {
+		return newErrorResponse(msg.ID, -32602, "Invalid params")
+	}
+
+	kind, value, err := parseResourceURI(params.URI)
+	if err != nil {
+		return newErrorResponse(msg.ID, -32602, err.Error())
+	}
+	hash := value
+	if kind == "url" {
+		hash = pageResourceHash(value)
+	}
+	pageResources.subscribe(hash, params.URI, subscribe)
+
+	return &JSONRPCMessage{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  map[string]interface{}{},
+	}
+}
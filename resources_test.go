@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestParseResourceURI(t *testing.T) {
+	tests := []struct {
+		uri     string
+		kind    string
+		value   string
+		wantErr bool
+	}{
+		{uri: "webreader://page/3f2a9c", kind: "page", value: "3f2a9c"},
+		{uri: "webreader://url/https%3A%2F%2Fexample.com%2Fdocs%3Fa%3D1", kind: "url", value: "https://example.com/docs?a=1"},
+		{uri: "webreader://url/https://example.com/plain", kind: "url", value: "https://example.com/plain"},
+		{uri: "webreader://url/http%3A%2F%2Fexample.com%2F%E6%96%87", kind: "url", value: "http://example.com/文"},
+		{uri: "webreader://page/", wantErr: true},
+		{uri: "webreader://url/", wantErr: true},
+		{uri: "webreader://url/%zz", wantErr: true},
+		{uri: "webreader://url/ftp%3A%2F%2Fexample.com%2Ffile", wantErr: true},
+		{uri: "webreader://url/https%3A%2F%2F", wantErr: true},
+		{uri: "webreader://url/not-a-url", wantErr: true},
+		{uri: "webreader://other/x", wantErr: true},
+		{uri: "https://example.com", wantErr: true},
+		{uri: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		kind, value, err := parseResourceURI(tt.uri)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseResourceURI(%q) error = %v, wantErr %v", tt.uri, err, tt.wantErr)
+			continue
+		}
+		if kind != tt.kind || value != tt.value {
+			t.Errorf("parseResourceURI(%q) = %q, %q, want %q, %q", tt.uri, kind, value, tt.kind, tt.value)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// chunkTokens is the target size of a passage in estimated tokens;
+	// sections are split on paragraph boundaries
+	chunkTokens = 240
+	bm25K1      = 1.2
+	bm25B       = 0.75
+)
+
+var (
+	wordRegex      = regexp.MustCompile(`[\p{L}\p{N}]+`)
+	sentenceRegex  = regexp.MustCompile(`[^.!?。！？\n]+[.!?。！？]*`)
+	headingIDRegex = regexp.MustCompile(`(?is)<h[1-6]\b[^>]*\bid=["']([^"']+)["'][^>]*>(.*?)</h[1-6]>`)
+)
+
+// stopWords are skipped when ranking; they match nearly every passage
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"can": true, "do": true, "does": true, "for": true, "from": true, "how": true, "i": true, "in": true,
+	"is": true, "it": true, "of": true, "on": true, "or": true, "that": true, "the": true, "this": true,
+	"to": true, "was": true, "what": true, "when": true, "where": true, "which": true, "who": true,
+	"why": true, "will": true, "with": true, "you": true,
+}
+
+// pagePassage is a chunk of converted Markdown under one heading
+type pagePassage struct {
+	ID      string
+	Heading string // heading path, e.g. "API > Authentication"
+	Anchor  string // fragment identifier of the section on the source page
+	Text    string
+	Score   float64
+}
+
+// chunkMarkdown splits Markdown into passages of about chunkTokens tokens that
+// never cross a heading, numbering them p1, p2, ... in document order
+func chunkMarkdown(markdown string, anchors map[string]string) []pagePassage {
+	var passages []pagePassage
+	for _, section := range parseMarkdownSections(splitLines(markdown)) {
+		anchor := sectionAnchor(section.Title, anchors)
+
+		var current []string
+		tokens := 0
+		flush := func() {
+			text := strings.TrimSpace(strings.Join(current, "\n\n"))
+			if text != "" {
+				passages = append(passages, pagePassage{
+					ID:      "p" + strconv.Itoa(len(passages)+1),
+					Heading: sectionTitle(section.Heading),
+					Anchor:  anchor,
+					Text:    text,
+				})
+			}
+			current, tokens = nil, 0
+		}
+
+		for _, paragraph := range strings.Split(strings.Join(section.Body, "\n"), "\n\n") {
+			n := estimateTokens(paragraph)
+			if tokens > 0 && tokens+n > chunkTokens {
+				flush()
+			}
+			current = append(current, paragraph)
+			tokens += n
+		}
+		flush()
+	}
+	return passages
+}
+
+// rankPassages scores passages against the query with BM25, counting heading
+// words as part of each passage, and returns the best topK in score order
+func rankPassages(passages []pagePassage, query string, topK int) []pagePassage {
+	terms := searchTerms(query)
+	if len(passages) == 0 || len(terms) == 0 {
+		return nil
+	}
+
+	docs := make([][]string, len(passages))
+	df := make(map[string]int)
+	totalLen := 0
+	for i, p := range passages {
+		docs[i] = searchTerms(p.Heading + " " + p.Text)
+		totalLen += len(docs[i])
+		seen := make(map[string]bool)
+		for _, t := range docs[i] {
+			if !seen[t] {
+				seen[t] = true
+				df[t]++
+			}
+		}
+	}
+	avgLen := float64(totalLen) / float64(len(docs))
+	n := float64(len(docs))
+
+	ranked := make([]pagePassage, 0, len(passages))
+	for i, p := range passages {
+		tf := make(map[string]int)
+		for _, t := range docs[i] {
+			tf[t]++
+		}
+		score := 0.0
+		for _, t := range terms {
+			if tf[t] == 0 {
+				continue
+			}
+			idf := math.Log(1 + (n-float64(df[t])+0.5)/(float64(df[t])+0.5))
+			f := float64(tf[t])
+			score += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*float64(len(docs[i]))/avgLen))
+		}
+		if score > 0 {
+			p.Score = score
+			ranked = append(ranked, p)
+		}
+	}
+
+	sort.SliceStable(ranked, func(a, b int) bool { return ranked[a].Score > ranked[b].Score })
+	if len(ranked) > topK {
+		ranked = ranked[:topK]
+	}
+	return ranked
+}
+
+// searchTerms lowercases and tokenizes text, dropping English stop words and
+// a plural "s". Chinese and Japanese write words without spaces, so runs of
+// Han and kana characters are indexed as overlapping character bigrams.
+func searchTerms(text string) []string {
+	var terms []string
+	for _, w := range wordRegex.FindAllString(strings.ToLower(text), -1) {
+		runes := []rune(w)
+		start := 0
+		for i := 1; i <= len(runes); i++ {
+			if i < len(runes) && isIdeographic(runes[i]) == isIdeographic(runes[start]) {
+				continue
+			}
+			run := runes[start:i]
+			start = i
+			if isIdeographic(run[0]) {
+				terms = append(terms, characterBigrams(run)...)
+				continue
+			}
+
+			word := string(run)
+			if stopWords[word] {
+				continue
+			}
+			if len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && isASCII(word) {
+				word = word[:len(word)-1]
+			}
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// isIdeographic reports whether r belongs to a script written without spaces between words
+func isIdeographic(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+// isASCII reports whether s is plain ASCII, where English plural rules apply
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// characterBigrams splits a run of characters into overlapping pairs; a
+// single character is kept as it is
+func characterBigrams(run []rune) []string {
+	if len(run) == 1 {
+		return []string{string(run)}
+	}
+	bigrams := make([]string, 0, len(run)-1)
+	for i := 0; i+1 < len(run); i++ {
+		bigrams = append(bigrams, string(run[i:i+2]))
+	}
+	return bigrams
+}
+
+// bestExcerpt returns the sentences of a passage that share the most terms
+// with the query, so a citation quotes the supporting text rather than the whole chunk
+func bestExcerpt(text, query string, maxLen int) string {
+	queryTerms := make(map[string]bool)
+	for _, t := range searchTerms(query) {
+		queryTerms[t] = true
+	}
+
+	sentences := sentenceRegex.FindAllString(text, -1)
+	best, bestScore := 0, -1
+	for i, s := range sentences {
+		score := 0
+		for _, t := range searchTerms(s) {
+			if queryTerms[t] {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if len(sentences) == 0 {
+		return truncateString(strings.TrimSpace(text), maxLen)
+	}
+
+	excerpt := strings.TrimSpace(sentences[best])
+	for j := best + 1; j < len(sentences) && len(excerpt) < maxLen/2; j++ {
+		excerpt += " " + strings.TrimSpace(sentences[j])
+	}
+	return truncateString(excerpt, maxLen)
+}
+
+// headingAnchors maps heading text to the id attribute it carries in the source HTML
+func headingAnchors(htmlContent string) map[string]string {
+	anchors := make(map[string]string)
+	for _, match := range headingIDRegex.FindAllStringSubmatch(htmlContent, -1) {
+		text := strings.ToLower(htmlText(match[2]))
+		if _, ok := anchors[text]; !ok && text != "" {
+			anchors[text] = match[1]
+		}
+	}
+	return anchors
+}
+
+// sectionAnchor returns the fragment for a section: the heading's id on the
+// page when known, otherwise a GitHub-style slug of the heading text
+func sectionAnchor(heading string, anchors map[string]string) string {
+	if heading == "" {
+		return ""
+	}
+	heading = strings.Trim(htmlText(heading), "*_` ")
+	if id, ok := anchors[strings.ToLower(heading)]; ok {
+		return id
+	}
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(heading) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChunkMarkdown(t *testing.T) {
+	markdown := "Intro paragraph.\n\n" +
+		"# Guide\n\nOverview text.\n\n" +
+		"## Install\n\nRun the installer.\n\n" +
+		"## Usage\n\nCall the tool.\n\n" +
+		"## Usage\n\nMore usage.\n"
+	anchors := map[string]string{"install": "getting-started"}
+
+	got := chunkMarkdown(markdown, anchors)
+
+	want := []struct {
+		id, heading, anchor, text string
+	}{
+		{"p1", "(top of page)", "", "Intro paragraph."},
+		{"p2", "Guide", "guide", "Overview text."},
+		{"p3", "Guide > Install", "getting-started", "Run the installer."},
+		{"p4", "Guide > Usage", "usage", "Call the tool."},
+		{"p5", "Guide > Usage (2)", "usage", "More usage."},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("chunkMarkdown() returned %d passages, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		p := got[i]
+		if p.ID != w.id || p.Heading != w.heading || p.Anchor != w.anchor || !strings.Contains(p.Text, w.text) {
+			t.Errorf("passage %d = %+v, want id %q heading %q anchor %q containing %q", i, p, w.id, w.heading, w.anchor, w.text)
+		}
+	}
+}
+
+func TestChunkMarkdownSplitsLongSections(t *testing.T) {
+	paragraph := strings.TrimSpace(strings.Repeat("word ", chunkTokens)) // about chunkTokens*5/4 tokens
+	markdown := "## Long\n\n" + strings.Join([]string{paragraph, paragraph, paragraph}, "\n\n")
+
+	passages := chunkMarkdown(markdown, nil)
+	if len(passages) < 3 {
+		t.Fatalf("chunkMarkdown() returned %d passages, want at least 3", len(passages))
+	}
+	for _, p := range passages {
+		if p.Heading != "Long" {
+			t.Errorf("passage %s heading = %q, want %q", p.ID, p.Heading, "Long")
+		}
+	}
+
+	// Small paragraphs are packed together up to the target size
+	small := "## Short\n\none\n\ntwo\n\nthree"
+	if passages := chunkMarkdown(small, nil); len(passages) != 1 {
+		t.Errorf("short section split into %d passages, want 1", len(passages))
+	}
+}
+
+func TestRankPassages(t *testing.T) {
+	passages := []pagePassage{
+		{ID: "p1", Heading: "Installation", Text: "Download the binary and put it on your PATH."},
+		{ID: "p2", Heading: "Configuration", Text: "Set the API key in the environment. The key is read at startup."},
+		{ID: "p3", Heading: "Pricing", Text: "The service costs nothing for small projects."},
+		{ID: "p4", Heading: "Keys", Text: "Rotate API keys every ninety days."},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		topK  int
+		want  []string
+	}{
+		{"best match first", "Where do I set the API key?", 5, []string{"p2", "p4"}},
+		{"heading words count", "installation", 5, []string{"p1"}},
+		{"topK limits results", "set the API key", 1, []string{"p2"}},
+		{"no matching terms", "kubernetes helm chart", 5, nil},
+		{"only stop words", "what is the", 5, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, p := range rankPassages(passages, tt.query, tt.topK) {
+				if p.Score <= 0 {
+					t.Errorf("passage %s has score %v", p.ID, p.Score)
+				}
+				got = append(got, p.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rankPassages(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankPassagesCJK(t *testing.T) {
+	passages := []pagePassage{
+		{ID: "p1", Heading: "概要", Text: "このツールはウェブページを読み込みます。"},
+		{ID: "p2", Heading: "料金", Text: "料金は月額千円です。"},
+		{ID: "p3", Heading: "安装", Text: "下载二进制文件并安装。"},
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"料金はいくらですか", "p2"},
+		{"如何安装", "p3"},
+	}
+	for _, tt := range tests {
+		ranked := rankPassages(passages, tt.query, 1)
+		if len(ranked) != 1 || ranked[0].ID != tt.want {
+			t.Errorf("rankPassages(%q) = %+v, want %s first", tt.query, ranked, tt.want)
+		}
+	}
+}
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"The Quick brown foxes", []string{"quick", "brown", "foxe"}},
+		{"class glass is", []string{"class", "glass"}},
+		{"API v2 keys", []string{"api", "v2", "key"}},
+		{"東京都", []string{"東京", "京都"}},
+		{"日", []string{"日"}},
+		{"Go言語を学ぶ", []string{"go", "言語", "語を", "を学", "学ぶ"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := searchTerms(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("searchTerms(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSectionAnchor(t *testing.T) {
+	anchors := map[string]string{"install (linux)": "linux-install"}
+
+	tests := []struct {
+		heading string
+		want    string
+	}{
+		{"", ""},
+		{"Getting Started", "getting-started"},
+		{"**API** `v2`", "api-v2"},
+		{"Install (Linux)", "linux-install"},
+		{"Step (2)", "step-2"},
+		{"What's new?", "whats-new"},
+	}
+	for _, tt := range tests {
+		if got := sectionAnchor(tt.heading, anchors); got != tt.want {
+			t.Errorf("sectionAnchor(%q) = %q, want %q", tt.heading, got, tt.want)
+		}
+	}
+}
+
+func TestChunkMarkdownParenthesizedHeadings(t *testing.T) {
+	markdown := "## Install (macOS)\n\nUse brew.\n\n## Step (2)\n\nFirst.\n\n## Step (2)\n\nAgain."
+
+	var got [][2]string
+	for _, p := range chunkMarkdown(markdown, nil) {
+		got = append(got, [2]string{p.Heading, p.Anchor})
+	}
+	want := [][2]string{
+		{"Install (macOS)", "install-macos"},
+		{"Step (2)", "step-2"},
+		{"Step (2) (2)", "step-2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("chunkMarkdown() headings and anchors = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxCrawlDelay      = 30 * time.Second
+	robotsFetchTimeout = 5 * time.Second
+)
+
+// errNoRobotsTxt means the host answered that it has no robots.txt, which
+// allows everything, as opposed to the host not answering at all
+var errNoRobotsTxt = errors.New("no robots.txt")
+
+// fetchRobotsTxt downloads robots.txt for the host of the given URL. The
+// request counts against the host's limits but does not wait for its Crawl-delay.
+func fetchRobotsTxt(u *url.URL) (string, error) {
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+
+	client := &http.Client{
+		Timeout:   robotsFetchTimeout,
+		Transport: insecureTransport(),
+	}
+
+	req, err := http.NewRequest("GET", robotsURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := outboundLimiter.do(client, req, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		return "", fmt.Errorf("%w: HTTP %d", errNoRobotsTxt, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 512*1024))
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
+
+// fetchCrawlDelay returns the Crawl-delay that robots.txt sets for all user
+// agents. A host without robots.txt has none; other failures are returned.
+func fetchCrawlDelay(u *url.URL) (time.Duration, error) {
+	robots, err := fetchRobotsTxt(u)
+	if errors.Is(err, errNoRobotsTxt) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return parseCrawlDelay(robots), nil
+}
+
+// parseCrawlDelay extracts the Crawl-delay directive from the "User-agent: *" group
+func parseCrawlDelay(robots string) time.Duration {
+	scanner := bufio.NewScanner(strings.NewReader(robots))
+
+	inWildcardGroup := false
+	lastWasAgent := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !lastWasAgent {
+				inWildcardGroup = false
+			}
+			if value == "*" {
+				inWildcardGroup = true
+			}
+			lastWasAgent = true
+		case "crawl-delay":
+			lastWasAgent = false
+			if !inWildcardGroup {
+				continue
+			}
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds <= 0 {
+				continue
+			}
+			delay := time.Duration(seconds * float64(time.Second))
+			if delay > maxCrawlDelay {
+				delay = maxCrawlDelay
+			}
+			return delay
+		default:
+			lastWasAgent = false
+		}
+	}
+
+	return 0
+}
+
+// robotsRules holds the Allow/Disallow path rules that apply to all user agents
+type robotsRules struct {
+	allow    []robotsRule
+	disallow []robotsRule
+}
+
+// robotsRule is a path pattern compiled once when robots.txt is parsed, since
+// a crawl checks every discovered URL against all of the host's rules
+type robotsRule struct {
+	pattern string
+	expr    *regexp.Regexp
+}
+
+// parseRobotsRules extracts the Allow and Disallow rules of the "User-agent: *" group
+func parseRobotsRules(robots string) *robotsRules {
+	rules := &robotsRules{}
+	scanner := bufio.NewScanner(strings.NewReader(robots))
+
+	inWildcardGroup := false
+	lastWasAgent := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !lastWasAgent {
+				inWildcardGroup = false
+			}
+			if value == "*" {
+				inWildcardGroup = true
+			}
+			lastWasAgent = true
+		case "allow", "disallow":
+			lastWasAgent = false
+			if !inWildcardGroup || value == "" {
+				continue
+			}
+			rule := robotsRule{pattern: value, expr: compileRobotsPattern(value)}
+			if key == "allow" {
+				rules.allow = append(rules.allow, rule)
+			} else {
+				rules.disallow = append(rules.disallow, rule)
+			}
+		default:
+			lastWasAgent = false
+		}
+	}
+
+	return rules
+}
+
+// allowed reports whether a path may be fetched. The longest matching rule
+// wins and Allow beats Disallow on ties, as in RFC 9309.
+func (r *robotsRules) allowed(u *url.URL) bool {
+	if r == nil {
+		return true
+	}
+
+	target := u.EscapedPath()
+	if target == "" {
+		target = "/"
+	}
+	if u.RawQuery != "" {
+		target += "?" + u.RawQuery
+	}
+
+	best := -1
+	allow := true
+	for _, rule := range r.disallow {
+		if len(rule.pattern) > best && rule.expr.MatchString(target) {
+			best = len(rule.pattern)
+			allow = false
+		}
+	}
+	for _, rule := range r.allow {
+		if len(rule.pattern) >= best && rule.expr.MatchString(target) {
+			best = len(rule.pattern)
+			allow = true
+		}
+	}
+	return allow
+}
+
+// compileRobotsPattern turns a robots.txt path pattern into a regexp, supporting
+// "*" wildcards and a "$" end anchor
+func compileRobotsPattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	// Everything but the wildcard is quoted, so the expression always compiles
+	return regexp.MustCompile(expr)
+}
+
+// parseRobotsSitemaps returns the Sitemap URLs listed in robots.txt, which apply to every group
+func parseRobotsSitemaps(robots string) []string {
+	var sitemaps []string
+	scanner := bufio.NewScanner(strings.NewReader(robots))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), "sitemap") {
+			continue
+		}
+		if value = strings.TrimSpace(value); value != "" {
+			sitemaps = append(sitemaps, value)
+		}
+	}
+	return sitemaps
+}
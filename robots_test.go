@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseCrawlDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		robots string
+		want   time.Duration
+	}{
+		{
+			name:   "empty",
+			robots: "",
+			want:   0,
+		},
+		{
+			name:   "wildcard group",
+			robots: "User-agent: *\nCrawl-delay: 2\n",
+			want:   2 * time.Second,
+		},
+		{
+			name:   "fractional seconds",
+			robots: "User-agent: *\nCrawl-delay: 0.5\n",
+			want:   500 * time.Millisecond,
+		},
+		{
+			name:   "other agent only",
+			robots: "User-agent: Googlebot\nCrawl-delay: 5\n",
+			want:   0,
+		},
+		{
+			name:   "shared group with wildcard",
+			robots: "User-agent: Googlebot\nUser-agent: *\nCrawl-delay: 3\n",
+			want:   3 * time.Second,
+		},
+		{
+			name:   "wildcard after another group",
+			robots: "User-agent: Bingbot\nCrawl-delay: 9\n\nUser-agent: *\nDisallow: /private\nCrawl-delay: 1\n",
+			want:   time.Second,
+		},
+		{
+			name:   "case and comments",
+			robots: "user-AGENT: * # everyone\nCRAWL-DELAY: 4 # be gentle\n",
+			want:   4 * time.Second,
+		},
+		{
+			name:   "invalid and negative values ignored",
+			robots: "User-agent: *\nCrawl-delay: soon\nCrawl-delay: -1\n",
+			want:   0,
+		},
+		{
+			name:   "capped",
+			robots: "User-agent: *\nCrawl-delay: 100000\n",
+			want:   maxCrawlDelay,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseCrawlDelay(tt.robots); got != tt.want {
+				t.Errorf("parseCrawlDelay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileRobotsPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		target  string
+		want    bool
+	}{
+		{"/", "/anything", true},
+		{"/private", "/private", true},
+		{"/private", "/private/page.html", true},
+		{"/private", "/public/private", false},
+		{"/*.pdf", "/docs/report.pdf", true},
+		{"/*.pdf$", "/docs/report.pdf", true},
+		{"/*.pdf$", "/docs/report.pdf?download=1", false},
+		{"/search?q=", "/search?q=go", true},
+		{"/a*b", "/a/x/b", true},
+		{"/a*b", "/ac", false},
+		{"/file.html$", "/file.html", true},
+		{"/file.html$", "/file.htmlx", false},
+		{"/dot.", "/dotx", false},
+	}
+
+	for _, tt := range tests {
+		if got := compileRobotsPattern(tt.pattern).MatchString(tt.target); got != tt.want {
+			t.Errorf("compileRobotsPattern(%q) matches %q = %v, want %v", tt.pattern, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsRulesAllowed(t *testing.T) {
+	rules := parseRobotsRules(`User-agent: Googlebot
+Disallow: /
+
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+Disallow: /search?
+Allow: /page
+Disallow: /page
+Disallow:
+`)
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/", true},
+		{"https://example.com", true},
+		{"https://example.com/about", true},
+		{"https://example.com/private", false},
+		{"https://example.com/private/notes.html", false},
+		{"https://example.com/private/public/index.html", true},
+		{"https://example.com/files/a.pdf", false},
+		{"https://example.com/files/a.pdf?x=1", true},
+		{"https://example.com/search", true},
+		{"https://example.com/search?q=robots", false},
+		{"https://example.com/page", true}, // Allow wins a tie
+	}
+
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatalf("url.Parse(%q): %v", tt.url, err)
+		}
+		if got := rules.allowed(u); got != tt.want {
+			t.Errorf("allowed(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsRulesNilAllowsAll(t *testing.T) {
+	var rules *robotsRules
+	u, _ := url.Parse("https://example.com/private")
+	if !rules.allowed(u) {
+		t.Error("nil rules should allow every URL")
+	}
+}
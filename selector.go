@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// parseSelector compiles a comma-separated CSS selector list. Everything
+// cascadia understands is accepted, including combinators and pseudo-classes.
+func parseSelector(selector string) (cascadia.SelectorGroup, error) {
+	sel, err := cascadia.ParseGroup(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %w", strings.TrimSpace(selector), err)
+	}
+	return sel, nil
+}
+
+// parseHTMLDocument parses a page into a DOM tree the way browsers do,
+// closing unclosed elements and repairing misnested markup
+func parseHTMLDocument(htmlContent string) (*html.Node, error) {
+	return html.Parse(strings.NewReader(htmlContent))
+}
+
+// renderNode serializes a node and everything inside it back to HTML
+func renderNode(n *html.Node) string {
+	var b strings.Builder
+	if err := html.Render(&b, n); err != nil {
+		return ""
+	}
+	return b.String()
+}
+
+// nodeAttr returns the value of the named attribute, or "" when it is absent
+func nodeAttr(n *html.Node, name string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// nodeText returns the text inside a node with whitespace collapsed
+func nodeText(n *html.Node) string {
+	var parts []string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			parts = append(parts, n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+// hasAncestor reports whether an element named name encloses n
+func hasAncestor(n *html.Node, name string) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && p.Data == name {
+			return true
+		}
+	}
+	return false
+}
+
+// outermostMatches returns the elements matching sel that are not inside
+// another match, in document order
+func outermostMatches(root *html.Node, sel cascadia.Matcher) []*html.Node {
+	var matches []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && sel.Match(n) {
+			matches = append(matches, n)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return matches
+}
+
+// scopeHTML narrows a document to the elements matching include and removes
+// the elements matching exclude, so extraction and conversion only see the
+// parts of the page that were asked for. Empty selectors are ignored.
+func scopeHTML(htmlContent, include, exclude string) (string, error) {
+	if include == "" && exclude == "" {
+		return htmlContent, nil
+	}
+
+	doc, err := parseHTMLDocument(htmlContent)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	if exclude != "" {
+		sel, err := parseSelector(exclude)
+		if err != nil {
+			return "", err
+		}
+		for _, n := range outermostMatches(doc, sel) {
+			n.Parent.RemoveChild(n)
+		}
+	}
+
+	if include == "" {
+		return renderNode(doc), nil
+	}
+
+	sel, err := parseSelector(include)
+	if err != nil {
+		return "", err
+	}
+	matches := outermostMatches(doc, sel)
+	if len(matches) == 0 {
+		return "", fmt.Errorf("include_selector %q matched no elements", include)
+	}
+	fragments := make([]string, len(matches))
+	for i, n := range matches {
+		fragments[i] = renderNode(n)
+	}
+	return strings.Join(fragments, "\n"), nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		wantErr  bool
+	}{
+		{"article", false},
+		{"#main, .content", false},
+		{"main > article p:not(.ad)", false},
+		{"nav ~ div[data-role='body']", false},
+		{"a[href^=\"https\"]", false},
+		{"", true},
+		{"div[", true},
+		{"p:unknown-pseudo", true},
+	}
+
+	for _, tt := range tests {
+		_, err := parseSelector(tt.selector)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSelector(%q) error = %v, wantErr %v", tt.selector, err, tt.wantErr)
+		}
+	}
+}
+
+func TestScopeHTML(t *testing.T) {
+	page := `<html><body>
+<nav><a href="/">Home</a></nav>
+<article id="post"><h1>Title</h1><p>Body text</p><div class="ad">Buy now</div>
+<section><p>Nested</p></section></article>
+<aside class="ad">Sidebar ad</aside>
+<footer>Footer</footer>
+</body></html>`
+
+	tests := []struct {
+		name     string
+		include  string
+		exclude  string
+		contains []string
+		excludes []string
+		wantErr  bool
+	}{
+		{
+			name:     "no selectors",
+			contains: []string{"<nav>", "Footer", "Sidebar ad"},
+		},
+		{
+			name:     "include",
+			include:  "#post",
+			contains: []string{"<h1>Title</h1>", "Body text", "Nested"},
+			excludes: []string{"<nav>", "Footer", "Sidebar ad"},
+		},
+		{
+			name:     "exclude",
+			exclude:  ".ad, nav",
+			contains: []string{"Body text", "Footer"},
+			excludes: []string{"Buy now", "Sidebar ad", "Home"},
+		},
+		{
+			name:     "include and exclude",
+			include:  "article",
+			exclude:  ".ad",
+			contains: []string{"Body text"},
+			excludes: []string{"Buy now", "Footer"},
+		},
+		{
+			name:     "combinator",
+			include:  "article > p",
+			contains: []string{"<p>Body text</p>"},
+			excludes: []string{"Nested", "Title"},
+		},
+		{
+			name:    "include matches nothing",
+			include: "main",
+			wantErr: true,
+		},
+		{
+			name:    "invalid selector",
+			exclude: "div[",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scopeHTML(page, tt.include, tt.exclude)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("scopeHTML() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(got, s) {
+					t.Errorf("scopeHTML() = %q, missing %q", got, s)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(got, s) {
+					t.Errorf("scopeHTML() = %q, should not contain %q", got, s)
+				}
+			}
+		})
+	}
+
+	// Nested matches are emitted once, inside their outermost match
+	got, _ := scopeHTML(page, "article, section", "")
+	if n := strings.Count(got, "Nested"); n != 1 {
+		t.Errorf("nested match rendered %d times, want 1", n)
+	}
+}
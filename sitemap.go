@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSitemapMaxURLs = 500
+	maxSitemapFetches     = 50
+	maxSitemapDepth       = 3
+	maxSitemapSize        = 50 * 1024 * 1024
+)
+
+// SitemapURL is one <url> entry of a sitemap
+type SitemapURL struct {
+	Loc        string   `json:"loc"`
+	LastMod    string   `json:"lastmod,omitempty"`
+	Priority   *float64 `json:"priority,omitempty"`
+	ChangeFreq string   `json:"changefreq,omitempty"`
+	Sitemap    string   `json:"sitemap,omitempty"` // the sitemap that listed it
+}
+
+// ReadSitemapOutput is the structuredContent returned by read_sitemap
+type ReadSitemapOutput struct {
+	SourceURL string       `json:"source_url"`
+	Sitemaps  []string     `json:"sitemaps"` // every sitemap and index that was read
+	Entries   []SitemapURL `json:"entries"`
+	URLs      []string     `json:"urls"` // entry locations, ready for web_reader_batch
+	Matched   int          `json:"matched"`
+	Truncated bool         `json:"truncated"`
+	Errors    []string     `json:"errors,omitempty"`
+}
+
+// sitemapDocument covers both <urlset> and <sitemapindex> documents
+type sitemapDocument struct {
+	XMLName xml.Name
+	URLs    []struct {
+		Loc        string `xml:"loc"`
+		LastMod    string `xml:"lastmod"`
+		Priority   string `xml:"priority"`
+		ChangeFreq string `xml:"changefreq"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"sitemap"`
+}
+
+// readSitemapTool describes the read_sitemap tool
+func readSitemapTool() Tool {
+	return Tool{
+		Name:        "read_sitemap",
+		Description: "Discover and read a site's sitemaps (via robots.txt and /sitemap.xml), following sitemap indexes and gzipped sitemaps. Returns page URLs with lastmod and priority, filterable by path pattern and modification date, ready to pass to web_reader_batch.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"url": map[string]interface{}{
+					"type":        "string",
+					"description": "A site URL to discover sitemaps for, or the URL of a sitemap or sitemap index",
+				},
+				"path_pattern": map[string]interface{}{
+					"type":        "string",
+					"description": "Only return URLs whose path matches this regular expression, e.g. ^/docs/",
+				},
+				"modified_since": map[string]interface{}{
+					"type":        "string",
+					"description": "Only return URLs with a lastmod on or after this date (YYYY-MM-DD or RFC 3339)",
+				},
+				"max_urls": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of URLs to return (default: 500)",
+				},
+			},
+			"required": []string{"url"},
+		},
+		OutputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"source_url": map[string]interface{}{"type": "string"},
+				"sitemaps":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				"entries": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"loc":        map[string]interface{}{"type": "string"},
+							"lastmod":    map[string]interface{}{"type": "string"},
+							"priority":   map[string]interface{}{"type": "number"},
+							"changefreq": map[string]interface{}{"type": "string"},
+							"sitemap":    map[string]interface{}{"type": "string"},
+						},
+						"required": []string{"loc"},
+					},
+				},
+				"urls":      map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				"matched":   map[string]interface{}{"type": "integer"},
+				"truncated": map[string]interface{}{"type": "boolean"},
+				"errors":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			},
+			"required": []string{"source_url", "sitemaps", "entries", "urls", "matched", "truncated"},
+		},
+	}
+}
+
+// handleReadSitemap processes the read_sitemap tool call
+func handleReadSitemap(id interface{}, args map[string]interface{}) *JSONRPCMessage {
+	sourceURL, ok := args["url"].(string)
+	if !ok || sourceURL == "" {
+		return newErrorResponse(id, -32602, "missing required parameter: url")
+	}
+	parsedURL, err := url.Parse(sourceURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		return newErrorResponse(id, -32602, fmt.Sprintf("invalid URL format: %s", sourceURL))
+	}
+
+	var pathPattern *regexp.Regexp
+	if v, ok := args["path_pattern"].(string); ok && v != "" {
+		if pathPattern, err = regexp.Compile(v); err != nil {
+			return newErrorResponse(id, -32602, fmt.Sprintf("invalid path_pattern: %v", err))
+		}
+	}
+	var modifiedSince time.Time
+	if v, ok := args["modified_since"].(string); ok && v != "" {
+		if modifiedSince, ok = parseSitemapDate(v); !ok {
+			return newErrorResponse(id, -32602, fmt.Sprintf("invalid modified_since date: %s", v))
+		}
+	}
+	maxURLs := defaultSitemapMaxURLs
+	if v, ok := args["max_urls"].(float64); ok && v > 0 {
+		maxURLs = int(v)
+	}
+
+	candidates, guessed := discoverSitemaps(parsedURL)
+	log.Printf("Reading sitemaps for %s: %v", sourceURL, candidates)
+
+	reader := &sitemapReader{seen: make(map[string]bool)}
+	var misses []string
+	for _, candidate := range candidates {
+		found, reported := len(reader.sitemaps), len(reader.errors)
+		reader.read(candidate, 0)
+		if !guessed {
+			continue
+		}
+		if len(reader.sitemaps) > found {
+			break
+		}
+		// A guessed location that does not exist is only worth reporting when none works
+		misses = append(misses, reader.errors[reported:]...)
+		reader.errors = reader.errors[:reported]
+	}
+
+	if len(reader.sitemaps) == 0 {
+		return newErrorResponse(id, -1, fmt.Sprintf("No sitemap found for %s: %s", sourceURL, strings.Join(append(reader.errors, misses...), "; ")))
+	}
+
+	output := ReadSitemapOutput{
+		SourceURL: sourceURL,
+		Sitemaps:  reader.sitemaps,
+		Entries:   []SitemapURL{},
+		URLs:      []string{},
+		Errors:    reader.errors,
+	}
+
+	seenLocs := make(map[string]bool)
+	for _, entry := range reader.entries {
+		if seenLocs[entry.Loc] {
+			continue
+		}
+		seenLocs[entry.Loc] = true
+
+		if pathPattern != nil {
+			u, err := url.Parse(entry.Loc)
+			if err != nil || !pathPattern.MatchString(u.Path) {
+				continue
+			}
+		}
+		if !modifiedSince.IsZero() {
+			lastMod, ok := parseSitemapDate(entry.LastMod)
+			if !ok || lastMod.Before(modifiedSince) {
+				continue
+			}
+		}
+
+		output.Matched++
+		if len(output.Entries) >= maxURLs {
+			output.Truncated = true
+			continue
+		}
+		output.Entries = append(output.Entries, entry)
+		output.URLs = append(output.URLs, entry.Loc)
+	}
+
+	return newToolResult(id, []interface{}{
+		TextContent{
+			Type: "text",
+			Text: formatSitemapReport(output),
+		},
+	}, output)
+}
+
+// discoverSitemaps returns the sitemap URLs to read for a site or sitemap URL.
+// Sitemaps listed in robots.txt win; otherwise the conventional locations are
+// returned with guessed set, to be tried in order until one exists.
+func discoverSitemaps(u *url.URL) (sitemaps []string, guessed bool) {
+	lowerPath := strings.ToLower(u.Path)
+	if strings.HasSuffix(lowerPath, ".xml") || strings.HasSuffix(lowerPath, ".xml.gz") || strings.Contains(lowerPath, "sitemap") {
+		return []string{u.String()}, false
+	}
+
+	if robots, err := fetchRobotsTxt(u); err == nil {
+		if sitemaps := parseRobotsSitemaps(robots); len(sitemaps) > 0 {
+			return sitemaps, false
+		}
+	}
+
+	root := &url.URL{Scheme: u.Scheme, Host: u.Host}
+	return []string{
+		root.ResolveReference(&url.URL{Path: "/sitemap.xml"}).String(),
+		root.ResolveReference(&url.URL{Path: "/sitemap_index.xml"}).String(),
+	}, true
+}
+
+// sitemapReader walks sitemaps and sitemap indexes, collecting URL entries
+type sitemapReader struct {
+	seen     map[string]bool
+	sitemaps []string
+	entries  []SitemapURL
+	errors   []string
+}
+
+func (r *sitemapReader) read(sitemapURL string, depth int) {
+	if r.seen[sitemapURL] || len(r.seen) >= maxSitemapFetches || depth > maxSitemapDepth {
+		return
+	}
+	r.seen[sitemapURL] = true
+
+	doc, err := fetchSitemap(sitemapURL)
+	if err != nil {
+		r.errors = append(r.errors, fmt.Sprintf("%s: %v", sitemapURL, err))
+		return
+	}
+	r.sitemaps = append(r.sitemaps, sitemapURL)
+
+	for _, child := range doc.Sitemaps {
+		if loc := strings.TrimSpace(child.Loc); loc != "" {
+			r.read(loc, depth+1)
+		}
+	}
+
+	for _, u := range doc.URLs {
+		entry := SitemapURL{
+			Loc:        strings.TrimSpace(u.Loc),
+			LastMod:    strings.TrimSpace(u.LastMod),
+			ChangeFreq: strings.TrimSpace(u.ChangeFreq),
+			Sitemap:    sitemapURL,
+		}
+		if entry.Loc == "" {
+			continue
+		}
+		if p, err := strconv.ParseFloat(strings.TrimSpace(u.Priority), 64); err == nil {
+			entry.Priority = &p
+		}
+		r.entries = append(r.entries, entry)
+	}
+}
+
+// fetchSitemap downloads and parses a sitemap, transparently handling gzip files
+func fetchSitemap(sitemapURL string) (*sitemapDocument, error) {
+	content, err := fetchWebContent(context.Background(), sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+
+	data := []byte(content)
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip sitemap: %w", err)
+		}
+		defer zr.Close()
+
+		data, err = io.ReadAll(io.LimitReader(zr, maxSitemapSize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress sitemap: %w", err)
+		}
+	}
+
+	var doc sitemapDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse sitemap XML: %w", err)
+	}
+	if doc.XMLName.Local != "urlset" && doc.XMLName.Local != "sitemapindex" {
+		return nil, fmt.Errorf("not a sitemap: root element <%s>", doc.XMLName.Local)
+	}
+
+	return &doc, nil
+}
+
+// parseSitemapDate parses the W3C datetime formats allowed in <lastmod>
+func parseSitemapDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02T15:04:05",
+		"2006-01-02",
+		"2006-01",
+		"2006",
+	} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// formatSitemapReport renders the sitemap URLs as a Markdown list
+func formatSitemapReport(output ReadSitemapOutput) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Sitemap for %s\n\n", output.SourceURL)
+	fmt.Fprintf(&b, "- Sitemaps read: %d\n", len(output.Sitemaps))
+	fmt.Fprintf(&b, "- Matching URLs: %d\n", output.Matched)
+	if output.Truncated {
+		fmt.Fprintf(&b, "- Returned: %d (raise max_urls for more)\n", len(output.Entries))
+	}
+	for _, e := range output.Errors {
+		fmt.Fprintf(&b, "- Error: %s\n", e)
+	}
+
+	b.WriteString("\n")
+	for _, entry := range output.Entries {
+		fmt.Fprintf(&b, "- %s", entry.Loc)
+		if entry.LastMod != "" {
+			fmt.Fprintf(&b, " (lastmod: %s)", entry.LastMod)
+		}
+		if entry.Priority != nil {
+			fmt.Fprintf(&b, " [priority %.1f]", *entry.Priority)
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
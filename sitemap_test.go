@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSitemapDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+		ok    bool
+	}{
+		{"2024-05-01T10:30:00+02:00", time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC), true},
+		{"2024-05-01T10:30:00.5Z", time.Date(2024, 5, 1, 10, 30, 0, 5e8, time.UTC), true},
+		{"2024-05-01T10:30Z", time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC), true},
+		{"2024-05-01T10:30:00", time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC), true},
+		{" 2024-05-01 ", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), true},
+		{"2024-05", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), true},
+		{"2024", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{"May 1, 2024", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseSitemapDate(tt.value)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("parseSitemapDate(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// sitemapTestServer serves a robots.txt that points at a sitemap index listing
+// a plain and a gzipped sitemap, or only /sitemap_index.xml when noRobots is set
+func sitemapTestServer(t *testing.T, noRobots bool) *httptest.Server {
+	t.Helper()
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/blog/new</loc><lastmod>2024-06-01</lastmod><priority>0.8</priority></url>
+  <url><loc>https://example.com/blog/old</loc><lastmod>2020-01-01</lastmod></url>
+  <url><loc>https://example.com/about</loc></url>
+</urlset>`))
+	zw.Close()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			if noRobots {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte("User-agent: *\nDisallow:\nSitemap: " + server.URL + "/sitemap_index.xml\n"))
+		case "/sitemap_index.xml":
+			w.Write([]byte(`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>` + server.URL + `/pages.xml</loc></sitemap>
+  <sitemap><loc>` + server.URL + `/posts.xml.gz</loc></sitemap>
+  <sitemap><loc>` + server.URL + `/missing.xml</loc></sitemap>
+  <sitemap><loc>` + server.URL + `/sitemap_index.xml</loc></sitemap>
+</sitemapindex>`))
+		case "/pages.xml":
+			w.Write([]byte(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc> https://example.com/about </loc><changefreq>yearly</changefreq></url>
+  <url><loc>https://example.com/contact</loc></url>
+  <url><loc></loc></url>
+</urlset>`))
+		case "/posts.xml.gz":
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Write(gz.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return server
+}
+
+func TestHandleReadSitemap(t *testing.T) {
+	useTestLimiter(t)
+	server := sitemapTestServer(t, false)
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		args      map[string]interface{}
+		urls      []string
+		matched   int
+		truncated bool
+	}{
+		{
+			name:    "index with plain and gzipped sitemaps",
+			args:    map[string]interface{}{"url": server.URL + "/"},
+			urls:    []string{"https://example.com/about", "https://example.com/contact", "https://example.com/blog/new", "https://example.com/blog/old"},
+			matched: 4,
+		},
+		{
+			name:    "path pattern",
+			args:    map[string]interface{}{"url": server.URL + "/", "path_pattern": "^/blog/"},
+			urls:    []string{"https://example.com/blog/new", "https://example.com/blog/old"},
+			matched: 2,
+		},
+		{
+			name:    "modified since drops undated entries",
+			args:    map[string]interface{}{"url": server.URL + "/", "modified_since": "2024-01-01"},
+			urls:    []string{"https://example.com/blog/new"},
+			matched: 1,
+		},
+		{
+			name:      "max urls",
+			args:      map[string]interface{}{"url": server.URL + "/sitemap_index.xml", "max_urls": float64(1)},
+			urls:      []string{"https://example.com/about"},
+			matched:   4,
+			truncated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := handleReadSitemap(1, tt.args)
+			if resp.Error != nil {
+				t.Fatalf("handleReadSitemap() error = %+v", resp.Error)
+			}
+			output := resp.Result.(map[string]interface{})["structuredContent"].(ReadSitemapOutput)
+
+			if !reflect.DeepEqual(output.URLs, tt.urls) {
+				t.Errorf("urls = %q, want %q", output.URLs, tt.urls)
+			}
+			if output.Matched != tt.matched || output.Truncated != tt.truncated {
+				t.Errorf("matched %d, truncated %v, want %d, %v", output.Matched, output.Truncated, tt.matched, tt.truncated)
+			}
+			wantSitemaps := []string{server.URL + "/sitemap_index.xml", server.URL + "/pages.xml", server.URL + "/posts.xml.gz"}
+			if !reflect.DeepEqual(output.Sitemaps, wantSitemaps) {
+				t.Errorf("sitemaps = %q, want %q", output.Sitemaps, wantSitemaps)
+			}
+			if len(output.Errors) != 1 || !strings.Contains(output.Errors[0], "/missing.xml") {
+				t.Errorf("errors = %q, want only the missing child sitemap", output.Errors)
+			}
+		})
+	}
+}
+
+func TestHandleReadSitemapEntries(t *testing.T) {
+	useTestLimiter(t)
+	server := sitemapTestServer(t, false)
+	defer server.Close()
+
+	resp := handleReadSitemap(1, map[string]interface{}{"url": server.URL + "/", "path_pattern": "^/(about|blog/new)$"})
+	output := resp.Result.(map[string]interface{})["structuredContent"].(ReadSitemapOutput)
+
+	priority := 0.8
+	want := []SitemapURL{
+		// The first sitemap to list a location wins
+		{Loc: "https://example.com/about", ChangeFreq: "yearly", Sitemap: server.URL + "/pages.xml"},
+		{Loc: "https://example.com/blog/new", LastMod: "2024-06-01", Priority: &priority, Sitemap: server.URL + "/posts.xml.gz"},
+	}
+	if !reflect.DeepEqual(output.Entries, want) {
+		t.Errorf("entries = %+v, want %+v", output.Entries, want)
+	}
+}
+
+func TestHandleReadSitemapGuessedLocation(t *testing.T) {
+	useTestLimiter(t)
+	server := sitemapTestServer(t, true)
+	defer server.Close()
+
+	resp := handleReadSitemap(1, map[string]interface{}{"url": server.URL + "/docs/"})
+	if resp.Error != nil {
+		t.Fatalf("handleReadSitemap() error = %+v", resp.Error)
+	}
+	output := resp.Result.(map[string]interface{})["structuredContent"].(ReadSitemapOutput)
+	// The missing /sitemap.xml guess is not reported once /sitemap_index.xml works
+	for _, e := range output.Errors {
+		if strings.Contains(e, "/sitemap.xml:") {
+			t.Errorf("errors = %q, want the failed guess left out", output.Errors)
+		}
+	}
+	if output.Matched != 4 {
+		t.Errorf("matched = %d, want 4", output.Matched)
+	}
+}
+
+func TestHandleReadSitemapErrors(t *testing.T) {
+	useTestLimiter(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/feed.xml" {
+			w.Write([]byte(`<rss><channel></channel></rss>`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		code    int
+		message string
+	}{
+		{"missing url", map[string]interface{}{}, -32602, "missing required parameter: url"},
+		{"not http", map[string]interface{}{"url": "ftp://example.com/"}, -32602, "invalid URL format"},
+		{"bad pattern", map[string]interface{}{"url": server.URL, "path_pattern": "("}, -32602, "invalid path_pattern"},
+		{"bad date", map[string]interface{}{"url": server.URL, "modified_since": "yesterday"}, -32602, "invalid modified_since"},
+		{"no sitemap", map[string]interface{}{"url": server.URL + "/"}, -1, "No sitemap found"},
+		{"not a sitemap", map[string]interface{}{"url": server.URL + "/feed.xml"}, -1, "root element <rss>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := handleReadSitemap(1, tt.args)
+			if resp.Error == nil || resp.Error.Code != tt.code || !strings.Contains(resp.Error.Message, tt.message) {
+				t.Errorf("error = %+v, want %d containing %q", resp.Error, tt.code, tt.message)
+			}
+		})
+	}
+}
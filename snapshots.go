@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	maxSnapshotsPerURL = 20
+	maxSnapshotURLs    = 500
+)
+
+// PageSnapshot is the converted Markdown of a URL at one point in time
+type PageSnapshot struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Hash      string    `json:"hash"`
+	Markdown  string    `json:"markdown"`
+}
+
+// pageSnapshotStore keeps snapshot history per URL, oldest first. When a
+// directory is configured each URL's history is also persisted as a JSON file
+// so change tracking survives restarts.
+type pageSnapshotStore struct {
+	mu      sync.Mutex
+	dir     string
+	history map[string][]*PageSnapshot
+	used    map[string]time.Time
+}
+
+var snapshotStore = &pageSnapshotStore{
+	history: make(map[string][]*PageSnapshot),
+	used:    make(map[string]time.Time),
+}
+
+// configureSnapshots reads the snapshot directory from the environment
+func configureSnapshots() {
+	dir := os.Getenv("SNAPSHOT_DIR")
+	if dir == "" {
+		return
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("Ignoring SNAPSHOT_DIR %q: %v", dir, err)
+		return
+	}
+	snapshotStore.dir = dir
+	log.Printf("Persisting page snapshots in %s", dir)
+}
+
+// list returns the snapshot history of a URL, oldest first
+func (s *pageSnapshotStore) list(pageURL string) []*PageSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*PageSnapshot(nil), s.load(pageURL)...)
+}
+
+// find returns the snapshot of a URL with the given ID, or nil
+func (s *pageSnapshotStore) find(pageURL, id string) *PageSnapshot {
+	for _, snap := range s.list(pageURL) {
+		if snap.ID == id {
+			return snap
+		}
+	}
+	return nil
+}
+
+// add records a new snapshot unless the Markdown is identical to the latest one,
+// in which case the latest snapshot is returned and added is false
+func (s *pageSnapshotStore) add(pageURL, markdown string, fetchedAt time.Time) (snap *PageSnapshot, added bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := s.load(pageURL)
+	hash := markdownHash(markdown)
+	if n := len(history); n > 0 && history[n-1].Hash == hash {
+		return history[n-1], false
+	}
+
+	snap = &PageSnapshot{
+		ID:        newSnapshotID(),
+		URL:       pageURL,
+		FetchedAt: fetchedAt.UTC(),
+		Hash:      hash,
+		Markdown:  markdown,
+	}
+	history = append(history, snap)
+	if len(history) > maxSnapshotsPerURL {
+		history = history[len(history)-maxSnapshotsPerURL:]
+	}
+	s.history[pageURL] = history
+
+	if s.dir != "" {
+		if err := s.save(pageURL, history); err != nil {
+			log.Printf("Failed to persist snapshots for %s: %v", pageURL, err)
+		}
+	}
+
+	return snap, true
+}
+
+// load returns a URL's history, reading it from disk on first use. The caller holds s.mu.
+func (s *pageSnapshotStore) load(pageURL string) []*PageSnapshot {
+	s.used[pageURL] = time.Now()
+	if history, ok := s.history[pageURL]; ok {
+		return history
+	}
+
+	// Forget the least recently used URL once too many are held in memory
+	if len(s.history) >= maxSnapshotURLs {
+		oldest := ""
+		for u := range s.history {
+			if oldest == "" || s.used[u].Before(s.used[oldest]) {
+				oldest = u
+			}
+		}
+		delete(s.history, oldest)
+		delete(s.used, oldest)
+	}
+
+	var history []*PageSnapshot
+	if s.dir != "" {
+		if data, err := os.ReadFile(s.path(pageURL)); err == nil {
+			if err := json.Unmarshal(data, &history); err != nil {
+				log.Printf("Ignoring unreadable snapshot file for %s: %v", pageURL, err)
+				history = nil
+			}
+		}
+	}
+	s.history[pageURL] = history
+	return history
+}
+
+// save writes a URL's history atomically
+func (s *pageSnapshotStore) save(pageURL string, history []*PageSnapshot) error {
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, "snapshot-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(pageURL))
+}
+
+// path names a URL's snapshot file after a hash of the URL
+func (s *pageSnapshotStore) path(pageURL string) string {
+	sum := sha256.Sum256([]byte(pageURL))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+func markdownHash(markdown string) string {
+	sum := sha256.Sum256([]byte(markdown))
+	return hex.EncodeToString(sum[:])
+}
+
+func newSnapshotID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("snap-%d", time.Now().UnixNano())
+	}
+	return "snap-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// summarizeBatchTokens is how much text one model call summarizes, in
+	// estimated tokens so pages without spaces between words are measured
+	// fairly; longer pages are summarized in batches whose notes are then
+	// summarized again
+	summarizeBatchTokens = 4000
+	maxSummaryLevels     = 3
+	summarizeConcurrency = 3
+	summarizeTemperature = 0.3
+)
+
+// Summary styles accepted by summarize_page
+const (
+	summaryOneLine   = "one_line"
+	summaryParagraph = "paragraph"
+	summaryBullets   = "bullets"
+	summaryTLDR      = "tldr"
+)
+
+var (
+	outlineHeadingRegex = regexp.MustCompile(`(?is)<h([1-6])\b[^>]*>(.*?)</h[1-6]\s*>`)
+	outlineBlockRegex   = regexp.MustCompile(`(?i)</?(?:p|div|br|li|tr|section|article|blockquote|pre|table|ul|ol|dl|dd|dt|figure|figcaption|header|footer|main)\b[^>]*>`)
+	outlineSpaceRegex   = regexp.MustCompile(`[ \t\r\f\v]+`)
+	outlineBlankRegex   = regexp.MustCompile(`\n\s*\n\s*`)
+	citationSpaceRegex  = regexp.MustCompile(`[ \t]+([.,;:!?])`)
+	doubleSpaceRegex    = regexp.MustCompile(`(\S)[ \t]{2,}`) // inside a line only, so list indentation survives
+	trailingSpaceRegex  = regexp.MustCompile(`(?m)[ \t]+$`)
+)
+
+// summaryInstructions tells the model what each style should look like
+var summaryInstructions = map[string]string{
+	summaryOneLine:   "Write a single sentence of at most 30 words that captures what the page is about.",
+	summaryParagraph: "Write one paragraph of 80 to 150 words covering the page's main points.",
+	summaryBullets:   "Write a bullet outline that follows the page's structure: top-level bullets for the main sections, nested bullets for their key points.",
+	summaryTLDR:      "Start with \"TL;DR:\" and one or two sentences, then a \"Key facts:\" list of 3 to 8 bullets with concrete facts such as numbers, dates, names and versions.",
+}
+
+// SummarySection is a page section the summary drew on
+type SummarySection struct {
+	Heading   string `json:"heading"`
+	Anchor    string `json:"anchor,omitempty"`
+	Citations int    `json:"citations"`
+}
+
+// SummarizePageOutput is the structuredContent returned by summarize_page
+type SummarizePageOutput struct {
+	URL          string           `json:"url"`
+	Title        string           `json:"title,omitempty"`
+	Style        string           `json:"style"`
+	Summary      string           `json:"summary"`
+	Sections     []SummarySection `json:"sections"`
+	SourceTokens int              `json:"source_tokens"` // estimated size of the page text
+	Hierarchical bool             `json:"hierarchical"`
+	ModelCalls   int              `json:"model_calls"`
+	Usage        *TokenUsage      `json:"usage,omitempty"`
+}
+
+// summarizePageTool describes the summarize_page tool
+func summarizePageTool() Tool {
+	return Tool{
+		Name:        "summarize_page",
+		Description: "Summarize a web page as a one-line summary, a paragraph, a bullet outline or a TL;DR with key facts. Long pages are summarized hierarchically in batches, and the result lists which page sections contributed.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"url": map[string]interface{}{
+					"type":        "string",
+					"description": "The page to summarize",
+				},
+				"style": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{summaryOneLine, summaryParagraph, summaryBullets, summaryTLDR},
+					"description": "Summary form (default: paragraph)",
+				},
+				"max_words": map[string]interface{}{
+					"type":        "integer",
+					"description": "Upper bound on the summary length in words, overriding the style's default length",
+				},
+				"focus": map[string]interface{}{
+					"type":        "string",
+					"description": "Topic to emphasise, e.g. \"breaking changes\" or \"pricing\"",
+				},
+				"include_selector": map[string]interface{}{
+					"type":        "string",
+					"description": "CSS selector for the parts of the page to summarize",
+				},
+				"exclude_selector": map[string]interface{}{
+					"type":        "string",
+					"description": "CSS selector for elements to ignore",
+				},
+				"model": map[string]interface{}{
+					"type":        "string",
+					"description": "AI model to use (default: " + defaultModel + ")",
+				},
+				"maxTokens": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum tokens per model response (default: 4000)",
+				},
+			},
+			"required": []string{"url"},
+		},
+		OutputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"url":     map[string]interface{}{"type": "string"},
+				"title":   map[string]interface{}{"type": "string"},
+				"style":   map[string]interface{}{"type": "string", "enum": []string{summaryOneLine, summaryParagraph, summaryBullets, summaryTLDR}},
+				"summary": map[string]interface{}{"type": "string"},
+				"sections": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"heading":   map[string]interface{}{"type": "string"},
+							"anchor":    map[string]interface{}{"type": "string"},
+							"citations": map[string]interface{}{"type": "integer"},
+						},
+					},
+				},
+				"source_tokens": map[string]interface{}{"type": "integer"},
+				"hierarchical":  map[string]interface{}{"type": "boolean"},
+				"model_calls":   map[string]interface{}{"type": "integer"},
+				"usage":         tokenUsageSchema,
+			},
+			"required": []string{"url", "style", "summary", "sections", "source_tokens", "hierarchical", "model_calls"},
+		},
+	}
+}
+
+// handleSummarizePage processes the summarize_page tool call
+func handleSummarizePage(id interface{}, args map[string]interface{}) *JSONRPCMessage {
+	pageURL, ok := args["url"].(string)
+	if !ok || pageURL == "" {
+		return newErrorResponse(id, -32602, "missing required parameter: url")
+	}
+	parsedURL, err := url.Parse(pageURL)
+	if err != nil {
+		return newErrorResponse(id, -32602, fmt.Sprintf("invalid URL format: %v", err))
+	}
+
+	style := summaryParagraph
+	if v, ok := args["style"].(string); ok && v != "" {
+		if _, known := summaryInstructions[v]; !known {
+			return newErrorResponse(id, -32602, fmt.Sprintf("invalid style: %s", v))
+		}
+		style = v
+	}
+	maxWords := 0
+	if v, ok := args["max_words"].(float64); ok && v > 0 {
+		maxWords = int(v)
+	}
+	focus, _ := args["focus"].(string)
+	include, _ := args["include_selector"].(string)
+	exclude, _ := args["exclude_selector"].(string)
+	for _, sel := range []string{include, exclude} {
+		if _, err := parseSelector(sel); sel != "" && err != nil {
+			return newErrorResponse(id, -32602, err.Error())
+		}
+	}
+	model, _ := args["model"].(string)
+	maxTokens := 0
+	if v, ok := args["maxTokens"].(float64); ok {
+		maxTokens = int(v)
+	}
+
+	log.Printf("Summarizing: %s", pageURL)
+
+	ctx := context.Background()
+	htmlContent, err := fetchWebContent(ctx, pageURL)
+	if err != nil {
+		return newErrorResponse(id, -1, fmt.Sprintf("Failed to fetch web content: %v", err))
+	}
+	meta := extractPageMetadata(htmlContent, parsedURL)
+	if htmlContent, err = scopeHTML(htmlContent, "", extractNoiseSelector); err == nil {
+		htmlContent, err = scopeHTML(htmlContent, include, exclude)
+	}
+	if err != nil {
+		return newErrorResponse(id, -2, fmt.Sprintf("Failed to apply selectors: %v", err))
+	}
+
+	passages := chunkMarkdown(htmlOutline(htmlContent), headingAnchors(htmlContent))
+	if len(passages) == 0 {
+		return newErrorResponse(id, -2, "Failed to summarize: the page has no text content")
+	}
+
+	output := SummarizePageOutput{
+		URL:      pageURL,
+		Title:    meta.Title,
+		Style:    style,
+		Sections: []SummarySection{},
+	}
+
+	texts := make([]string, len(passages))
+	for i, p := range passages {
+		texts[i] = fmt.Sprintf("[%s] (section: %s)\n%s", p.ID, p.Heading, p.Text)
+		output.SourceTokens += estimateTokens(p.Text)
+	}
+
+	var usage TokenUsage
+
+	// Reduce long pages to notes batch by batch until one call can take it all
+	for level := 0; level < maxSummaryLevels && len(texts) > 1 && countTokens(texts) > summarizeBatchTokens; level++ {
+		batches := groupByTokens(texts, summarizeBatchTokens)
+		log.Printf("Summarizing %s hierarchically: level %d, %d batches", pageURL, level+1, len(batches))
+
+		notes, batchUsage, err := summarizeBatches(ctx, batches, model, maxTokens, focus)
+		output.ModelCalls += len(batches)
+		usage.add(batchUsage)
+		if err != nil {
+			return newErrorResponse(id, -2, fmt.Sprintf("Failed to summarize: %v", err))
+		}
+		texts = notes
+		output.Hierarchical = true
+	}
+
+	instructions := summaryInstructions[style]
+	if maxWords > 0 {
+		instructions += fmt.Sprintf(" Use at most %d words.", maxWords)
+	}
+	if focus != "" {
+		instructions += " Focus on: " + focus + "."
+	}
+	source := "Passages"
+	if output.Hierarchical {
+		source = "Notes summarizing the page"
+	}
+
+	messages := []AIMessage{
+		{
+			Role: "system",
+			Content: "You summarize web pages faithfully, using only the text you are given. " +
+				"After each statement, cite the passages it draws on with their IDs in square brackets, e.g. [p3]. " +
+				"Return only the summary in Markdown.",
+		},
+		{
+			Role: "user",
+			Content: fmt.Sprintf("%s\n\nPage: %s\nTitle: %s\n\n%s:\n\n%s",
+				instructions, pageURL, meta.Title, source, strings.Join(texts, "\n\n")),
+		},
+	}
+	summary, summaryUsage, err := callAI(ctx, messages, model, maxTokens, summarizeTemperature)
+	output.ModelCalls++
+	usage.add(summaryUsage)
+	if err != nil {
+		return newErrorResponse(id, -2, fmt.Sprintf("Failed to summarize: %v", err))
+	}
+
+	output.Summary, output.Sections = summarySections(summary, passages)
+	output.Usage = usage.report()
+
+	return newToolResult(id, []interface{}{
+		TextContent{
+			Type: "text",
+			Text: formatSummaryReport(output),
+		},
+	}, output)
+}
+
+// summarizeBatches turns each batch of passages into dense notes that keep
+// the passage citations, so the final summary can still credit sections
+func summarizeBatches(ctx context.Context, batches [][]string, model string, maxTokens int, focus string) ([]string, TokenUsage, error) {
+	notes := make([]string, len(batches))
+	usages := make([]TokenUsage, len(batches))
+	errs := make([]error, len(batches))
+
+	instructions := "Condense these passages from part %d of %d of a web page into dense notes that keep every concrete fact " +
+		"(numbers, dates, names, versions, decisions). Keep the passage IDs in square brackets, e.g. [p3], after each note they support."
+	if focus != "" {
+		instructions += " Pay particular attention to: " + focus + "."
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, summarizeConcurrency)
+	for i, batch := range batches {
+		wg.Add(1)
+		go func(i int, batch []string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			messages := []AIMessage{
+				{
+					Role:    "system",
+					Content: "You take notes on web pages faithfully, using only the text you are given. Return only the notes.",
+				},
+				{
+					Role:    "user",
+					Content: fmt.Sprintf(instructions, i+1, len(batches)) + "\n\n" + strings.Join(batch, "\n\n"),
+				},
+			}
+			notes[i], usages[i], errs[i] = callAI(ctx, messages, model, maxTokens, summarizeTemperature)
+		}(i, batch)
+	}
+	wg.Wait()
+
+	var usage TokenUsage
+	for _, u := range usages {
+		usage.add(u)
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, usage, err
+		}
+	}
+	return notes, usage, nil
+}
+
+// summarySections strips passage citations from the summary and tallies the
+// sections they point at, in page order
+func summarySections(summary string, passages []pagePassage) (string, []SummarySection) {
+	byID := make(map[string]pagePassage, len(passages))
+	order := make(map[string]int)
+	for i, p := range passages {
+		byID[p.ID] = p
+		if _, ok := order[p.Heading]; !ok {
+			order[p.Heading] = i
+		}
+	}
+
+	counts := make(map[string]*SummarySection)
+	for _, match := range citationRegex.FindAllStringSubmatch(summary, -1) {
+		p, ok := byID[match[1]]
+		if !ok {
+			continue
+		}
+		if counts[p.Heading] == nil {
+			counts[p.Heading] = &SummarySection{Heading: p.Heading, Anchor: p.Anchor}
+		}
+		counts[p.Heading].Citations++
+	}
+
+	sections := []SummarySection{}
+	for _, s := range counts {
+		sections = append(sections, *s)
+	}
+	sort.Slice(sections, func(a, b int) bool { return order[sections[a].Heading] < order[sections[b].Heading] })
+
+	clean := citationRegex.ReplaceAllString(summary, "")
+	clean = citationSpaceRegex.ReplaceAllString(clean, "$1")
+	clean = doubleSpaceRegex.ReplaceAllString(clean, "$1 ")
+	clean = trailingSpaceRegex.ReplaceAllString(clean, "")
+	return strings.TrimSpace(clean), sections
+}
+
+// htmlOutline renders HTML as plain text with Markdown headings and paragraph
+// breaks, which is enough structure to chunk a page by section without the AI step
+func htmlOutline(htmlContent string) string {
+	text := outlineHeadingRegex.ReplaceAllStringFunc(htmlContent, func(h string) string {
+		match := outlineHeadingRegex.FindStringSubmatch(h)
+		level := int(match[1][0] - '0')
+		return "\n\n" + strings.Repeat("#", level) + " " + htmlText(match[2]) + "\n\n"
+	})
+	text = outlineBlockRegex.ReplaceAllString(text, "\n\n")
+	text = html.UnescapeString(tagStripRegex.ReplaceAllString(text, " "))
+	text = outlineSpaceRegex.ReplaceAllString(text, " ")
+	text = outlineBlankRegex.ReplaceAllString(text, "\n\n")
+
+	lines := strings.Split(text, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func countTokens(texts []string) int {
+	n := 0
+	for _, t := range texts {
+		n += estimateTokens(t)
+	}
+	return n
+}
+
+// groupByTokens packs consecutive texts into batches of at most maxTokens estimated tokens
+func groupByTokens(texts []string, maxTokens int) [][]string {
+	var batches [][]string
+	var current []string
+	tokens := 0
+	for _, t := range texts {
+		n := estimateTokens(t)
+		if len(current) > 0 && tokens+n > maxTokens {
+			batches = append(batches, current)
+			current, tokens = nil, 0
+		}
+		current = append(current, t)
+		tokens += n
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// formatSummaryReport renders the summary and the sections it drew on
+func formatSummaryReport(output SummarizePageOutput) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Summary of %s\n\n%s\n", firstNonEmpty(output.Title, output.URL), output.Summary)
+	if len(output.Sections) > 0 {
+		b.WriteString("\n**Sections used:**\n")
+		for _, s := range output.Sections {
+			fmt.Fprintf(&b, "- %s (%s)\n", s.Heading, sectionURL(output.URL, s.Anchor))
+		}
+	}
+	fmt.Fprintf(&b, "\n---\n- Source tokens (estimated): %d\n- Model calls: %d\n", output.SourceTokens, output.ModelCalls)
+	b.WriteString(formatTokenUsage(output.Usage))
+
+	return b.String()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSummarySections(t *testing.T) {
+	passages := []pagePassage{
+		{ID: "p1", Heading: "Intro", Anchor: "intro"},
+		{ID: "p2", Heading: "Setup", Anchor: "setup"},
+		{ID: "p3", Heading: "Setup", Anchor: "setup"},
+		{ID: "p4", Heading: "Usage", Anchor: "usage"},
+	}
+
+	tests := []struct {
+		name     string
+		summary  string
+		want     string
+		sections []SummarySection
+	}{
+		{
+			name:     "no citations",
+			summary:  "A plain summary.",
+			want:     "A plain summary.",
+			sections: []SummarySection{},
+		},
+		{
+			name:    "citations removed and tallied in page order",
+			summary: "Use it daily [p4]. Install first [p2][p3], as the intro says [p1].",
+			want:    "Use it daily. Install first, as the intro says.",
+			sections: []SummarySection{
+				{Heading: "Intro", Anchor: "intro", Citations: 1},
+				{Heading: "Setup", Anchor: "setup", Citations: 2},
+				{Heading: "Usage", Anchor: "usage", Citations: 1},
+			},
+		},
+		{
+			name:     "unknown passage ids ignored",
+			summary:  "Claim [p9] here.",
+			want:     "Claim here.",
+			sections: []SummarySection{},
+		},
+		{
+			name: "nested outline keeps its indentation",
+			summary: "- Setup [p2]\n" +
+				"  - Install the binary [p2]\n" +
+				"    - Add it to PATH [p3]\n" +
+				"- Usage\n" +
+				"  - Run  the tool [p4] daily",
+			want: "- Setup\n" +
+				"  - Install the binary\n" +
+				"    - Add it to PATH\n" +
+				"- Usage\n" +
+				"  - Run the tool daily",
+			sections: []SummarySection{
+				{Heading: "Setup", Anchor: "setup", Citations: 3},
+				{Heading: "Usage", Anchor: "usage", Citations: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, sections := summarySections(tt.summary, passages)
+			if got != tt.want {
+				t.Errorf("summarySections() summary =\n%q\nwant\n%q", got, tt.want)
+			}
+			if !reflect.DeepEqual(sections, tt.sections) {
+				t.Errorf("summarySections() sections = %+v, want %+v", sections, tt.sections)
+			}
+		})
+	}
+}
+
+func TestHTMLOutline(t *testing.T) {
+	htmlContent := `<html><body><h1>Title</h1><p>First   paragraph
+with a <b>bold</b> word.</p><h2 class="x">Part &amp; two</h2><ul><li>One</li><li>Two</li></ul></body></html>`
+	want := "# Title\n\nFirst paragraph\nwith a bold word.\n\n## Part & two\n\nOne\n\nTwo"
+
+	if got := htmlOutline(htmlContent); got != want {
+		t.Errorf("htmlOutline() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestGroupByTokens(t *testing.T) {
+	texts := []string{"aaaa", "bbbbbbbb", "cccc", "dddddddddddddddd", "e"}
+	want := [][]string{{"aaaa", "bbbbbbbb"}, {"cccc"}, {"dddddddddddddddd"}, {"e"}}
+
+	if got := groupByTokens(texts, 3); !reflect.DeepEqual(got, want) {
+		t.Errorf("groupByTokens() = %q, want %q", got, want)
+	}
+	if got := groupByTokens(nil, 3); got != nil {
+		t.Errorf("groupByTokens(nil) = %q, want nil", got)
+	}
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	defaultMaxTables = 50
+	maxTableSpan     = 1000
+	maxTableCells    = 100000
+
+	// tablePlaceholderFmt marks where a table was taken out before AI conversion
+	tablePlaceholderFmt = "WEBREADERTABLE%dPLACEHOLDER"
+)
+
+var (
+	headingElementRegex = regexp.MustCompile(`^h[1-6]$`)
+	tablePlaceholderRe  = regexp.MustCompile(`WEBREADERTABLE(\d+)PLACEHOLDER`)
+	tableSelector       = cascadia.MustCompile("table")
+	mainContentSelector = cascadia.MustCompile("main, article")
+)
+
+// ExtractedTable is one HTML table flattened into a header row and data rows
+type ExtractedTable struct {
+	Index       int        `json:"index"`
+	Caption     string     `json:"caption,omitempty"`
+	Heading     string     `json:"heading,omitempty"` // nearest heading before the table
+	ID          string     `json:"id,omitempty"`
+	Region      string     `json:"region,omitempty"`
+	Nested      bool       `json:"nested,omitempty"` // the table sits inside another table's cell
+	Headers     []string   `json:"headers"`
+	Rows        [][]string `json:"rows"`
+	RowCount    int        `json:"row_count"`
+	ColumnCount int        `json:"column_count"`
+	CSV         string     `json:"csv"`
+}
+
+// ExtractTablesOutput is the structuredContent returned by extract_tables
+type ExtractTablesOutput struct {
+	SourceURL string           `json:"source_url"`
+	Tables    []ExtractedTable `json:"tables"`
+	Total     int              `json:"total"`
+}
+
+// extractTablesTool describes the extract_tables tool
+func extractTablesTool() Tool {
+	return Tool{
+		Name:        "extract_tables",
+		Description: "Extract every HTML table on a page as structured data without the AI step. Handles thead, colspan/rowspan and multi-row headers, and returns each table's headers and rows as JSON and CSV together with its caption, nearest heading and page region.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"url": map[string]interface{}{
+					"type":        "string",
+					"description": "The page to extract tables from",
+				},
+				"include_selector": map[string]interface{}{
+					"type":        "string",
+					"description": "CSS selector limiting extraction to tables inside matching elements",
+				},
+				"exclude_selector": map[string]interface{}{
+					"type":        "string",
+					"description": "CSS selector for elements to ignore",
+				},
+				"max_tables": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of tables to return (default: 50)",
+				},
+				"skip_nested": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Skip tables nested inside other tables, which are often layout rather than data",
+				},
+			},
+			"required": []string{"url"},
+		},
+		OutputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"source_url": map[string]interface{}{"type": "string"},
+				"tables": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"index":   map[string]interface{}{"type": "integer"},
+							"caption": map[string]interface{}{"type": "string"},
+							"heading": map[string]interface{}{"type": "string"},
+							"id":      map[string]interface{}{"type": "string"},
+							"region":  map[string]interface{}{"type": "string"},
+							"nested":  map[string]interface{}{"type": "boolean"},
+							"headers": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+							"rows": map[string]interface{}{
+								"type":  "array",
+								"items": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+							},
+							"row_count":    map[string]interface{}{"type": "integer"},
+							"column_count": map[string]interface{}{"type": "integer"},
+							"csv":          map[string]interface{}{"type": "string"},
+						},
+						"required": []string{"index", "headers", "rows", "row_count", "column_count", "csv"},
+					},
+				},
+				"total": map[string]interface{}{"type": "integer"},
+			},
+			"required": []string{"source_url", "tables", "total"},
+		},
+	}
+}
+
+// handleExtractTables processes the extract_tables tool call
+func handleExtractTables(id interface{}, args map[string]interface{}) *JSONRPCMessage {
+	pageURL, ok := args["url"].(string)
+	if !ok || pageURL == "" {
+		return newErrorResponse(id, -32602, "missing required parameter: url")
+	}
+	if _, err := url.Parse(pageURL); err != nil {
+		return newErrorResponse(id, -32602, fmt.Sprintf("invalid URL format: %v", err))
+	}
+	include, _ := args["include_selector"].(string)
+	exclude, _ := args["exclude_selector"].(string)
+	for _, sel := range []string{include, exclude} {
+		if _, err := parseSelector(sel); sel != "" && err != nil {
+			return newErrorResponse(id, -32602, err.Error())
+		}
+	}
+	maxTables := defaultMaxTables
+	if v, ok := args["max_tables"].(float64); ok && v > 0 {
+		maxTables = int(v)
+	}
+	skipNested, _ := args["skip_nested"].(bool)
+
+	log.Printf("Extracting tables from: %s", pageURL)
+
+	htmlContent, err := fetchWebContent(context.Background(), pageURL)
+	if err != nil {
+		return newErrorResponse(id, -1, fmt.Sprintf("Failed to fetch web content: %v", err))
+	}
+	if htmlContent, err = scopeHTML(htmlContent, include, exclude); err != nil {
+		return newErrorResponse(id, -2, fmt.Sprintf("Failed to apply selectors: %v", err))
+	}
+
+	output := ExtractTablesOutput{
+		SourceURL: pageURL,
+		Tables:    []ExtractedTable{},
+	}
+	for _, table := range extractTables(htmlContent) {
+		if skipNested && table.Nested {
+			continue
+		}
+		output.Total++
+		if len(output.Tables) < maxTables {
+			output.Tables = append(output.Tables, table)
+		}
+	}
+
+	return newToolResult(id, []interface{}{
+		TextContent{
+			Type: "text",
+			Text: formatTablesReport(output),
+		},
+	}, output)
+}
+
+// extractTables parses every <table> in the document, in document order
+func extractTables(htmlContent string) []ExtractedTable {
+	doc, err := parseHTMLDocument(htmlContent)
+	if err != nil {
+		return nil
+	}
+	hasMain := cascadia.Query(doc, mainContentSelector) != nil
+
+	var tables []ExtractedTable
+	heading := ""
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "table" {
+			table := parseTable(n)
+			table.Index = len(tables) + 1
+			table.Heading = heading
+			table.Region = landmarkRegion(n, hasMain)
+			tables = append(tables, table)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		// A heading counts for the tables that follow its end tag
+		if n.Type == html.ElementNode && headingElementRegex.MatchString(n.Data) {
+			heading = nodeText(n)
+		}
+	}
+	walk(doc)
+	return tables
+}
+
+// tableCell is a cell as written in the markup, before spans are expanded
+type tableCell struct {
+	Text    string
+	Header  bool
+	ColSpan int
+	RowSpan int
+}
+
+// tableRow is a <tr> with the table section it belongs to
+type tableRow struct {
+	Section string // thead, tbody or tfoot
+	Cells   []tableCell
+}
+
+// parseTable reads a table element. Rows and cells of tables nested inside
+// its cells belong to those tables and are skipped.
+func parseTable(t *html.Node) ExtractedTable {
+	table := ExtractedTable{
+		ID:     nodeAttr(t, "id"),
+		Nested: hasAncestor(t, "table"),
+	}
+
+	var rows []tableRow
+	var walk func(n *html.Node, section string)
+	walk = func(n *html.Node, section string) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.Data {
+			case "table":
+				// Nested tables own their rows
+			case "caption":
+				if table.Caption == "" {
+					table.Caption = nodeText(c)
+				}
+			case "thead", "tbody", "tfoot":
+				walk(c, c.Data)
+			case "tr":
+				row := tableRow{Section: section}
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type != html.ElementNode || (cell.Data != "td" && cell.Data != "th") {
+						continue
+					}
+					row.Cells = append(row.Cells, tableCell{
+						Text:    nodeText(cell),
+						Header:  cell.Data == "th",
+						ColSpan: spanAttr(cell, "colspan"),
+						RowSpan: spanAttr(cell, "rowspan"),
+					})
+				}
+				rows = append(rows, row)
+			default:
+				walk(c, section)
+			}
+		}
+	}
+	walk(t, "tbody")
+
+	// Browsers render thead first and tfoot last wherever they appear in the markup
+	var ordered []tableRow
+	for _, section := range []string{"thead", "tbody", "tfoot"} {
+		for _, row := range rows {
+			if row.Section == section {
+				ordered = append(ordered, row)
+			}
+		}
+	}
+
+	grid := expandTableGrid(ordered)
+
+	// Header rows are the thead rows, or else leading rows made only of <th> cells
+	headerRows := 0
+	for headerRows < len(ordered) && ordered[headerRows].Section == "thead" {
+		headerRows++
+	}
+	if headerRows == 0 {
+		for headerRows < len(ordered)-1 && len(ordered[headerRows].Cells) > 0 && allHeaderCells(ordered[headerRows].Cells) {
+			headerRows++
+		}
+	}
+
+	columns := 0
+	for _, row := range grid {
+		columns = max(columns, len(row))
+	}
+	table.Headers = tableHeaders(grid[:headerRows], columns)
+	table.Rows = [][]string{}
+	for _, row := range grid[headerRows:] {
+		padded := make([]string, columns)
+		copy(padded, row)
+		table.Rows = append(table.Rows, padded)
+	}
+	table.RowCount = len(table.Rows)
+	table.ColumnCount = columns
+	table.CSV = tableCSV(table.Headers, table.Rows)
+
+	return table
+}
+
+// expandTableGrid places cells on a grid, copying spanned cells into every
+// position they cover so each row lines up with the header
+func expandTableGrid(rows []tableRow) [][]string {
+	grid := make([][]string, len(rows))
+	filled := make([][]bool, len(rows))
+	cells := 0
+
+	for r, row := range rows {
+		c := 0
+		for _, cell := range row.Cells {
+			for c < len(filled[r]) && filled[r][c] {
+				c++
+			}
+			rowSpan := cell.RowSpan
+			if rowSpan == 0 || r+rowSpan > len(rows) {
+				// rowspan="0" spans the rest of the table
+				rowSpan = len(rows) - r
+			}
+			for dr := 0; dr < rowSpan; dr++ {
+				for dc := 0; dc < cell.ColSpan && cells < maxTableCells; dc++ {
+					rr, cc := r+dr, c+dc
+					for len(grid[rr]) <= cc {
+						grid[rr] = append(grid[rr], "")
+						filled[rr] = append(filled[rr], false)
+					}
+					grid[rr][cc] = cell.Text
+					filled[rr][cc] = true
+					cells++
+				}
+			}
+			c += cell.ColSpan
+		}
+	}
+	return grid
+}
+
+// tableHeaders combines stacked header rows into one name per column, e.g.
+// "2024 / Q1" under a "2024" cell spanning four quarters. Columns without a
+// header are numbered, and repeated names get a suffix so every name is unique.
+func tableHeaders(headerRows [][]string, columns int) []string {
+	headers := make([]string, columns)
+	seen := make(map[string]int)
+	for c := 0; c < columns; c++ {
+		var parts []string
+		for _, row := range headerRows {
+			if c < len(row) && row[c] != "" && (len(parts) == 0 || parts[len(parts)-1] != row[c]) {
+				parts = append(parts, row[c])
+			}
+		}
+		name := strings.Join(parts, " / ")
+		if name == "" {
+			name = fmt.Sprintf("Column %d", c+1)
+		}
+		seen[name]++
+		if seen[name] > 1 {
+			name = fmt.Sprintf("%s (%d)", name, seen[name])
+		}
+		headers[c] = name
+	}
+	return headers
+}
+
+// tableCSV renders the header and rows as RFC 4180 CSV
+func tableCSV(headers []string, rows [][]string) string {
+	if len(headers) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write(headers)
+	w.WriteAll(rows)
+	return b.String()
+}
+
+// tableMarkdown renders a table as a GitHub-flavored Markdown table
+func tableMarkdown(table ExtractedTable) string {
+	if table.ColumnCount == 0 {
+		return ""
+	}
+
+	cell := func(s string) string {
+		s = strings.ReplaceAll(s, "\\", "\\\\")
+		return strings.ReplaceAll(s, "|", "\\|")
+	}
+
+	var b strings.Builder
+	if table.Caption != "" {
+		fmt.Fprintf(&b, "**%s**\n\n", table.Caption)
+	}
+	b.WriteString("|")
+	for _, h := range table.Headers {
+		fmt.Fprintf(&b, " %s |", cell(h))
+	}
+	b.WriteString("\n|")
+	for range table.Headers {
+		b.WriteString(" --- |")
+	}
+	b.WriteString("\n")
+	for _, row := range table.Rows {
+		b.WriteString("|")
+		for _, v := range row {
+			fmt.Fprintf(&b, " %s |", cell(v))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// replaceTablesWithPlaceholders swaps each top-level table for a placeholder
+// paragraph so the model does not rewrite it, returning the tables to put back
+func replaceTablesWithPlaceholders(htmlContent string) (string, []ExtractedTable) {
+	doc, err := parseHTMLDocument(htmlContent)
+	if err != nil {
+		return htmlContent, nil
+	}
+
+	var tables []ExtractedTable
+	for _, n := range outermostMatches(doc, tableSelector) {
+		table := parseTable(n)
+		table.Index = len(tables) + 1
+		tables = append(tables, table)
+
+		placeholder := &html.Node{Type: html.ElementNode, Data: "p", DataAtom: atom.P}
+		placeholder.AppendChild(&html.Node{Type: html.TextNode, Data: fmt.Sprintf(tablePlaceholderFmt, table.Index)})
+		n.Parent.InsertBefore(placeholder, n)
+		n.Parent.RemoveChild(n)
+	}
+	if len(tables) == 0 {
+		return htmlContent, nil
+	}
+	return renderNode(doc), tables
+}
+
+// fillTablePlaceholders puts the Markdown tables back in place of their
+// placeholders; tables whose placeholder the model dropped are appended
+func fillTablePlaceholders(markdown string, tables []ExtractedTable) string {
+	used := make(map[int]bool)
+	markdown = tablePlaceholderRe.ReplaceAllStringFunc(markdown, func(match string) string {
+		n, _ := strconv.Atoi(tablePlaceholderRe.FindStringSubmatch(match)[1])
+		if n < 1 || n > len(tables) {
+			return ""
+		}
+		used[n] = true
+		return "\n" + tableMarkdown(tables[n-1])
+	})
+
+	var missing []string
+	for _, table := range tables {
+		if !used[table.Index] && table.ColumnCount > 0 {
+			missing = append(missing, tableMarkdown(table))
+		}
+	}
+	if len(missing) > 0 {
+		markdown = strings.TrimRight(markdown, "\n") + "\n\n## Tables\n\n" + strings.Join(missing, "\n")
+	}
+	return markdown
+}
+
+// spanAttr reads a colspan or rowspan attribute, clamped to a sane range
+func spanAttr(cell *html.Node, name string) int {
+	v := strings.TrimSpace(nodeAttr(cell, name))
+	if v == "" {
+		return 1
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 1
+	}
+	if name == "rowspan" && n == 0 {
+		return 0
+	}
+	return min(max(n, 1), maxTableSpan)
+}
+
+func allHeaderCells(cells []tableCell) bool {
+	for _, c := range cells {
+		if !c.Header {
+			return false
+		}
+	}
+	return true
+}
+
+// formatTablesReport renders the extracted tables as Markdown
+func formatTablesReport(output ExtractTablesOutput) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Tables from %s\n\n", output.SourceURL)
+	fmt.Fprintf(&b, "- Tables found: %d\n", output.Total)
+	if len(output.Tables) < output.Total {
+		fmt.Fprintf(&b, "- Returned: %d (raise max_tables for more)\n", len(output.Tables))
+	}
+
+	for _, table := range output.Tables {
+		fmt.Fprintf(&b, "\n## Table %d", table.Index)
+		if table.Heading != "" {
+			fmt.Fprintf(&b, " (under \"%s\")", table.Heading)
+		}
+		fmt.Fprintf(&b, "\n\n- Size: %d rows x %d columns\n", table.RowCount, table.ColumnCount)
+		if table.Region != "" {
+			fmt.Fprintf(&b, "- Region: %s\n", table.Region)
+		}
+		if table.Nested {
+			b.WriteString("- Nested inside another table\n")
+		}
+		b.WriteString("\n")
+		b.WriteString(tableMarkdown(table))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testCell builds a table cell for tests
+func testCell(text string, colSpan, rowSpan int) tableCell {
+	return tableCell{Text: text, ColSpan: colSpan, RowSpan: rowSpan}
+}
+
+func TestExpandTableGrid(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []tableRow
+		want [][]string
+	}{
+		{
+			name: "plain",
+			rows: []tableRow{
+				{Cells: []tableCell{testCell("a", 1, 1), testCell("b", 1, 1)}},
+				{Cells: []tableCell{testCell("c", 1, 1), testCell("d", 1, 1)}},
+			},
+			want: [][]string{{"a", "b"}, {"c", "d"}},
+		},
+		{
+			name: "colspan",
+			rows: []tableRow{
+				{Cells: []tableCell{testCell("wide", 2, 1), testCell("x", 1, 1)}},
+				{Cells: []tableCell{testCell("a", 1, 1), testCell("b", 1, 1), testCell("c", 1, 1)}},
+			},
+			want: [][]string{{"wide", "wide", "x"}, {"a", "b", "c"}},
+		},
+		{
+			name: "rowspan shifts later cells",
+			rows: []tableRow{
+				{Cells: []tableCell{testCell("tall", 1, 2), testCell("a", 1, 1)}},
+				{Cells: []tableCell{testCell("b", 1, 1)}},
+			},
+			want: [][]string{{"tall", "a"}, {"tall", "b"}},
+		},
+		{
+			name: "rowspan in the middle",
+			rows: []tableRow{
+				{Cells: []tableCell{testCell("a", 1, 1), testCell("mid", 1, 3), testCell("b", 1, 1)}},
+				{Cells: []tableCell{testCell("c", 1, 1), testCell("d", 1, 1)}},
+				{Cells: []tableCell{testCell("e", 1, 1), testCell("f", 1, 1)}},
+			},
+			want: [][]string{{"a", "mid", "b"}, {"c", "mid", "d"}, {"e", "mid", "f"}},
+		},
+		{
+			name: "rowspan and colspan",
+			rows: []tableRow{
+				{Cells: []tableCell{testCell("block", 2, 2), testCell("a", 1, 1)}},
+				{Cells: []tableCell{testCell("b", 1, 1)}},
+			},
+			want: [][]string{{"block", "block", "a"}, {"block", "block", "b"}},
+		},
+		{
+			name: "rowspan zero spans the rest",
+			rows: []tableRow{
+				{Cells: []tableCell{testCell("all", 1, 0), testCell("a", 1, 1)}},
+				{Cells: []tableCell{testCell("b", 1, 1)}},
+				{Cells: []tableCell{testCell("c", 1, 1)}},
+			},
+			want: [][]string{{"all", "a"}, {"all", "b"}, {"all", "c"}},
+		},
+		{
+			name: "rowspan past the last row is clipped",
+			rows: []tableRow{
+				{Cells: []tableCell{testCell("a", 1, 5), testCell("b", 1, 1)}},
+				{Cells: []tableCell{testCell("c", 1, 1)}},
+			},
+			want: [][]string{{"a", "b"}, {"a", "c"}},
+		},
+		{
+			name: "ragged rows",
+			rows: []tableRow{
+				{Cells: []tableCell{testCell("a", 1, 1)}},
+				{Cells: []tableCell{testCell("b", 1, 1), testCell("c", 1, 1)}},
+				{},
+			},
+			want: [][]string{{"a"}, {"b", "c"}, nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandTableGrid(tt.rows); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandTableGrid() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    [][]string
+		columns int
+		want    []string
+	}{
+		{
+			name:    "single row",
+			rows:    [][]string{{"Name", "Age"}},
+			columns: 2,
+			want:    []string{"Name", "Age"},
+		},
+		{
+			name:    "stacked rows",
+			rows:    [][]string{{"Region", "2024", "2024"}, {"Region", "Q1", "Q2"}},
+			columns: 3,
+			want:    []string{"Region", "2024 / Q1", "2024 / Q2"},
+		},
+		{
+			name:    "missing names are numbered",
+			rows:    [][]string{{"Name"}},
+			columns: 3,
+			want:    []string{"Name", "Column 2", "Column 3"},
+		},
+		{
+			name:    "duplicates get a suffix",
+			rows:    [][]string{{"Value", "Value", "Value"}},
+			columns: 3,
+			want:    []string{"Value", "Value (2)", "Value (3)"},
+		},
+		{
+			name:    "no header rows",
+			rows:    nil,
+			columns: 2,
+			want:    []string{"Column 1", "Column 2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tableHeaders(tt.rows, tt.columns); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tableHeaders() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// translateChunkChars bounds each translation request; counted in
+	// characters because CJK text has no spaces to count words by
+	translateChunkChars  = 6000
+	translateConcurrency = 3
+	translateTemperature = 0.3
+)
+
+var (
+	keepPlaceholderRegex = regexp.MustCompile(`@@KEEP(\d+)@@`)
+	inlineCodeRegex      = regexp.MustCompile("`[^`\n]+`")
+	markdownURLRegex     = regexp.MustCompile(`(\]\()([^)\s]+)((?:\s+"[^"]*")?\))`)
+	bareURLRegex         = regexp.MustCompile(`<?https?://[\x21-\x28\x2a-\x3d\x3f-\x5c\x5e-\x7e]+>?`)
+	headingLineRegex     = regexp.MustCompile(`(?m)^#{1,6}\s`)
+)
+
+// languageNames maps common language names to their primary subtag so
+// "Chinese" and "zh-CN" are recognised as the same language
+var languageNames = map[string]string{
+	"english": "en", "chinese": "zh", "japanese": "ja", "korean": "ko", "russian": "ru",
+	"german": "de", "french": "fr", "spanish": "es", "portuguese": "pt", "italian": "it",
+	"arabic": "ar", "hebrew": "he", "greek": "el", "thai": "th", "hindi": "hi",
+	"中文": "zh", "简体中文": "zh", "繁體中文": "zh", "英文": "en", "英语": "en", "日语": "ja",
+}
+
+// TranslationInfo reports how web_reader translated the converted Markdown
+type TranslationInfo struct {
+	TargetLanguage string   `json:"target_language"`
+	SourceLanguage string   `json:"source_language"`
+	Detection      string   `json:"detection"`  // script, html_lang or default
+	Translated     bool     `json:"translated"` // false when the page is already in the target language
+	Chunks         int      `json:"chunks"`
+	Warnings       []string `json:"warnings,omitempty"`
+}
+
+// translateMarkdown translates Markdown into the target language. Code,
+// URLs and other text that must survive unchanged are swapped for
+// placeholders before the model sees the text and restored afterwards.
+func translateMarkdown(ctx context.Context, markdown, target, declaredLanguage, model string, maxTokens int) (string, *TranslationInfo, TokenUsage, error) {
+	protected, kept := protectMarkdown(markdown)
+
+	info := &TranslationInfo{TargetLanguage: target}
+	info.SourceLanguage, info.Detection = detectLanguage(keepPlaceholderRegex.ReplaceAllString(protected, " "), declaredLanguage)
+	if primaryLanguage(info.SourceLanguage) == primaryLanguage(target) {
+		log.Printf("Skipping translation: content is already in %s", target)
+		return markdown, info, TokenUsage{}, nil
+	}
+
+	chunks := translationChunks(protected)
+	info.Chunks = len(chunks)
+	log.Printf("Translating %s -> %s in %d chunks", info.SourceLanguage, target, len(chunks))
+
+	translated := make([]string, len(chunks))
+	usages := make([]TokenUsage, len(chunks))
+	errs := make([]error, len(chunks))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, translateConcurrency)
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			messages := []AIMessage{
+				{
+					Role: "system",
+					Content: "You are a professional translator. Translate the Markdown you are given into " + target + ". " +
+						"Keep the Markdown structure exactly: the same headings with the same number of # characters, lists, tables, emphasis and line breaks. " +
+						"Tokens like @@KEEP12@@ stand for code, URLs and other content that must not change: copy each one exactly, in the same place. " +
+						"Do not add explanations or notes. Return only the translated Markdown.",
+				},
+				{
+					Role:    "user",
+					Content: chunk,
+				},
+			}
+			translated[i], usages[i], errs[i] = callAI(ctx, messages, model, maxTokens, translateTemperature)
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	var usage TokenUsage
+	for _, u := range usages {
+		usage.add(u)
+	}
+	for i, err := range errs {
+		if err != nil {
+			return "", nil, usage, fmt.Errorf("chunk %d: %w", i+1, err)
+		}
+		if before, after := len(headingLineRegex.FindAllString(chunks[i], -1)), len(headingLineRegex.FindAllString(translated[i], -1)); before != after {
+			info.Warnings = append(info.Warnings, fmt.Sprintf("chunk %d: %d headings became %d", i+1, before, after))
+		}
+	}
+
+	result, missing := restoreMarkdown(strings.Join(translated, "\n\n"), kept)
+	if missing > 0 {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("%d protected code or URL fragments were dropped by the model", missing))
+	}
+	info.Translated = true
+
+	return result, info, usage, nil
+}
+
+// protectMarkdown replaces fenced code blocks, inline code and URLs with
+// @@KEEPn@@ placeholders and returns the text with the originals
+func protectMarkdown(markdown string) (string, []string) {
+	var kept []string
+	keep := func(s string) string {
+		kept = append(kept, s)
+		return fmt.Sprintf("@@KEEP%d@@", len(kept)-1)
+	}
+
+	// Fenced code blocks become one placeholder line each
+	segments := splitFencedBlocks(markdown)
+	parts := make([]string, len(segments))
+	for i, segment := range segments {
+		parts[i] = segment.Text
+		if segment.Code {
+			parts[i] = keep(segment.Text)
+		}
+	}
+	text := strings.Join(parts, "\n")
+
+	text = inlineCodeRegex.ReplaceAllStringFunc(text, keep)
+	text = markdownURLRegex.ReplaceAllStringFunc(text, func(m string) string {
+		parts := markdownURLRegex.FindStringSubmatch(m)
+		return parts[1] + keep(parts[2]) + parts[3]
+	})
+	text = bareURLRegex.ReplaceAllStringFunc(text, func(m string) string {
+		if keepPlaceholderRegex.MatchString(m) {
+			return m
+		}
+		// Sentence punctuation after a URL belongs to the text
+		url := strings.TrimRight(m, ".,;:!?'")
+		return keep(url) + m[len(url):]
+	})
+
+	return text, kept
+}
+
+// restoreMarkdown puts protected fragments back and counts the ones the model lost
+func restoreMarkdown(text string, kept []string) (string, int) {
+	used := make([]bool, len(kept))
+	text = keepPlaceholderRegex.ReplaceAllStringFunc(text, func(m string) string {
+		n, _ := strconv.Atoi(keepPlaceholderRegex.FindStringSubmatch(m)[1])
+		if n < 0 || n >= len(kept) {
+			return ""
+		}
+		used[n] = true
+		return kept[n]
+	})
+
+	missing := 0
+	for _, u := range used {
+		if !u {
+			missing++
+		}
+	}
+	return text, missing
+}
+
+// translationChunks splits Markdown at section boundaries into chunks of at
+// most translateChunkChars, falling back to paragraph boundaries for long sections
+func translationChunks(markdown string) []string {
+	var pieces []string
+	for _, section := range parseMarkdownSections(splitLines(markdown)) {
+		body := strings.Join(section.Body, "\n")
+		if len([]rune(body)) <= translateChunkChars {
+			pieces = append(pieces, body)
+			continue
+		}
+		pieces = append(pieces, strings.Split(body, "\n\n")...)
+	}
+
+	var chunks []string
+	var current strings.Builder
+	for _, piece := range pieces {
+		if current.Len() > 0 && len([]rune(current.String()))+len([]rune(piece)) > translateChunkChars {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(piece)
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// detectLanguage guesses the language of text from the scripts it uses.
+// Latin-script text cannot be told apart this way, so the page's declared
+// language is used for it when available.
+func detectLanguage(text, declared string) (string, string) {
+	counts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			counts["ja"]++
+		case unicode.Is(unicode.Hangul, r):
+			counts["ko"]++
+		case unicode.Is(unicode.Han, r):
+			counts["zh"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			counts["ru"]++
+		case unicode.Is(unicode.Arabic, r):
+			counts["ar"]++
+		case unicode.Is(unicode.Hebrew, r):
+			counts["he"]++
+		case unicode.Is(unicode.Greek, r):
+			counts["el"]++
+		case unicode.Is(unicode.Thai, r):
+			counts["th"]++
+		case unicode.Is(unicode.Devanagari, r):
+			counts["hi"]++
+		}
+	}
+
+	// Japanese mixes kana with Han characters, so any real share of kana decides it
+	if counts["ja"] > 0 && counts["ja"]*10 >= counts["zh"] {
+		counts["ja"] += counts["zh"]
+		counts["zh"] = 0
+	}
+
+	best, bestCount := "", 0
+	for lang, n := range counts {
+		if n > bestCount {
+			best, bestCount = lang, n
+		}
+	}
+	if letters > 0 && bestCount*3 >= letters {
+		if primaryLanguage(declared) == best {
+			return declared, "script"
+		}
+		return best, "script"
+	}
+
+	if declared != "" {
+		return declared, "html_lang"
+	}
+	return "en", "default"
+}
+
+// primaryLanguage reduces a language tag or name to its lowercase primary subtag
+func primaryLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if code, ok := languageNames[lang]; ok {
+		return code
+	}
+	if i := strings.IndexAny(lang, "-_"); i > 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// formatTranslationInfo renders translation details as metadata lines
+func formatTranslationInfo(info *TranslationInfo) string {
+	if info == nil {
+		return ""
+	}
+
+	source := fmt.Sprintf("- Source language: %s (detected by %s)\n", info.SourceLanguage, info.Detection)
+	if !info.Translated {
+		return source + fmt.Sprintf("- Translation: skipped, already in %s\n", info.TargetLanguage)
+	}
+
+	text := source + fmt.Sprintf("- Translated to: %s (%d chunks)\n", info.TargetLanguage, info.Chunks)
+	for _, w := range info.Warnings {
+		text += fmt.Sprintf("- Translation warning: %s\n", w)
+	}
+	return text
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProtectMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		text     string
+		kept     []string
+	}{
+		{
+			name:     "plain text",
+			markdown: "Hello world.",
+			text:     "Hello world.",
+			kept:     nil,
+		},
+		{
+			name:     "inline code",
+			markdown: "Run `go test` now.",
+			text:     "Run @@KEEP0@@ now.",
+			kept:     []string{"`go test`"},
+		},
+		{
+			name:     "link target keeps its text",
+			markdown: `See [the docs](https://example.com/docs "Docs") here.`,
+			text:     `See [the docs](@@KEEP0@@ "Docs") here.`,
+			kept:     []string{"https://example.com/docs"},
+		},
+		{
+			name:     "bare URL without trailing punctuation",
+			markdown: "Visit https://example.com/a?b=1. Thanks",
+			text:     "Visit @@KEEP0@@. Thanks",
+			kept:     []string{"https://example.com/a?b=1"},
+		},
+		{
+			name:     "fenced code block",
+			markdown: "Intro\n```go\nfmt.Println(\"hi\")\n```\nOutro",
+			text:     "Intro\n@@KEEP0@@\nOutro",
+			kept:     []string{"```go\nfmt.Println(\"hi\")\n```"},
+		},
+		{
+			name:     "image inside a link",
+			markdown: "[![logo](/logo.png)](https://example.com)",
+			text:     "[![logo](@@KEEP0@@)](@@KEEP1@@)",
+			kept:     []string{"/logo.png", "https://example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, kept := protectMarkdown(tt.markdown)
+			if text != tt.text {
+				t.Errorf("protectMarkdown() text = %q, want %q", text, tt.text)
+			}
+			if !reflect.DeepEqual(kept, tt.kept) {
+				t.Errorf("protectMarkdown() kept = %q, want %q", kept, tt.kept)
+			}
+
+			restored, missing := restoreMarkdown(text, kept)
+			if restored != tt.markdown || missing != 0 {
+				t.Errorf("restoreMarkdown() = %q, %d missing, want %q", restored, missing, tt.markdown)
+			}
+		})
+	}
+}
+
+func TestRestoreMarkdown(t *testing.T) {
+	kept := []string{"`a`", "https://example.com", "```\ncode\n```"}
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		missing int
+	}{
+		{"all present, reordered", "@@KEEP1@@ then @@KEEP0@@\n@@KEEP2@@", "https://example.com then `a`\n```\ncode\n```", 0},
+		{"one dropped", "@@KEEP0@@ and @@KEEP2@@", "`a` and ```\ncode\n```", 1},
+		{"duplicated placeholder", "@@KEEP0@@ @@KEEP0@@", "`a` `a`", 2},
+		{"unknown placeholder removed", "x @@KEEP9@@ y", "x  y", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, missing := restoreMarkdown(tt.text, kept)
+			if got != tt.want || missing != tt.missing {
+				t.Errorf("restoreMarkdown() = %q, %d missing, want %q, %d missing", got, missing, tt.want, tt.missing)
+			}
+		})
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		declared  string
+		want      string
+		detection string
+	}{
+		{"latin defaults to english", "The quick brown fox.", "", "en", "default"},
+		{"latin uses declared language", "Der schnelle braune Fuchs.", "de", "de", "html_lang"},
+		{"chinese", "这是一个测试页面，用于检查语言识别。", "", "zh", "script"},
+		{"japanese with kanji", "これは日本語のテストページです。", "", "ja", "script"},
+		{"korean", "이것은 테스트 페이지입니다.", "", "ko", "script"},
+		{"russian", "Это тестовая страница.", "en", "ru", "script"},
+		{"script keeps a matching declared tag", "这是一个测试页面。", "zh-TW", "zh-TW", "script"},
+		{"mostly latin with a few han characters", "This page explains the 漢字 writing system in detail.", "en", "en", "html_lang"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lang, detection := detectLanguage(tt.text, tt.declared)
+			if lang != tt.want || detection != tt.detection {
+				t.Errorf("detectLanguage(%q, %q) = %q, %q, want %q, %q", tt.text, tt.declared, lang, detection, tt.want, tt.detection)
+			}
+		})
+	}
+}
+
+func TestPrimaryLanguage(t *testing.T) {
+	tests := map[string]string{
+		"en":       "en",
+		"zh-CN":    "zh",
+		"pt_BR":    "pt",
+		" French ": "fr",
+		"简体中文":     "zh",
+		"":         "",
+	}
+	for in, want := range tests {
+		if got := primaryLanguage(in); got != want {
+			t.Errorf("primaryLanguage(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTranslationChunks(t *testing.T) {
+	section := strings.Repeat("word ", translateChunkChars/10)
+	markdown := "# A\n\n" + section + "\n\n# B\n\n" + section + "\n\n# C\n\n" + section
+
+	chunks := translationChunks(markdown)
+	if len(chunks) < 2 {
+		t.Fatalf("translationChunks() returned %d chunks, want several", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if n := len([]rune(chunk)); n > translateChunkChars {
+			t.Errorf("chunk %d has %d characters, over the %d limit", i, n, translateChunkChars)
+		}
+		if !strings.HasPrefix(chunk, "# ") {
+			t.Errorf("chunk %d does not start at a heading: %.20q", i, chunk)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// TokenUsage counts the tokens spent on AI calls
+type TokenUsage struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Calls            int     `json:"calls"`
+	Estimated        bool    `json:"estimated,omitempty"` // some counts were estimated locally because the provider sent none
+	CostUSD          float64 `json:"cost_usd,omitempty"`  // only when MODEL_PRICING covers the model
+}
+
+// add accumulates another usage into u
+func (u *TokenUsage) add(other TokenUsage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+	u.Calls += other.Calls
+	u.Estimated = u.Estimated || other.Estimated
+	u.CostUSD = math.Round((u.CostUSD+other.CostUSD)*1e6) / 1e6
+}
+
+// report returns u for optional output fields, or nil when no AI call was made
+func (u TokenUsage) report() *TokenUsage {
+	if u.Calls == 0 {
+		return nil
+	}
+	return &u
+}
+
+// modelPrice is the USD price per million tokens of a model
+type modelPrice struct {
+	Prompt     float64
+	Completion float64
+}
+
+// tokenLedger aggregates AI usage for the client session, which for a
+// stdio server is the process from one initialize to the next
+type tokenLedger struct {
+	mu        sync.Mutex
+	client    string
+	startedAt time.Time
+	total     TokenUsage
+	reserved  int // tokens held by calls still waiting for the model
+	byModel   map[string]*TokenUsage
+	budget    int // 0 is unlimited
+	prices    map[string]modelPrice
+}
+
+var sessionUsage = &tokenLedger{
+	startedAt: time.Now(),
+	byModel:   make(map[string]*TokenUsage),
+	prices:    make(map[string]modelPrice),
+}
+
+// configureTokenAccounting reads the session token budget and model prices from the environment
+func configureTokenAccounting() {
+	if v := os.Getenv("SESSION_TOKEN_BUDGET"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			sessionUsage.budget = n
+			log.Printf("Session token budget: %d", n)
+		} else {
+			log.Printf("Ignoring invalid SESSION_TOKEN_BUDGET %q", v)
+		}
+	}
+
+	// MODEL_PRICING is a comma-separated list of model=prompt:completion USD per million tokens
+	for _, entry := range strings.Split(os.Getenv("MODEL_PRICING"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		model, prices, _ := strings.Cut(entry, "=")
+		promptPrice, completionPrice, _ := strings.Cut(prices, ":")
+		p, err1 := strconv.ParseFloat(strings.TrimSpace(promptPrice), 64)
+		c, err2 := strconv.ParseFloat(strings.TrimSpace(completionPrice), 64)
+		if strings.TrimSpace(model) == "" || err1 != nil || err2 != nil {
+			log.Printf("Ignoring invalid MODEL_PRICING entry %q", entry)
+			continue
+		}
+		sessionUsage.prices[strings.TrimSpace(model)] = modelPrice{Prompt: p, Completion: c}
+	}
+}
+
+// reset starts a new session for the named client
+func (l *tokenLedger) reset(client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.client = client
+	l.startedAt = time.Now()
+	l.total = TokenUsage{}
+	l.reserved = 0
+	l.byModel = make(map[string]*TokenUsage)
+}
+
+// reserve holds the most a call can spend, its estimated prompt plus the
+// completion limit, against the session budget until the call is settled.
+// Concurrent calls therefore cannot overrun the budget together.
+func (l *tokenLedger) reserve(tokens int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.budget > 0 && l.total.TotalTokens+l.reserved+tokens > l.budget {
+		return fmt.Errorf("session token budget exhausted: %d of %d tokens used, %d held by calls in progress, this call needs up to %d",
+			l.total.TotalTokens, l.budget, l.reserved, tokens)
+	}
+	l.reserved += tokens
+	return nil
+}
+
+// release returns a reservation for a call that failed before the model answered
+func (l *tokenLedger) release(tokens int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reserved = max(0, l.reserved-tokens)
+}
+
+// record settles a call's reservation and adds its usage to the session
+// totals, pricing it when the model's price is known, and returns the priced usage
+func (l *tokenLedger) record(model string, reserved int, usage TokenUsage) TokenUsage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.reserved = max(0, l.reserved-reserved)
+
+	if price, ok := l.prices[model]; ok {
+		usage.CostUSD = math.Round((float64(usage.PromptTokens)*price.Prompt + float64(usage.CompletionTokens)*price.Completion)) / 1e6
+	}
+	l.total.add(usage)
+	if l.byModel[model] == nil {
+		l.byModel[model] = &TokenUsage{}
+	}
+	l.byModel[model].add(usage)
+	return usage
+}
+
+// usageErrorData is the data of an RPC error for a call that spent AI tokens
+// before it failed, or nil when no model call was made
+func usageErrorData(usage TokenUsage) json.RawMessage {
+	if usage.Calls == 0 {
+		return nil
+	}
+	data, err := json.Marshal(map[string]interface{}{"usage": usage})
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// aiCallUsage builds the usage of one AI call from the provider's counts,
+// estimating whichever are missing
+func aiCallUsage(messages []AIMessage, reply string, promptTokens, completionTokens int) TokenUsage {
+	usage := TokenUsage{PromptTokens: promptTokens, CompletionTokens: completionTokens, Calls: 1}
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = estimatePromptTokens(messages)
+		usage.Estimated = true
+	}
+	if usage.CompletionTokens == 0 && reply != "" {
+		usage.CompletionTokens = estimateTokens(reply)
+		usage.Estimated = true
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// estimatePromptTokens estimates the prompt size of a chat request
+func estimatePromptTokens(messages []AIMessage) int {
+	tokens := 0
+	for _, m := range messages {
+		tokens += estimateTokens(m.Content) + 4 // role and message framing
+	}
+	return tokens
+}
+
+// estimateTokens approximates a tokenizer: about four bytes per token for
+// ASCII text and one token per character for CJK and other scripts
+func estimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// formatTokenUsage renders usage as a metadata line
+func formatTokenUsage(usage *TokenUsage) string {
+	if usage == nil {
+		return ""
+	}
+	text := fmt.Sprintf("- Tokens: %d (%d prompt + %d completion, %d AI calls", usage.TotalTokens, usage.PromptTokens, usage.CompletionTokens, usage.Calls)
+	if usage.Estimated {
+		text += ", estimated"
+	}
+	text += ")\n"
+	if usage.CostUSD > 0 {
+		text += fmt.Sprintf("- Cost: $%.6f\n", usage.CostUSD)
+	}
+	return text + formatSessionBudget()
+}
+
+// formatSessionBudget reports the session's budget use as a metadata line
+func formatSessionBudget() string {
+	sessionUsage.mu.Lock()
+	defer sessionUsage.mu.Unlock()
+	if sessionUsage.budget == 0 {
+		return fmt.Sprintf("- Session tokens: %d\n", sessionUsage.total.TotalTokens)
+	}
+	return fmt.Sprintf("- Session tokens: %d of %d budget\n", sessionUsage.total.TotalTokens, sessionUsage.budget)
+}
+
+// ModelUsage is the session usage of one model
+type ModelUsage struct {
+	Model string `json:"model"`
+	TokenUsage
+}
+
+// TokenUsageOutput is the structuredContent returned by token_usage
+type TokenUsageOutput struct {
+	Client    string       `json:"client,omitempty"`
+	StartedAt string       `json:"started_at"`
+	Total     TokenUsage   `json:"total"`
+	ByModel   []ModelUsage `json:"by_model"`
+	Budget    int          `json:"budget,omitempty"`
+	Remaining *int         `json:"remaining,omitempty"`
+}
+
+// snapshot returns the session totals
+func (l *tokenLedger) snapshot() TokenUsageOutput {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	output := TokenUsageOutput{
+		Client:    l.client,
+		StartedAt: l.startedAt.UTC().Format(time.RFC3339),
+		Total:     l.total,
+		ByModel:   []ModelUsage{},
+		Budget:    l.budget,
+	}
+	for model, usage := range l.byModel {
+		output.ByModel = append(output.ByModel, ModelUsage{Model: model, TokenUsage: *usage})
+	}
+	sort.Slice(output.ByModel, func(i, j int) bool { return output.ByModel[i].Model < output.ByModel[j].Model })
+	if l.budget > 0 {
+		remaining := max(l.budget-l.total.TotalTokens, 0)
+		output.Remaining = &remaining
+	}
+	return output
+}
+
+// tokenUsageTool describes the token_usage tool
+func tokenUsageTool() Tool {
+	return Tool{
+		Name:        "token_usage",
+		Description: "Report the AI tokens and cost spent in this session, in total and per model, and the remaining session token budget when one is configured.",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+		OutputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"client":     map[string]interface{}{"type": "string"},
+				"started_at": map[string]interface{}{"type": "string", "format": "date-time"},
+				"total":      tokenUsageSchema,
+				"by_model":   map[string]interface{}{"type": "array", "items": modelUsageSchema},
+				"budget":     map[string]interface{}{"type": "integer"},
+				"remaining":  map[string]interface{}{"type": "integer"},
+			},
+			"required": []string{"started_at", "total", "by_model"},
+		},
+	}
+}
+
+// handleTokenUsage executes the token_usage tool
+func handleTokenUsage(id interface{}, args map[string]interface{}) *JSONRPCMessage {
+	output := sessionUsage.snapshot()
+
+	var b strings.Builder
+	b.WriteString("# Token Usage\n\n")
+	fmt.Fprintf(&b, "Session started %s", output.StartedAt)
+	if output.Client != "" {
+		fmt.Fprintf(&b, " by %s", output.Client)
+	}
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "- Total: %d tokens (%d prompt + %d completion) in %d AI calls\n",
+		output.Total.TotalTokens, output.Total.PromptTokens, output.Total.CompletionTokens, output.Total.Calls)
+	if output.Total.CostUSD > 0 {
+		fmt.Fprintf(&b, "- Cost: $%.6f\n", output.Total.CostUSD)
+	}
+	if output.Remaining != nil {
+		fmt.Fprintf(&b, "- Budget: %d tokens, %d remaining\n", output.Budget, *output.Remaining)
+	}
+	if output.Total.Estimated {
+		b.WriteString("- Some counts were estimated because the provider did not report usage\n")
+	}
+
+	if len(output.ByModel) > 0 {
+		b.WriteString("\n| Model | Calls | Prompt | Completion | Total | Cost (USD) |\n|---|---|---|---|---|---|\n")
+		for _, m := range output.ByModel {
+			fmt.Fprintf(&b, "| %s | %d | %d | %d | %d | %.6f |\n", m.Model, m.Calls, m.PromptTokens, m.CompletionTokens, m.TotalTokens, m.CostUSD)
+		}
+	}
+
+	return newToolResult(id, []interface{}{
+		TextContent{Type: "text", Text: b.String()},
+	}, output)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"a", 1},
+		{"abcd", 1},
+		{"abcde", 2},
+		{strings.Repeat("x", 400), 100},
+		{"日本語", 3},
+		{"héllo", 2}, // four ASCII bytes round up to one token, plus one for é
+		{"Go言語", 3},
+	}
+	for _, tt := range tests {
+		if got := estimateTokens(tt.text); got != tt.want {
+			t.Errorf("estimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestAICallUsage(t *testing.T) {
+	messages := []AIMessage{
+		{Role: "system", Content: strings.Repeat("s", 40)}, // 10 tokens + 4 framing
+		{Role: "user", Content: strings.Repeat("u", 80)},   // 20 tokens + 4 framing
+	}
+
+	tests := []struct {
+		name       string
+		reply      string
+		prompt     int
+		completion int
+		want       TokenUsage
+	}{
+		{
+			name:       "provider counts",
+			reply:      "answer",
+			prompt:     120,
+			completion: 30,
+			want:       TokenUsage{PromptTokens: 120, CompletionTokens: 30, TotalTokens: 150, Calls: 1},
+		},
+		{
+			name:  "both estimated",
+			reply: strings.Repeat("r", 20),
+			want:  TokenUsage{PromptTokens: 38, CompletionTokens: 5, TotalTokens: 43, Calls: 1, Estimated: true},
+		},
+		{
+			name:   "completion estimated",
+			reply:  strings.Repeat("r", 8),
+			prompt: 100,
+			want:   TokenUsage{PromptTokens: 100, CompletionTokens: 2, TotalTokens: 102, Calls: 1, Estimated: true},
+		},
+		{
+			name:   "empty reply needs no completion estimate",
+			prompt: 100,
+			want:   TokenUsage{PromptTokens: 100, TotalTokens: 100, Calls: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := aiCallUsage(messages, tt.reply, tt.prompt, tt.completion); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("aiCallUsage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenUsageAdd(t *testing.T) {
+	u := TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15, Calls: 1, CostUSD: 0.1}
+	u.add(TokenUsage{PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3, Calls: 1, Estimated: true, CostUSD: 0.2})
+
+	want := TokenUsage{PromptTokens: 11, CompletionTokens: 7, TotalTokens: 18, Calls: 2, Estimated: true, CostUSD: 0.3}
+	if !reflect.DeepEqual(u, want) {
+		t.Errorf("add() = %+v, want %+v", u, want)
+	}
+	if (TokenUsage{}).report() != nil {
+		t.Error("report() of an unused TokenUsage should be nil")
+	}
+}
+
+func TestTokenLedger(t *testing.T) {
+	l := &tokenLedger{
+		byModel: make(map[string]*TokenUsage),
+		budget:  1000,
+		prices:  map[string]modelPrice{"priced": {Prompt: 1, Completion: 2}},
+	}
+
+	if err := l.reserve(600); err != nil {
+		t.Fatalf("reserve(600) = %v, want nil", err)
+	}
+	// The first call's reservation counts against the budget until it settles
+	if err := l.reserve(500); err == nil {
+		t.Fatal("reserve(500) with 600 held succeeded, want budget error")
+	}
+
+	usage := l.record("priced", 600, TokenUsage{PromptTokens: 300, CompletionTokens: 100, TotalTokens: 400, Calls: 1})
+	if usage.CostUSD != 0.0005 {
+		t.Errorf("cost = %v, want 0.0005", usage.CostUSD)
+	}
+	if l.reserved != 0 || l.total.TotalTokens != 400 {
+		t.Errorf("after record: reserved %d, total %d, want 0 and 400", l.reserved, l.total.TotalTokens)
+	}
+
+	if err := l.reserve(500); err != nil {
+		t.Fatalf("reserve(500) after settling = %v, want nil", err)
+	}
+	l.release(500)
+	if l.reserved != 0 {
+		t.Errorf("after release: reserved %d, want 0", l.reserved)
+	}
+	if err := l.reserve(601); err == nil {
+		t.Error("reserve(601) with 400 spent succeeded, want budget error")
+	}
+
+	l.record("unpriced", 0, TokenUsage{PromptTokens: 10, TotalTokens: 10, Calls: 1})
+	if got := l.byModel["unpriced"]; got == nil || got.CostUSD != 0 || got.TotalTokens != 10 {
+		t.Errorf("unpriced model usage = %+v", got)
+	}
+	if l.total.Calls != 2 || l.total.TotalTokens != 410 {
+		t.Errorf("total = %+v, want 2 calls and 410 tokens", l.total)
+	}
+}
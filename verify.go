@@ -0,0 +1,382 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Fidelity check modes for the fidelity_check argument
+const (
+	fidelityOff    = "off"
+	fidelityFlag   = "flag"
+	fidelityRepair = "repair"
+)
+
+const (
+	maxFidelityIssues = 50
+	// codeRepairMinOverlap is the share of a code block's lines that must
+	// appear in a source <pre> element before the block is replaced by it
+	codeRepairMinOverlap = 0.5
+)
+
+var (
+	markdownImageRegex = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	markdownLinkRegex  = regexp.MustCompile(`\[((?:[^\[\]]|\[[^\]]*\])*)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	srcsetAttrRegex    = regexp.MustCompile(`(?i)<(?:img|source)\s[^>]*>`)
+)
+
+// FidelityIssue is one part of the Markdown that could not be traced back to the page
+type FidelityIssue struct {
+	Kind   string `json:"kind"`   // link, image or code_block
+	Value  string `json:"value"`  // the URL, or the first line of the code block
+	Action string `json:"action"` // flagged, unlinked, removed or restored
+}
+
+// FidelityReport summarises how faithfully the converted Markdown follows the source HTML
+type FidelityReport struct {
+	Mode                 string          `json:"mode"`
+	Score                float64         `json:"score"` // share of checked items found in the source, 1 when nothing was checked
+	LinksChecked         int             `json:"links_checked"`
+	LinksUnverified      int             `json:"links_unverified"`
+	ImagesChecked        int             `json:"images_checked"`
+	ImagesUnverified     int             `json:"images_unverified"`
+	CodeBlocksChecked    int             `json:"code_blocks_checked"`
+	CodeBlocksUnverified int             `json:"code_blocks_unverified"`
+	Repaired             int             `json:"repaired"`
+	Issues               []FidelityIssue `json:"issues,omitempty"`
+}
+
+// markdownSegment is a run of Markdown that is either a fenced code block or ordinary text
+type markdownSegment struct {
+	Text string
+	Code bool
+}
+
+// splitFencedBlocks splits Markdown into fenced code blocks, fences
+// included, and the text between them
+func splitFencedBlocks(markdown string) []markdownSegment {
+	var segments []markdownSegment
+	var text, block []string
+	fence := ""
+	for _, line := range strings.Split(markdown, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence == "" && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")):
+			if text != nil {
+				segments = append(segments, markdownSegment{Text: strings.Join(text, "\n")})
+				text = nil
+			}
+			fence = trimmed[:3]
+			block = []string{line}
+		case fence != "":
+			block = append(block, line)
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				segments = append(segments, markdownSegment{Text: strings.Join(block, "\n"), Code: true})
+				fence, block = "", nil
+			}
+		default:
+			text = append(text, line)
+		}
+	}
+	if fence != "" {
+		segments = append(segments, markdownSegment{Text: strings.Join(block, "\n"), Code: true})
+	}
+	if text != nil {
+		segments = append(segments, markdownSegment{Text: strings.Join(text, "\n")})
+	}
+	return segments
+}
+
+// verifyMarkdown checks the links, images and code blocks of AI-converted
+// Markdown against the HTML it was converted from. In repair mode invented
+// links are unlinked, invented images removed and altered code blocks
+// replaced with the matching <pre> element from the page.
+func verifyMarkdown(markdown, htmlContent string, baseURL *url.URL, mode string) (string, *FidelityReport) {
+	report := &FidelityReport{Mode: mode}
+	repair := mode == fidelityRepair
+
+	linkURLs, imageURLs := sourceURLs(htmlContent, baseURL)
+	decodedHTML := html.UnescapeString(htmlContent)
+	sourceCode := compactWhitespace(html.UnescapeString(tagStripRegex.ReplaceAllString(htmlContent, "")))
+	preBlocks := sourcePreBlocks(htmlContent)
+
+	addIssue := func(kind, value, action string) {
+		if len(report.Issues) < maxFidelityIssues {
+			report.Issues = append(report.Issues, FidelityIssue{Kind: kind, Value: truncateString(value, 200), Action: action})
+		}
+	}
+
+	// known reports whether a Markdown URL points somewhere the page does.
+	// Same-page anchors and non-web schemes only need to appear in the HTML.
+	known := func(raw string, sets ...map[string]bool) bool {
+		if strings.HasPrefix(raw, "data:") {
+			return true
+		}
+		u, err := resolveURL(baseURL, raw)
+		if err != nil {
+			return false
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return strings.Contains(decodedHTML, raw)
+		}
+		key := urlKey(u)
+		if key == urlKey(baseURL) {
+			return true
+		}
+		for _, set := range sets {
+			if set[key] {
+				return true
+			}
+		}
+		return false
+	}
+
+	segments := splitFencedBlocks(markdown)
+	for i, segment := range segments {
+		if segment.Code {
+			report.CodeBlocksChecked++
+			code := fencedCode(segment.Text)
+			if compact := compactWhitespace(code); compact == "" || strings.Contains(sourceCode, compact) {
+				continue
+			}
+			report.CodeBlocksUnverified++
+			action := "flagged"
+			if repair {
+				if original, ok := closestPreBlock(code, preBlocks); ok {
+					segments[i].Text = replaceFencedCode(segment.Text, original)
+					action = "restored"
+					report.Repaired++
+				}
+			}
+			addIssue("code_block", firstLine(code), action)
+			continue
+		}
+
+		text := markdownImageRegex.ReplaceAllStringFunc(segment.Text, func(m string) string {
+			parts := markdownImageRegex.FindStringSubmatch(m)
+			report.ImagesChecked++
+			if known(parts[2], imageURLs) {
+				return m
+			}
+			report.ImagesUnverified++
+			if repair {
+				addIssue("image", parts[2], "removed")
+				report.Repaired++
+				return ""
+			}
+			addIssue("image", parts[2], "flagged")
+			return m
+		})
+
+		// Images were handled above; a match preceded by "!" is one of them
+		var b strings.Builder
+		last := 0
+		for _, loc := range markdownLinkRegex.FindAllStringSubmatchIndex(text, -1) {
+			if loc[0] > 0 && text[loc[0]-1] == '!' {
+				continue
+			}
+			linkText, href := text[loc[2]:loc[3]], text[loc[4]:loc[5]]
+			report.LinksChecked++
+			if known(href, linkURLs, imageURLs) {
+				continue
+			}
+			report.LinksUnverified++
+			if !repair {
+				addIssue("link", href, "flagged")
+				continue
+			}
+			addIssue("link", href, "unlinked")
+			report.Repaired++
+			b.WriteString(text[last:loc[0]])
+			b.WriteString(linkText)
+			last = loc[1]
+		}
+		b.WriteString(text[last:])
+		segments[i].Text = b.String()
+	}
+
+	checked := report.LinksChecked + report.ImagesChecked + report.CodeBlocksChecked
+	unverified := report.LinksUnverified + report.ImagesUnverified + report.CodeBlocksUnverified
+	report.Score = 1
+	if checked > 0 {
+		report.Score = math.Round(float64(checked-unverified)/float64(checked)*1000) / 1000
+	}
+
+	if unverified > 0 {
+		log.Printf("Fidelity check: %d of %d links, images and code blocks not found in the source", unverified, checked)
+	}
+	if !repair {
+		return markdown, report
+	}
+
+	parts := make([]string, len(segments))
+	for i, segment := range segments {
+		parts[i] = segment.Text
+	}
+	return strings.Join(parts, "\n"), report
+}
+
+// sourceURLs returns the normalised link and image URLs of the page,
+// including every srcset candidate the model may have picked
+func sourceURLs(htmlContent string, baseURL *url.URL) (map[string]bool, map[string]bool) {
+	links := make(map[string]bool)
+	for _, link := range extractLinks(htmlContent, baseURL) {
+		if u, err := url.Parse(link.URL); err == nil {
+			links[urlKey(u)] = true
+		}
+	}
+
+	images := make(map[string]bool)
+	addImage := func(raw string) {
+		if raw == "" {
+			return
+		}
+		if u, err := resolveURL(baseURL, raw); err == nil {
+			images[urlKey(u)] = true
+		}
+	}
+	found, _ := extractImages(htmlContent, baseURL, imageOptions{})
+	for _, img := range found {
+		addImage(img.OriginalURL)
+	}
+	for _, tag := range srcsetAttrRegex.FindAllString(htmlContent, -1) {
+		addImage(tagAttr(tag, "src"))
+		for _, attr := range []string{"srcset", "data-srcset"} {
+			for _, candidate := range parseSrcset(tagAttr(tag, attr)) {
+				addImage(candidate.URL)
+			}
+		}
+		for _, candidate := range imageCandidates(tag) {
+			addImage(candidate.URL)
+		}
+	}
+
+	return links, images
+}
+
+// urlKey normalises a URL for comparison: no fragment, lowercase host, no trailing slash
+func urlKey(u *url.URL) string {
+	normalized := *u
+	normalized.Fragment = ""
+	normalized.RawFragment = ""
+	normalized.Host = strings.ToLower(normalized.Host)
+	normalized.Path = strings.TrimSuffix(normalized.Path, "/")
+	normalized.RawPath = ""
+	return normalized.String()
+}
+
+// compactWhitespace removes all whitespace so code can be compared
+// regardless of indentation and syntax-highlighting markup
+func compactWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+// fencedCode returns the code inside a fenced block, without the fences
+func fencedCode(block string) string {
+	lines := strings.Split(block, "\n")
+	if len(lines) < 2 {
+		return ""
+	}
+	lines = lines[1:]
+	if last := strings.TrimSpace(lines[len(lines)-1]); strings.HasPrefix(last, "```") || strings.HasPrefix(last, "~~~") {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// replaceFencedCode swaps the code inside a fenced block, keeping its fences and language
+func replaceFencedCode(block, code string) string {
+	lines := strings.Split(block, "\n")
+	closing := strings.TrimSpace(lines[0])[:3]
+	if len(lines) > 1 && strings.HasPrefix(strings.TrimSpace(lines[len(lines)-1]), closing) {
+		closing = lines[len(lines)-1]
+	}
+	return lines[0] + "\n" + strings.TrimRight(code, "\n") + "\n" + closing
+}
+
+// sourcePreBlocks returns the text of every <pre> element, with newlines kept
+func sourcePreBlocks(htmlContent string) []string {
+	var blocks []string
+	for _, span := range elementSpans(htmlContent, "pre") {
+		pre := htmlContent[span[0]:span[1]]
+		text := html.UnescapeString(tagStripRegex.ReplaceAllString(pre, ""))
+		blocks = append(blocks, strings.Trim(text, "\n"))
+	}
+	return blocks
+}
+
+// closestPreBlock finds the <pre> element sharing the most lines with the
+// code, if enough of the code's lines appear in it to call it the same sample
+func closestPreBlock(code string, blocks []string) (string, bool) {
+	var codeLines []string
+	for _, line := range strings.Split(code, "\n") {
+		if line = compactWhitespace(line); line != "" {
+			codeLines = append(codeLines, line)
+		}
+	}
+	if len(codeLines) == 0 {
+		return "", false
+	}
+
+	best, bestOverlap := "", 0.0
+	for _, block := range blocks {
+		blockLines := make(map[string]bool)
+		for _, line := range strings.Split(block, "\n") {
+			blockLines[compactWhitespace(line)] = true
+		}
+		matched := 0
+		for _, line := range codeLines {
+			if blockLines[line] {
+				matched++
+			}
+		}
+		if overlap := float64(matched) / float64(len(codeLines)); overlap > bestOverlap {
+			best, bestOverlap = block, overlap
+		}
+	}
+	return best, bestOverlap >= codeRepairMinOverlap
+}
+
+// firstLine returns the first non-blank line of s
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// formatFidelityReport renders the fidelity check as metadata lines
+func formatFidelityReport(report *FidelityReport) string {
+	if report == nil {
+		return ""
+	}
+
+	text := fmt.Sprintf("- Fidelity score: %.3f (%d/%d links, %d/%d images, %d/%d code blocks not found in source",
+		report.Score, report.LinksUnverified, report.LinksChecked, report.ImagesUnverified, report.ImagesChecked,
+		report.CodeBlocksUnverified, report.CodeBlocksChecked)
+	if report.Repaired > 0 {
+		text += fmt.Sprintf("; %d repaired", report.Repaired)
+	}
+	text += ")\n"
+
+	if len(report.Issues) == 0 {
+		return text
+	}
+	text += "\n**Fidelity issues:**\n"
+	for i, issue := range report.Issues {
+		if i >= 10 {
+			text += fmt.Sprintf("... and %d more\n", len(report.Issues)-10)
+			break
+		}
+		text += fmt.Sprintf("%d. %s %s: %s\n", i+1, issue.Action, issue.Kind, issue.Value)
+	}
+	text += "\n"
+	return text
+}
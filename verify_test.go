@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+const verifySourceHTML = `<html><body>
+<h1>Guide</h1>
+<p>Read the <a href="/docs/install">install guide</a> or <a href="https://other.example/ref">reference</a>.</p>
+<p>Mail <a href="mailto:team@example.com">us</a>.</p>
+<img src="/img/logo.png" alt="Logo">
+<img src="/img/photo-400.jpg" srcset="/img/photo-400.jpg 400w, /img/photo-800.jpg 800w" alt="Photo">
+<pre><code>go install example.com/tool@latest
+tool --help</code></pre>
+</body></html>`
+
+func TestVerifyMarkdown(t *testing.T) {
+	baseURL, _ := url.Parse("https://example.com/guide")
+
+	tests := []struct {
+		name     string
+		markdown string
+		mode     string
+		want     string // returned Markdown; empty means unchanged
+		score    float64
+		issues   []FidelityIssue
+	}{
+		{
+			name:     "everything traced to the page",
+			markdown: "See [install](https://example.com/docs/install), [ref](https://other.example/ref), [top](#guide) and [mail](mailto:team@example.com).\n\n![Logo](https://example.com/img/logo.png) ![Photo](/img/photo-800.jpg)\n\n```sh\ngo install example.com/tool@latest\ntool --help\n```",
+			mode:     fidelityFlag,
+			score:    1,
+		},
+		{
+			name:     "nothing to check",
+			markdown: "Just text.",
+			mode:     fidelityFlag,
+			score:    1,
+		},
+		{
+			name:     "invented items flagged",
+			markdown: "Read [the FAQ](https://example.com/faq).\n\n![Chart](/img/chart.png)\n\n[install](/docs/install)",
+			mode:     fidelityFlag,
+			score:    0.333,
+			issues: []FidelityIssue{
+				{Kind: "image", Value: "/img/chart.png", Action: "flagged"},
+				{Kind: "link", Value: "https://example.com/faq", Action: "flagged"},
+			},
+		},
+		{
+			name:     "invented items repaired",
+			markdown: "Read [the FAQ](https://example.com/faq).\n\n![Chart](/img/chart.png)\n\n[install](/docs/install)",
+			mode:     fidelityRepair,
+			want:     "Read the FAQ.\n\n\n\n[install](/docs/install)",
+			score:    0.333,
+			issues: []FidelityIssue{
+				{Kind: "image", Value: "/img/chart.png", Action: "removed"},
+				{Kind: "link", Value: "https://example.com/faq", Action: "unlinked"},
+			},
+		},
+		{
+			name:     "altered code block restored",
+			markdown: "Install:\n\n```sh\ngo install example.com/tool@v2\ntool --help\n```\n\nDone.",
+			mode:     fidelityRepair,
+			want:     "Install:\n\n```sh\ngo install example.com/tool@latest\ntool --help\n```\n\nDone.",
+			score:    0,
+			issues: []FidelityIssue{
+				{Kind: "code_block", Value: "go install example.com/tool@v2", Action: "restored"},
+			},
+		},
+		{
+			name:     "unrelated code block only flagged",
+			markdown: "```\nrm -rf /\n```",
+			mode:     fidelityRepair,
+			score:    0,
+			issues: []FidelityIssue{
+				{Kind: "code_block", Value: "rm -rf /", Action: "flagged"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, report := verifyMarkdown(tt.markdown, verifySourceHTML, baseURL, tt.mode)
+			want := tt.want
+			if want == "" {
+				want = tt.markdown
+			}
+			if got != want {
+				t.Errorf("verifyMarkdown() markdown =\n%q\nwant\n%q", got, want)
+			}
+			if report.Score != tt.score {
+				t.Errorf("score = %v, want %v", report.Score, tt.score)
+			}
+			if !reflect.DeepEqual(report.Issues, tt.issues) {
+				t.Errorf("issues = %+v, want %+v", report.Issues, tt.issues)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// urlListSplitRegex separates URLs passed to a prompt as one string argument
+var urlListSplitRegex = regexp.MustCompile(`[\s,]+`)
+
+// promptWorkflow is a reusable reading workflow offered through prompts/get.
+// Prompt arguments are strings, so lists are passed comma- or newline-separated.
+type promptWorkflow struct {
+	Prompt
+	render func(args map[string]string) string
+}
+
+// promptWorkflows are listed before the conversion profiles in prompts/list
+var promptWorkflows = []promptWorkflow{
+	{
+		Prompt: Prompt{
+			Name:        "research-topic",
+			Description: "Research a topic from a set of URLs and write a cited summary",
+			Arguments: []PromptArgument{
+				{Name: "topic", Description: "The topic or question to research", Required: true},
+				{Name: "urls", Description: "URLs to read, separated by commas or newlines", Required: true},
+				{Name: "audience", Description: "Who the write-up is for, e.g. \"engineers new to the project\""},
+			},
+		},
+		render: renderResearchPrompt,
+	},
+	{
+		Prompt: Prompt{
+			Name:        "compare-pages",
+			Description: "Compare two pages and report where they agree and differ",
+			Arguments: []PromptArgument{
+				{Name: "first_url", Description: "The first page", Required: true},
+				{Name: "second_url", Description: "The second page", Required: true},
+				{Name: "focus", Description: "Aspects to compare, e.g. \"pricing and rate limits\""},
+			},
+		},
+		render: renderComparePrompt,
+	},
+	{
+		Prompt: Prompt{
+			Name:        "extract-api-reference",
+			Description: "Extract a structured API reference from documentation pages",
+			Arguments: []PromptArgument{
+				{Name: "url", Description: "The API reference page", Required: true},
+				{Name: "follow_links", Description: "\"true\" to also read linked reference pages under the same path"},
+				{Name: "format", Description: "\"markdown\" (default) or \"json\""},
+			},
+		},
+		render: renderAPIReferencePrompt,
+	},
+}
+
+// findPromptWorkflow returns the workflow with the given prompt name
+func findPromptWorkflow(name string) (*promptWorkflow, bool) {
+	for i := range promptWorkflows {
+		if promptWorkflows[i].Name == name {
+			return &promptWorkflows[i], true
+		}
+	}
+	return nil, false
+}
+
+// missingPromptArgument returns the first required argument that is empty.
+// A value of only separators counts as empty, so a URL list of ", " is missing.
+func missingPromptArgument(prompt Prompt, args map[string]string) string {
+	for _, arg := range prompt.Arguments {
+		if arg.Required && len(splitURLList(args[arg.Name])) == 0 {
+			return arg.Name
+		}
+	}
+	return ""
+}
+
+// splitURLList splits a comma- or whitespace-separated list, dropping the empty
+// elements left by leading, trailing or repeated separators
+func splitURLList(list string) []string {
+	var urls []string
+	for _, u := range urlListSplitRegex.Split(list, -1) {
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// renderResearchPrompt asks for the URLs to be read in one batch and synthesised with citations
+func renderResearchPrompt(args map[string]string) string {
+	urls := splitURLList(args["urls"])
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Research the following topic: %s\n\n", strings.TrimSpace(args["topic"]))
+	b.WriteString("Sources:\n")
+	for _, u := range urls {
+		fmt.Fprintf(&b, "- %s\n", u)
+	}
+	b.WriteString("\nSteps:\n")
+	if len(urls) > 1 {
+		fmt.Fprintf(&b, "1. Read all %d sources in one call with the web_reader_batch tool. Note any that fail and carry on with the rest.\n", len(urls))
+	} else {
+		b.WriteString("1. Read the source with the web_reader tool.\n")
+	}
+	b.WriteString("2. For specific questions a source leaves unclear, use the ask_page tool on that URL rather than re-reading the whole page.\n")
+	b.WriteString("3. Write a summary of what the sources say about the topic, organised by theme rather than by source. " +
+		"Cite each claim with the URL it came from, and call out where sources disagree or where information is missing.\n")
+	if audience := strings.TrimSpace(args["audience"]); audience != "" {
+		fmt.Fprintf(&b, "\nWrite for this audience: %s\n", audience)
+	}
+	return b.String()
+}
+
+// renderComparePrompt asks for two pages to be read and compared side by side
+func renderComparePrompt(args map[string]string) string {
+	first, second := strings.TrimSpace(args["first_url"]), strings.TrimSpace(args["second_url"])
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Compare these two pages:\n\n- A: %s\n- B: %s\n\n", first, second)
+	b.WriteString("Steps:\n")
+	b.WriteString("1. Read both pages in one call with the web_reader_batch tool.\n")
+	b.WriteString("2. If either page has tables relevant to the comparison, read them with the extract_tables tool so values are compared exactly.\n")
+	if focus := strings.TrimSpace(args["focus"]); focus != "" {
+		fmt.Fprintf(&b, "3. Compare the pages on: %s.\n", focus)
+	} else {
+		b.WriteString("3. Compare the pages' purpose, main claims, facts and figures, and recommendations.\n")
+	}
+	b.WriteString("4. Report what both pages agree on, what only one of them covers, and where they contradict each other, " +
+		"quoting the relevant passage from each page for every difference. Finish with a short verdict.\n")
+	if first == second {
+		b.WriteString("\nBoth arguments name the same URL. To compare it with an earlier version, use the diff_page tool instead.\n")
+	}
+	return b.String()
+}
+
+// renderAPIReferencePrompt asks for an API reference to be extracted with the api-reference profile
+func renderAPIReferencePrompt(args map[string]string) string {
+	pageURL := strings.TrimSpace(args["url"])
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Extract the API reference documented at %s.\n\n", pageURL)
+	b.WriteString("Steps:\n")
+	b.WriteString("1. Read the page with the web_reader tool, passing prompt_profile \"api-reference\", tables_as_markdown true and fidelity_check \"repair\", " +
+		"so parameter tables and code samples come from the page itself.\n")
+	step := 2
+	if strings.EqualFold(strings.TrimSpace(args["follow_links"]), "true") {
+		fmt.Fprintf(&b, "%d. Find the linked reference pages with the crawl_site tool, seeded at the page and limited to its path prefix with a depth of 1, and read the ones that document endpoints or types.\n", step)
+		step++
+	}
+	if strings.EqualFold(strings.TrimSpace(args["format"]), "json") {
+		fmt.Fprintf(&b, "%d. Use the extract_structured tool on each page with a schema for an array of endpoints, each with method, path, description, "+
+			"parameters (name, in, type, required, description) and responses (status, description).\n", step)
+		step++
+		fmt.Fprintf(&b, "%d. Merge the results into a single JSON document and return it.\n", step)
+	} else {
+		fmt.Fprintf(&b, "%d. Produce one Markdown reference with a section per endpoint or type: signature, description, a parameter table and an example. "+
+			"Keep every parameter, and copy examples verbatim.\n", step)
+	}
+	b.WriteString("\nDo not invent parameters or endpoints; if something is unclear on the page, say so.\n")
+	return b.String()
+}
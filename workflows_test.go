@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitURLList(t *testing.T) {
+	tests := []struct {
+		list string
+		want []string
+	}{
+		{"https://a.example", []string{"https://a.example"}},
+		{"https://a.example, https://b.example", []string{"https://a.example", "https://b.example"}},
+		{"https://a.example,\nhttps://b.example,\n", []string{"https://a.example", "https://b.example"}},
+		{" ,https://a.example,,  https://b.example ", []string{"https://a.example", "https://b.example"}},
+		{"", nil},
+		{" , \n", nil},
+	}
+	for _, tt := range tests {
+		if got := splitURLList(tt.list); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitURLList(%q) = %q, want %q", tt.list, got, tt.want)
+		}
+	}
+}
+
+func TestRenderResearchPrompt(t *testing.T) {
+	tests := []struct {
+		name     string
+		urls     string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "several sources read in one batch",
+			urls:     "https://a.example, https://b.example",
+			contains: []string{"- https://a.example\n- https://b.example\n", "Read all 2 sources", "web_reader_batch"},
+		},
+		{
+			name:     "trailing separators add no sources",
+			urls:     "https://a.example,\nhttps://b.example,\n",
+			contains: []string{"Read all 2 sources"},
+			excludes: []string{"- \n"},
+		},
+		{
+			name:     "single source read with web_reader",
+			urls:     "https://a.example,",
+			contains: []string{"- https://a.example\n", "Read the source with the web_reader tool"},
+			excludes: []string{"- \n", "web_reader_batch"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := renderResearchPrompt(map[string]string{"topic": "Go modules", "urls": tt.urls})
+			for _, s := range tt.contains {
+				if !strings.Contains(got, s) {
+					t.Errorf("prompt does not contain %q:\n%s", s, got)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(got, s) {
+					t.Errorf("prompt contains %q:\n%s", s, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleGetPromptWorkflows(t *testing.T) {
+	tests := []struct {
+		name      string
+		params    string
+		wantError string
+		contains  string
+	}{
+		{
+			name:     "research topic",
+			params:   `{"name":"research-topic","arguments":{"topic":"Go modules","urls":"https://a.example"}}`,
+			contains: "Research the following topic: Go modules",
+		},
+		{
+			name:      "missing required argument",
+			params:    `{"name":"research-topic","arguments":{"topic":"Go modules"}}`,
+			wantError: "Missing required argument: urls",
+		},
+		{
+			name:      "URL list of only separators",
+			params:    `{"name":"research-topic","arguments":{"topic":"Go modules","urls":" ,\n"}}`,
+			wantError: "Missing required argument: urls",
+		},
+		{
+			name:     "same page compared with itself",
+			params:   `{"name":"compare-pages","arguments":{"first_url":"https://a.example","second_url":"https://a.example"}}`,
+			contains: "use the diff_page tool",
+		},
+		{
+			name:      "unknown prompt",
+			params:    `{"name":"no-such-prompt","arguments":{}}`,
+			wantError: "Unknown prompt: no-such-prompt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := handleGetPrompt(&JSONRPCMessage{JSONRPC: "2.0", ID: 1, Method: "prompts/get", Params: json.RawMessage(tt.params)})
+			if tt.wantError != "" {
+				if resp.Error == nil || resp.Error.Code != -32602 || resp.Error.Message != tt.wantError {
+					t.Fatalf("error = %+v, want -32602 %q", resp.Error, tt.wantError)
+				}
+				return
+			}
+			if resp.Error != nil {
+				t.Fatalf("unexpected error %+v", resp.Error)
+			}
+			result := resp.Result.(GetPromptResult)
+			if len(result.Messages) != 1 || !strings.Contains(result.Messages[0].Content.Text, tt.contains) {
+				t.Errorf("messages = %+v, want one containing %q", result.Messages, tt.contains)
+			}
+		})
+	}
+}